@@ -1,22 +1,52 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/handlers"
 	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/singbox"
+	"github.com/traf72/singbox-api/internal/singbox/config/dns"
 	"github.com/traf72/singbox-api/internal/utils"
 )
 
 func main() {
+	newToken := flag.Bool("new-token", false, "generate a new API token, print it with its hash and exit")
+	flag.Parse()
+
+	if *newToken {
+		printNewToken()
+		return
+	}
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := dns.LoadServers(); err != nil {
+		log.Fatal(err)
+	}
+
+	if !auth.Enabled() {
+		log.Println("WARNING: AUTH_TOKENS_FILE is not set, the API is available without authentication")
+	}
+
+	if singbox.SupervisorMode() {
+		startSupervisor()
+	}
+
+	app.StartSubscriptions()
+
 	router := http.NewServeMux()
 
 	router.Handle("GET /health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -24,19 +54,52 @@ func main() {
 		w.Write([]byte("The service is alive"))
 	}))
 
+	router.Handle("GET /dns-rules", handlers.ListDNSRulesHandler())
 	router.Handle("PUT /dns-rules", handlers.AddDNSRuleHandler())
 	router.Handle("DELETE /dns-rules", handlers.RemoveDNSRuleHandler())
+	router.Handle("POST /dns-rules/move", handlers.MoveDNSRuleHandler())
 
+	router.Handle("GET /ip-rules", handlers.ListIPRulesHandler())
 	router.Handle("PUT /ip-rules", handlers.AddIPRuleHandler())
 	router.Handle("DELETE /ip-rules", handlers.RemoveIPRuleHandler())
+	router.Handle("POST /ip-rules/move", handlers.MoveIPRuleHandler())
+	router.Handle("GET /ip-rules/analysis", handlers.AnalyzeIPRulesHandler())
+	router.Handle("POST /ip-rules/aggregate", handlers.AggregateIPRulesHandler())
+
+	router.Handle("POST /rules/batch", handlers.RulesBatchHandler())
+
+	router.Handle("GET /outbounds", handlers.ListOutboundsHandler())
+	router.Handle("POST /outbounds", handlers.AddOutboundHandler())
+	router.Handle("POST /outbounds/import", handlers.ImportOutboundsHandler())
+	router.Handle("GET /outbounds/{tag}", handlers.GetOutboundHandler())
+	router.Handle("GET /outbounds/{tag}/share", handlers.ShareOutboundHandler())
+	router.Handle("PUT /outbounds/{tag}", handlers.UpdateOutboundHandler())
+	router.Handle("DELETE /outbounds/{tag}", handlers.RemoveOutboundHandler())
+
+	router.Handle("GET /inbounds/{tag}/share", handlers.ShareInboundHandler())
+
+	router.Handle("GET /subscriptions", handlers.ListSubscriptionsHandler())
+	router.Handle("POST /subscriptions", handlers.AddSubscriptionHandler())
+	router.Handle("GET /subscriptions/{id}", handlers.GetSubscriptionHandler())
+	router.Handle("PUT /subscriptions/{id}", handlers.UpdateSubscriptionHandler())
+	router.Handle("DELETE /subscriptions/{id}", handlers.RemoveSubscriptionHandler())
+	router.Handle("POST /subscriptions/{id}/refresh", handlers.RefreshSubscriptionHandler())
+
+	router.Handle("GET /route/test", handlers.RouteTestHandler())
 
 	router.Handle("GET /config", handlers.GetConfigHandler())
+	router.Handle("GET /config/history", handlers.GetConfigHistoryHandler())
+	router.Handle("GET /config/history/{id}", handlers.GetConfigSnapshotHandler())
+	router.Handle("POST /config/history/{id}/restore", handlers.RestoreConfigSnapshotHandler())
 
+	router.Handle("GET /singbox/status", handlers.SingboxStatusHandler())
 	router.Handle("POST /singbox/start", handlers.SingboxStartHandler())
 	router.Handle("POST /singbox/stop", handlers.SingboxStopHandler())
 	router.Handle("POST /singbox/restart", handlers.SingboxRestartHandler())
 
 	router.Handle("GET /logs", handlers.LogDownloadHandler())
+	router.Handle("GET /logs/stream", handlers.LogStreamHandler())
+	router.Handle("GET /logs/entries", handlers.LogEntriesHandler())
 	router.Handle("PUT /logs/enable", handlers.LogsEnableHandler())
 	router.Handle("PUT /logs/disable", handlers.LogsDisableHandler())
 	router.Handle("PUT /logs/truncate", handlers.LogTruncateHandler())
@@ -47,9 +110,47 @@ func main() {
 	addr := utils.GetEnv("LISTEN_ADDR", "127.0.0.1:8080")
 	server := http.Server{
 		Addr:    addr,
-		Handler: middleware.NewHandler(router).WithRequestLogging().Build(),
+		Handler: middleware.NewHandler(router).WithRequestLogging().WithRequestID().Build(),
 	}
 
 	fmt.Println("Server is listening on", addr)
 	server.ListenAndServe()
 }
+
+func printNewToken() {
+	token, err := auth.NewToken()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("token:", token)
+	fmt.Println("hash: ", auth.HashToken(token))
+}
+
+func startSupervisor() {
+	if err := singbox.Start(); err != nil {
+		log.Println("failed to start sing-box:", err.Msg())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				if err := singbox.Reload(); err != nil {
+					log.Println("failed to reload sing-box:", err.Msg())
+				}
+
+				continue
+			}
+
+			// The child must not outlive the supervisor
+			if err := singbox.Stop(); err != nil {
+				log.Println("failed to stop sing-box:", err.Msg())
+			}
+
+			os.Exit(0)
+		}
+	}()
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/api/header"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+type Scope string
+
+const (
+	ScopeAll            Scope = "*"
+	ScopeRead           Scope = "read"
+	ScopeRulesWrite     Scope = "rules:write"
+	ScopeConfigWrite    Scope = "config:write"
+	ScopeServiceControl Scope = "service:control"
+)
+
+func (s Scope) isValid() bool {
+	switch s {
+	case ScopeAll, ScopeRead, ScopeRulesWrite, ScopeConfigWrite, ScopeServiceControl:
+		return true
+	default:
+		return false
+	}
+}
+
+const hashPrefix = "sha256:"
+
+var (
+	errMissingToken = apperr.NewUnauthorizedErr("Auth_MissingToken", "bearer token is missing")
+	errInvalidToken = apperr.NewUnauthorizedErr("Auth_InvalidToken", "bearer token is invalid")
+)
+
+func errMissingScope(s Scope) apperr.Err {
+	return apperr.NewForbiddenErr("Auth_MissingScope", fmt.Sprintf("token does not have the '%s' scope", s))
+}
+
+func errTokensFile(err string) apperr.Err {
+	return apperr.NewFatalErr("Auth_TokensFileError", err)
+}
+
+type Token struct {
+	Name   string  `json:"name"`
+	Hash   string  `json:"hash"`
+	Scopes []Scope `json:"scopes"`
+}
+
+func (t *Token) HasScope(s Scope) bool {
+	return slices.Contains(t.Scopes, ScopeAll) || slices.Contains(t.Scopes, s)
+}
+
+func (t *Token) validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("token name is empty")
+	}
+
+	digest, ok := strings.CutPrefix(t.Hash, hashPrefix)
+	if !ok {
+		return fmt.Errorf("token '%s' hash must start with '%s'", t.Name, hashPrefix)
+	}
+
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("token '%s' hash is not a valid SHA-256 hex digest", t.Name)
+	}
+
+	for _, s := range t.Scopes {
+		if !s.isValid() {
+			return fmt.Errorf("token '%s' has unknown scope '%s'", t.Name, s)
+		}
+	}
+
+	return nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type store struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	tokens  []*Token
+}
+
+var tokens = &store{}
+
+func Enabled() bool {
+	return getTokensPath() != ""
+}
+
+func getTokensPath() string {
+	return utils.GetEnv("AUTH_TOKENS_FILE", "")
+}
+
+func (s *store) load(path string) ([]*Token, apperr.Err) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, errTokensFile(err.Error())
+	}
+
+	if s.path == path && s.modTime.Equal(stat.ModTime()) {
+		return s.tokens, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errTokensFile(err.Error())
+	}
+	defer file.Close()
+
+	var loaded []*Token
+	if err := utils.FromJSON(file, &loaded); err != nil {
+		return nil, errTokensFile(err.Error())
+	}
+
+	for _, t := range loaded {
+		if err := t.validate(); err != nil {
+			return nil, errTokensFile(err.Error())
+		}
+	}
+
+	s.path, s.modTime, s.tokens = path, stat.ModTime(), loaded
+	return loaded, nil
+}
+
+func find(all []*Token, raw string) *Token {
+	hash := []byte(HashToken(raw))
+
+	var found *Token
+	for _, t := range all {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 && found == nil {
+			found = t
+		}
+	}
+
+	return found
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get(header.Authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func Authorize(r *http.Request, scope Scope) apperr.Err {
+	path := getTokensPath()
+	if path == "" {
+		return nil
+	}
+
+	raw, ok := bearerToken(r)
+	if !ok {
+		return errMissingToken
+	}
+
+	all, err := tokens.load(path)
+	if err != nil {
+		return err
+	}
+
+	t := find(all, raw)
+	if t == nil {
+		return errInvalidToken
+	}
+
+	if !t.HasScope(scope) {
+		return errMissingScope(scope)
+	}
+
+	return nil
+}
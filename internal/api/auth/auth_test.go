@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+const (
+	readToken  = "read-token"
+	rulesToken = "rules-token"
+	adminToken = "admin-token"
+)
+
+func writeTokensFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func validTokensFile() string {
+	return `[
+		{"name": "dashboard", "hash": "` + HashToken(readToken) + `", "scopes": ["read"]},
+		{"name": "ci", "hash": "` + HashToken(rulesToken) + `", "scopes": ["read", "rules:write"]},
+		{"name": "admin", "hash": "` + HashToken(adminToken) + `", "scopes": ["*"]}
+	]`
+}
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashToken("hello"))
+}
+
+func TestNewToken(t *testing.T) {
+	first, err := NewToken()
+	require.NoError(t, err)
+	second, err := NewToken()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 43)
+	assert.NotEqual(t, first, second)
+}
+
+func TestToken_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       Token
+		expectedErr string
+	}{
+		{"Valid", Token{Name: "ci", Hash: HashToken("x"), Scopes: []Scope{ScopeRead, ScopeRulesWrite}}, ""},
+		{"EmptyName", Token{Name: " ", Hash: HashToken("x")}, "token name is empty"},
+		{"PlainTextHash", Token{Name: "ci", Hash: "x"}, "token 'ci' hash must start with 'sha256:'"},
+		{"InvalidDigest", Token{Name: "ci", Hash: "sha256:abc"}, "token 'ci' hash is not a valid SHA-256 hex digest"},
+		{"UnknownScope", Token{Name: "ci", Hash: HashToken("x"), Scopes: []Scope{"write"}}, "token 'ci' has unknown scope 'write'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.validate()
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Setenv("AUTH_TOKENS_FILE", writeTokensFile(t, validTokensFile()))
+
+	tests := []struct {
+		name          string
+		authorization string
+		scope         Scope
+		expected      apperr.Err
+	}{
+		{"Read_ReadScope", "Bearer " + readToken, ScopeRead, nil},
+		{"Read_RulesScope", "Bearer " + readToken, ScopeRulesWrite, errMissingScope(ScopeRulesWrite)},
+		{"Rules_RulesScope", "bearer  " + rulesToken, ScopeRulesWrite, nil},
+		{"Rules_ServiceScope", "Bearer " + rulesToken, ScopeServiceControl, errMissingScope(ScopeServiceControl)},
+		{"Admin_ServiceScope", "Bearer " + adminToken, ScopeServiceControl, nil},
+		{"MissingHeader", "", ScopeRead, errMissingToken},
+		{"EmptyToken", "Bearer ", ScopeRead, errMissingToken},
+		{"BasicScheme", "Basic " + readToken, ScopeRead, errMissingToken},
+		{"UnknownToken", "Bearer unknown", ScopeRead, errInvalidToken},
+		{"HashAsToken", "Bearer " + HashToken(readToken), ScopeRead, errInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/config", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			assert.Equal(t, tt.expected, Authorize(r, tt.scope))
+		})
+	}
+}
+
+func TestAuthorize_Disabled(t *testing.T) {
+	t.Setenv("AUTH_TOKENS_FILE", "")
+
+	assert.False(t, Enabled())
+	assert.Nil(t, Authorize(httptest.NewRequest("GET", "/config", nil), ScopeServiceControl))
+}
+
+func TestAuthorize_InvalidTokensFile(t *testing.T) {
+	t.Setenv("AUTH_TOKENS_FILE", writeTokensFile(t, `[{"name": "ci", "hash": "plain", "scopes": ["read"]}]`))
+
+	r := httptest.NewRequest("GET", "/config", nil)
+	r.Header.Set("Authorization", "Bearer plain")
+
+	err := Authorize(r, ScopeRead)
+	require.NotNil(t, err)
+	assert.Equal(t, apperr.Fatal, err.Kind())
+	assert.Equal(t, "Auth_TokensFileError", err.Code())
+}
+
+func TestAuthorize_ReloadsChangedFile(t *testing.T) {
+	path := writeTokensFile(t, validTokensFile())
+	t.Setenv("AUTH_TOKENS_FILE", path)
+
+	r := httptest.NewRequest("GET", "/config", nil)
+	r.Header.Set("Authorization", "Bearer "+readToken)
+	require.Nil(t, Authorize(r, ScopeRead))
+
+	require.NoError(t, os.WriteFile(path, []byte(`[]`), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	assert.Equal(t, errInvalidToken, Authorize(r, ScopeRead))
+}
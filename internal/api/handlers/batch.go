@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func applyRulesBatch(w http.ResponseWriter, r *http.Request) {
+	batch := new(app.RulesBatch)
+
+	if err := utils.FromJSON(r.Body, batch); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	move, err := query.GetBool(r.URL.Query(), "move", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "move", err)
+		return
+	}
+
+	result, appErr := app.ApplyRulesBatch(batch, !noRestart, move)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	if result.HasConflicts() {
+		api.SendJsonWithStatus(w, http.StatusConflict, result)
+		return
+	}
+
+	if !result.Applied {
+		api.SendJsonWithStatus(w, http.StatusBadRequest, result)
+		return
+	}
+
+	api.SendJson(w, result)
+}
+
+func RulesBatchHandler() http.Handler {
+	return middleware.NewHandlerFunc(applyRulesBatch).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/header"
 	"github.com/traf72/singbox-api/internal/api/middleware"
 	"github.com/traf72/singbox-api/internal/api/query"
@@ -19,7 +21,7 @@ func getConfig(w http.ResponseWriter, r *http.Request) {
 
 	download, err := query.GetBool(r.URL.Query(), "download", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "download", err)
 		return
 	}
 
@@ -30,6 +32,65 @@ func getConfig(w http.ResponseWriter, r *http.Request) {
 	api.SendJson(w, c)
 }
 
+func getConfigHistory(w http.ResponseWriter, _ *http.Request) {
+	history, appErr := app.GetConfigHistory()
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, history)
+}
+
+func getConfigSnapshot(w http.ResponseWriter, r *http.Request) {
+	download, err := query.GetBool(r.URL.Query(), "download", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "download", err)
+		return
+	}
+
+	s, appErr := app.GetConfigSnapshot(r.PathValue("id"))
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	if download {
+		header.SetAttachment(w, fmt.Sprintf("config-%s.json", s.ID))
+		api.SendJson(w, s.Config)
+		return
+	}
+
+	api.SendJson(w, s)
+}
+
+func restoreConfigSnapshot(w http.ResponseWriter, r *http.Request) {
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	if err := app.RestoreConfigSnapshot(r.PathValue("id"), !noRestart); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func GetConfigHandler() http.Handler {
-	return middleware.NewHandlerFunc(getConfig).Build()
+	return middleware.NewHandlerFunc(getConfig).WithAuth(auth.ScopeRead).Build()
+}
+
+func GetConfigHistoryHandler() http.Handler {
+	return middleware.NewHandlerFunc(getConfigHistory).WithAuth(auth.ScopeRead).Build()
+}
+
+func GetConfigSnapshotHandler() http.Handler {
+	return middleware.NewHandlerFunc(getConfigSnapshot).WithAuth(auth.ScopeRead).Build()
+}
+
+func RestoreConfigSnapshotHandler() http.Handler {
+	return middleware.NewHandlerFunc(restoreConfigSnapshot).WithAuth(auth.ScopeConfigWrite).Build()
 }
@@ -4,45 +4,76 @@ import (
 	"net/http"
 
 	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/middleware"
 	"github.com/traf72/singbox-api/internal/api/query"
 	"github.com/traf72/singbox-api/internal/app"
 	"github.com/traf72/singbox-api/internal/utils"
 )
 
+func listDNSRules(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p, err := getPagination(q)
+	if err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	f := &app.DNSRuleFilter{
+		RouteMode: query.GetString(q, "mode", ""),
+		Type:      query.GetString(q, "type", ""),
+		Search:    query.GetString(q, "search", ""),
+	}
+
+	page, appErr := app.ListDNSRules(f, p)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, page)
+}
+
 func addDNSRule(w http.ResponseWriter, r *http.Request) {
 	dnsReq := new(app.DNSRule)
 
 	if err := utils.FromJSON(r.Body, dnsReq); err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidBody(w, err)
 		return
 	}
 
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
-	if err := app.AddDNSRule(dnsReq, !noRestart); err != nil {
-		api.SendError(w, err)
+	move, err := query.GetBool(r.URL.Query(), "move", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "move", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	rules, appErr := app.AddDNSRule(dnsReq, !noRestart, move)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJsonWithStatus(w, http.StatusCreated, rules)
 }
 
 func removeDNSRule(w http.ResponseWriter, r *http.Request) {
 	dnsReq := new(app.DNSRule)
 
 	if err := utils.FromJSON(r.Body, dnsReq); err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidBody(w, err)
 		return
 	}
 
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
@@ -54,10 +85,41 @@ func removeDNSRule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func moveDNSRule(w http.ResponseWriter, r *http.Request) {
+	dnsReq := new(app.DNSRule)
+
+	if err := utils.FromJSON(r.Body, dnsReq); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	result, appErr := app.MoveDNSRule(dnsReq, !noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, result)
+}
+
+func ListDNSRulesHandler() http.Handler {
+	return middleware.NewHandlerFunc(listDNSRules).WithAuth(auth.ScopeRead).Build()
+}
+
 func AddDNSRuleHandler() http.Handler {
-	return middleware.NewHandlerFunc(addDNSRule).WithJsonRequest().Build()
+	return middleware.NewHandlerFunc(addDNSRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
 }
 
 func RemoveDNSRuleHandler() http.Handler {
-	return middleware.NewHandlerFunc(removeDNSRule).WithJsonRequest().Build()
+	return middleware.NewHandlerFunc(removeDNSRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
+}
+
+func MoveDNSRuleHandler() http.Handler {
+	return middleware.NewHandlerFunc(moveDNSRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/header"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+)
+
+func shareInbound(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tag := r.PathValue("tag")
+	host := query.GetString(q, "host", "")
+	user := query.GetString(q, "user", "")
+
+	switch format := query.GetString(q, "format", "uri"); format {
+	case "uri":
+		share, appErr := app.ShareInbound(tag, host, user)
+		if appErr != nil {
+			api.SendError(w, appErr)
+			return
+		}
+
+		api.SendJson(w, share)
+	case "png":
+		size, err := query.GetInt(q, "size", 256)
+		if err != nil {
+			api.SendInvalidQuery(w, "size", err)
+			return
+		}
+
+		png, appErr := app.ShareInboundQR(tag, host, user, size)
+		if appErr != nil {
+			api.SendError(w, appErr)
+			return
+		}
+
+		header.SetContentType(w, header.ContentTypePNG)
+		w.Write(png)
+	default:
+		api.SendInvalidQuery(w, "format", errors.New("expected 'uri' or 'png'"))
+	}
+}
+
+func ShareInboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(shareInbound).WithAuth(auth.ScopeRead).Build()
+}
@@ -4,45 +4,69 @@ import (
 	"net/http"
 
 	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/middleware"
 	"github.com/traf72/singbox-api/internal/api/query"
 	"github.com/traf72/singbox-api/internal/app"
 	"github.com/traf72/singbox-api/internal/utils"
 )
 
+func listIPRules(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p, err := getPagination(q)
+	if err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	f := &app.IPRuleFilter{
+		RouteMode: query.GetString(q, "mode", ""),
+		Search:    query.GetString(q, "search", ""),
+	}
+
+	page, appErr := app.ListIPRules(f, p)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, page)
+}
+
 func addIPRule(w http.ResponseWriter, r *http.Request) {
 	ipReq := new(app.IPRule)
 
 	if err := utils.FromJSON(r.Body, ipReq); err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidBody(w, err)
 		return
 	}
 
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
-	if err := app.AddIPRule(ipReq, !noRestart); err != nil {
-		api.SendError(w, err)
+	rules, appErr := app.AddIPRule(ipReq, !noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	api.SendJsonWithStatus(w, http.StatusCreated, rules)
 }
 
 func removeIPRule(w http.ResponseWriter, r *http.Request) {
 	ipReq := new(app.IPRule)
 
 	if err := utils.FromJSON(r.Body, ipReq); err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidBody(w, err)
 		return
 	}
 
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
@@ -54,10 +78,75 @@ func removeIPRule(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func analyzeIPRules(w http.ResponseWriter, r *http.Request) {
+	analysis, err := app.AnalyzeIPRules()
+	if err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJson(w, analysis)
+}
+
+func aggregateIPRules(w http.ResponseWriter, r *http.Request) {
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	changes, appErr := app.AggregateIPRules(!noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, changes)
+}
+
+func moveIPRule(w http.ResponseWriter, r *http.Request) {
+	ipReq := new(app.IPRule)
+
+	if err := utils.FromJSON(r.Body, ipReq); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	result, appErr := app.MoveIPRule(ipReq, !noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, result)
+}
+
+func ListIPRulesHandler() http.Handler {
+	return middleware.NewHandlerFunc(listIPRules).WithAuth(auth.ScopeRead).Build()
+}
+
 func AddIPRuleHandler() http.Handler {
-	return middleware.NewHandlerFunc(addIPRule).WithJsonRequest().Build()
+	return middleware.NewHandlerFunc(addIPRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
 }
 
 func RemoveIPRuleHandler() http.Handler {
-	return middleware.NewHandlerFunc(removeIPRule).WithJsonRequest().Build()
+	return middleware.NewHandlerFunc(removeIPRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
+}
+
+func AnalyzeIPRulesHandler() http.Handler {
+	return middleware.NewHandlerFunc(analyzeIPRules).WithAuth(auth.ScopeRead).Build()
+}
+
+func AggregateIPRulesHandler() http.Handler {
+	return middleware.NewHandlerFunc(aggregateIPRules).WithAuth(auth.ScopeRulesWrite).Build()
+}
+
+func MoveIPRuleHandler() http.Handler {
+	return middleware.NewHandlerFunc(moveIPRule).WithJsonRequest().WithAuth(auth.ScopeRulesWrite).Build()
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/header"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/singbox"
+)
+
+const wsWriteTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{}
+
+func streamLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tail, err := query.GetInt(q, "tail", 0)
+	if err != nil {
+		api.SendInvalidQuery(w, "tail", err)
+		return
+	}
+
+	stream, appErr := app.OpenLogStream(&app.LogStreamFilter{
+		Level:  query.GetString(q, "level", ""),
+		Search: query.GetString(q, "search", ""),
+		Regex:  query.GetString(q, "regex", ""),
+		Tail:   tail,
+	})
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		streamLogWebSocket(w, r, stream)
+	} else {
+		streamLogEvents(w, r, stream)
+	}
+}
+
+func streamLogEvents(w http.ResponseWriter, r *http.Request, stream *singbox.LogStream) {
+	rc := http.NewResponseController(w)
+
+	header.SetContentType(w, header.ContentTypeEventStream)
+	w.Header().Set(header.CacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Println("log stream: flushing is not supported:", err)
+		return
+	}
+
+	stream.Follow(r.Context(), func(line string) error {
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", line); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	})
+}
+
+func streamLogWebSocket(w http.ResponseWriter, r *http.Request, stream *singbox.LogStream) {
+	// The upgrader has already replied with an error
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// The client is not expected to send anything, reading is needed to process the close and ping frames
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	stream.Follow(ctx, func(line string) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteMessage(websocket.TextMessage, []byte(line))
+	})
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
+
+func LogStreamHandler() http.Handler {
+	return middleware.NewHandlerFunc(streamLog).WithAuth(auth.ScopeRead).Build()
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/header"
 	"github.com/traf72/singbox-api/internal/api/middleware"
 	"github.com/traf72/singbox-api/internal/api/query"
@@ -35,23 +36,49 @@ func downloadLog(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func listLogEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p, appErr := getPagination(q)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	filter := &app.LogEntriesFilter{
+		From:     query.GetString(q, "from", ""),
+		To:       query.GetString(q, "to", ""),
+		Level:    query.GetString(q, "level", ""),
+		Outbound: query.GetString(q, "outbound", ""),
+		Domain:   query.GetString(q, "domain", ""),
+	}
+
+	page, appErr := app.ListLogEntries(filter, p)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, page)
+}
+
 func enableLog(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	noRestart, err := query.GetBool(q, "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
 	truncate, err := query.GetBool(q, "truncate", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "truncate", err)
 		return
 	}
 
 	level := query.GetString(q, "level", "")
 	if err := app.EnableLog(!noRestart, truncate, level); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -60,12 +87,13 @@ func enableLog(w http.ResponseWriter, r *http.Request) {
 func disableLog(w http.ResponseWriter, r *http.Request) {
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
 	if err := app.DisableLog(!noRestart); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -74,7 +102,7 @@ func disableLog(w http.ResponseWriter, r *http.Request) {
 func setLogLevel(w http.ResponseWriter, r *http.Request) {
 	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
 	if err != nil {
-		api.SendBadRequest(w, err.Error())
+		api.SendInvalidQuery(w, "norestart", err)
 		return
 	}
 
@@ -82,6 +110,7 @@ func setLogLevel(w http.ResponseWriter, r *http.Request) {
 
 	if err := app.SetLogLevel(l, !noRestart); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -97,21 +126,25 @@ func truncateLog(w http.ResponseWriter, _ *http.Request) {
 }
 
 func LogDownloadHandler() http.Handler {
-	return middleware.NewHandlerFunc(downloadLog).Build()
+	return middleware.NewHandlerFunc(downloadLog).WithAuth(auth.ScopeRead).Build()
+}
+
+func LogEntriesHandler() http.Handler {
+	return middleware.NewHandlerFunc(listLogEntries).WithAuth(auth.ScopeRead).Build()
 }
 
 func LogsEnableHandler() http.Handler {
-	return middleware.NewHandlerFunc(enableLog).Build()
+	return middleware.NewHandlerFunc(enableLog).WithAuth(auth.ScopeConfigWrite).Build()
 }
 
 func LogsDisableHandler() http.Handler {
-	return middleware.NewHandlerFunc(disableLog).Build()
+	return middleware.NewHandlerFunc(disableLog).WithAuth(auth.ScopeConfigWrite).Build()
 }
 
 func LogTruncateHandler() http.Handler {
-	return middleware.NewHandlerFunc(truncateLog).Build()
+	return middleware.NewHandlerFunc(truncateLog).WithAuth(auth.ScopeServiceControl).Build()
 }
 
 func LogSetLevelHandler() http.Handler {
-	return middleware.NewHandlerFunc(setLogLevel).Build()
+	return middleware.NewHandlerFunc(setLogLevel).WithAuth(auth.ScopeConfigWrite).Build()
 }
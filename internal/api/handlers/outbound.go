@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/header"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func listOutbounds(w http.ResponseWriter, _ *http.Request) {
+	outbounds, appErr := app.ListOutbounds()
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, outbounds)
+}
+
+func getOutbound(w http.ResponseWriter, r *http.Request) {
+	o, appErr := app.GetOutbound(r.PathValue("tag"))
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, o)
+}
+
+func shareOutbound(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tag := r.PathValue("tag")
+
+	switch format := query.GetString(q, "format", "uri"); format {
+	case "uri":
+		share, appErr := app.ShareOutbound(tag)
+		if appErr != nil {
+			api.SendError(w, appErr)
+			return
+		}
+
+		api.SendJson(w, share)
+	case "png":
+		size, err := query.GetInt(q, "size", 256)
+		if err != nil {
+			api.SendInvalidQuery(w, "size", err)
+			return
+		}
+
+		png, appErr := app.ShareOutboundQR(tag, size)
+		if appErr != nil {
+			api.SendError(w, appErr)
+			return
+		}
+
+		header.SetContentType(w, header.ContentTypePNG)
+		w.Write(png)
+	default:
+		api.SendInvalidQuery(w, "format", errors.New("expected 'uri' or 'png'"))
+	}
+}
+
+func addOutbound(w http.ResponseWriter, r *http.Request) {
+	o := new(config.Outbound)
+
+	if err := utils.FromJSON(r.Body, o); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	if err := app.AddOutbound(o, !noRestart); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJsonWithStatus(w, http.StatusCreated, o)
+}
+
+func updateOutbound(w http.ResponseWriter, r *http.Request) {
+	o := new(config.Outbound)
+
+	if err := utils.FromJSON(r.Body, o); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	if err := app.UpdateOutbound(r.PathValue("tag"), o, !noRestart); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJson(w, o)
+}
+
+func removeOutbound(w http.ResponseWriter, r *http.Request) {
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	if err := app.RemoveOutbound(r.PathValue("tag"), !noRestart); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func importOutbounds(w http.ResponseWriter, r *http.Request) {
+	imp := new(app.OutboundImport)
+
+	if err := utils.FromJSON(r.Body, imp); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	add, err := query.GetBool(r.URL.Query(), "add", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "add", err)
+		return
+	}
+
+	result, appErr := app.ImportOutbounds(imp, add, !noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	switch {
+	case result.HasStatus(app.BatchInvalid):
+		api.SendJsonWithStatus(w, http.StatusBadRequest, result)
+	case result.HasStatus(app.BatchConflict):
+		api.SendJsonWithStatus(w, http.StatusConflict, result)
+	case result.Added:
+		api.SendJsonWithStatus(w, http.StatusCreated, result)
+	default:
+		api.SendJson(w, result)
+	}
+}
+
+func ListOutboundsHandler() http.Handler {
+	return middleware.NewHandlerFunc(listOutbounds).WithAuth(auth.ScopeRead).Build()
+}
+
+func GetOutboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(getOutbound).WithAuth(auth.ScopeRead).Build()
+}
+
+func ShareOutboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(shareOutbound).WithAuth(auth.ScopeRead).Build()
+}
+
+func AddOutboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(addOutbound).WithJsonRequest().WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func UpdateOutboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(updateOutbound).WithJsonRequest().WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func RemoveOutboundHandler() http.Handler {
+	return middleware.NewHandlerFunc(removeOutbound).WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func ImportOutboundsHandler() http.Handler {
+	return middleware.NewHandlerFunc(importOutbounds).WithJsonRequest().WithAuth(auth.ScopeConfigWrite).Build()
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/url"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+func getPagination(q url.Values) (*app.Pagination, apperr.Err) {
+	offset, err := query.GetInt(q, "offset", 0)
+	if err != nil {
+		return nil, api.InvalidQueryErr("offset", err)
+	}
+
+	limit, err := query.GetInt(q, "limit", app.DefaultPageLimit)
+	if err != nil {
+		return nil, api.InvalidQueryErr("limit", err)
+	}
+
+	return &app.Pagination{Offset: offset, Limit: limit}, nil
+}
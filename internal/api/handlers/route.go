@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+)
+
+func testRoute(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	rq := &app.RouteQuery{
+		Host:     query.GetString(q, "host", ""),
+		IP:       query.GetString(q, "ip", ""),
+		Inbound:  query.GetString(q, "inbound", ""),
+		Protocol: query.GetString(q, "protocol", ""),
+	}
+
+	result, err := app.TestRoute(rq)
+	if err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJson(w, result)
+}
+
+func RouteTestHandler() http.Handler {
+	return middleware.NewHandlerFunc(testRoute).WithAuth(auth.ScopeRead).Build()
+}
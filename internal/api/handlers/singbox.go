@@ -4,13 +4,32 @@ import (
 	"net/http"
 
 	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
 	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
 	"github.com/traf72/singbox-api/internal/singbox"
 )
 
+func getSingboxStatus(w http.ResponseWriter, r *http.Request) {
+	lines, err := query.GetInt(r.URL.Query(), "lines", singbox.DefaultJournalLines)
+	if err != nil {
+		api.SendInvalidQuery(w, "lines", err)
+		return
+	}
+
+	status, appErr := singbox.GetStatus(lines)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, status)
+}
+
 func startSingbox(w http.ResponseWriter, r *http.Request) {
 	if err := singbox.Start(); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -19,6 +38,7 @@ func startSingbox(w http.ResponseWriter, r *http.Request) {
 func stopSingbox(w http.ResponseWriter, r *http.Request) {
 	if err := singbox.Stop(); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -27,19 +47,24 @@ func stopSingbox(w http.ResponseWriter, r *http.Request) {
 func restartSingbox(w http.ResponseWriter, r *http.Request) {
 	if err := singbox.Restart(); err != nil {
 		api.SendError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func SingboxStatusHandler() http.Handler {
+	return middleware.NewHandlerFunc(getSingboxStatus).WithAuth(auth.ScopeRead).Build()
+}
+
 func SingboxStartHandler() http.Handler {
-	return middleware.NewHandlerFunc(startSingbox).Build()
+	return middleware.NewHandlerFunc(startSingbox).WithAuth(auth.ScopeServiceControl).Build()
 }
 
 func SingboxStopHandler() http.Handler {
-	return middleware.NewHandlerFunc(stopSingbox).Build()
+	return middleware.NewHandlerFunc(stopSingbox).WithAuth(auth.ScopeServiceControl).Build()
 }
 
 func SingboxRestartHandler() http.Handler {
-	return middleware.NewHandlerFunc(restartSingbox).Build()
+	return middleware.NewHandlerFunc(restartSingbox).WithAuth(auth.ScopeServiceControl).Build()
 }
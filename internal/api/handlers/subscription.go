@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+	"github.com/traf72/singbox-api/internal/api/middleware"
+	"github.com/traf72/singbox-api/internal/api/query"
+	"github.com/traf72/singbox-api/internal/app"
+	"github.com/traf72/singbox-api/internal/subscription"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func listSubscriptions(w http.ResponseWriter, _ *http.Request) {
+	subs, appErr := app.ListSubscriptions()
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, subs)
+}
+
+func getSubscription(w http.ResponseWriter, r *http.Request) {
+	s, appErr := app.GetSubscription(r.PathValue("id"))
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, s)
+}
+
+func addSubscription(w http.ResponseWriter, r *http.Request) {
+	s := new(subscription.Subscription)
+
+	if err := utils.FromJSON(r.Body, s); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	if err := app.AddSubscription(s); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJsonWithStatus(w, http.StatusCreated, s)
+}
+
+func updateSubscription(w http.ResponseWriter, r *http.Request) {
+	s := new(subscription.Subscription)
+
+	if err := utils.FromJSON(r.Body, s); err != nil {
+		api.SendInvalidBody(w, err)
+		return
+	}
+
+	if err := app.UpdateSubscription(r.PathValue("id"), s); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	api.SendJson(w, s)
+}
+
+func removeSubscription(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	noRestart, err := query.GetBool(q, "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	purge, err := query.GetBool(q, "purge", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "purge", err)
+		return
+	}
+
+	if err := app.RemoveSubscription(r.PathValue("id"), purge, !noRestart); err != nil {
+		api.SendError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func refreshSubscription(w http.ResponseWriter, r *http.Request) {
+	noRestart, err := query.GetBool(r.URL.Query(), "norestart", false)
+	if err != nil {
+		api.SendInvalidQuery(w, "norestart", err)
+		return
+	}
+
+	result, appErr := app.RefreshSubscription(r.PathValue("id"), !noRestart)
+	if appErr != nil {
+		api.SendError(w, appErr)
+		return
+	}
+
+	api.SendJson(w, result)
+}
+
+func ListSubscriptionsHandler() http.Handler {
+	return middleware.NewHandlerFunc(listSubscriptions).WithAuth(auth.ScopeRead).Build()
+}
+
+func GetSubscriptionHandler() http.Handler {
+	return middleware.NewHandlerFunc(getSubscription).WithAuth(auth.ScopeRead).Build()
+}
+
+func AddSubscriptionHandler() http.Handler {
+	return middleware.NewHandlerFunc(addSubscription).WithJsonRequest().WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func UpdateSubscriptionHandler() http.Handler {
+	return middleware.NewHandlerFunc(updateSubscription).WithJsonRequest().WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func RemoveSubscriptionHandler() http.Handler {
+	return middleware.NewHandlerFunc(removeSubscription).WithAuth(auth.ScopeConfigWrite).Build()
+}
+
+func RefreshSubscriptionHandler() http.Handler {
+	return middleware.NewHandlerFunc(refreshSubscription).WithAuth(auth.ScopeConfigWrite).Build()
+}
@@ -8,11 +8,18 @@ import (
 const (
 	ContentType        = "Content-Type"
 	ContentDisposition = "Content-Disposition"
+	Authorization      = "Authorization"
+	WWWAuthenticate    = "WWW-Authenticate"
+	RequestID          = "X-Request-ID"
+	CacheControl       = "Cache-Control"
 )
 
 const (
-	ContentTypeJson      = "application/json"
-	ContentTypeTextPlain = "text/plain"
+	ContentTypeJson        = "application/json"
+	ContentTypeProblemJson = "application/problem+json"
+	ContentTypeTextPlain   = "text/plain"
+	ContentTypeEventStream = "text/event-stream"
+	ContentTypePNG         = "image/png"
 )
 
 func SetContentType(w http.ResponseWriter, value string) {
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api"
+	"github.com/traf72/singbox-api/internal/api/auth"
+)
+
+func Auth(scope auth.Scope) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := auth.Authorize(r, scope); err != nil {
+				api.SendError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"net/http"
+
+	"github.com/traf72/singbox-api/internal/api/auth"
 )
 
 type HttpHandler struct {
@@ -21,11 +23,21 @@ func (h *HttpHandler) WithJsonRequest() *HttpHandler {
 	return h
 }
 
+func (h *HttpHandler) WithAuth(scope auth.Scope) *HttpHandler {
+	h.handler = Auth(scope)(h.handler)
+	return h
+}
+
 func (h *HttpHandler) WithRequestLogging() *HttpHandler {
 	h.handler = LogRequest(h.handler)
 	return h
 }
 
+func (h *HttpHandler) WithRequestID() *HttpHandler {
+	h.handler = RequestID(h.handler)
+	return h
+}
+
 func (h *HttpHandler) Build() http.Handler {
 	return h.handler
 }
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/traf72/singbox-api/internal/api"
 	"github.com/traf72/singbox-api/internal/api/header"
+	"github.com/traf72/singbox-api/internal/apperr"
 )
 
 func JsonRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get(header.ContentType) != header.ContentTypeJson {
-			http.Error(w, fmt.Sprintf(`The "%s" must be "%s"`, header.ContentType, header.ContentTypeJson), http.StatusUnsupportedMediaType)
+			msg := fmt.Sprintf(`The "%s" must be "%s"`, header.ContentType, header.ContentTypeJson)
+			api.SendProblem(w, http.StatusUnsupportedMediaType, apperr.NewFieldValidationErr("Request_UnsupportedMediaType", header.ContentType, msg))
 			return
 		}
 
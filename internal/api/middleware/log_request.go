@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/traf72/singbox-api/internal/api/header"
 )
 
 type wrappedWriter struct {
@@ -16,6 +20,20 @@ func (w *wrappedWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 }
 
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack are needed by the log streaming, the websocket upgrader requires http.Hijacker itself
+func (w *wrappedWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *wrappedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.statusCode = http.StatusSwitchingProtocols
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
 func LogRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -24,6 +42,6 @@ func LogRequest(next http.Handler) http.Handler {
 
 		next.ServeHTTP(ww, r)
 
-		log.Printf("%d %s %s %d ms", ww.statusCode, r.Method, r.URL.Path, time.Since(start).Milliseconds())
+		log.Printf("%d %s %s %d ms %s", ww.statusCode, r.Method, r.URL.Path, time.Since(start).Milliseconds(), w.Header().Get(header.RequestID))
 	})
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/traf72/singbox-api/internal/api/header"
+)
+
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header.RequestID)
+		if !requestIDRegex.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(header.RequestID, id)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -31,3 +31,17 @@ func GetString(q url.Values, key string, fallback string) string {
 
 	return q.Get(key)
 }
+
+func GetInt(q url.Values, key string, fallback int) (int, error) {
+	if _, ok := q[key]; !ok {
+		return fallback, nil
+	}
+
+	val := q.Get(key)
+	result, err := strconv.Atoi(val)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid value '%s' for query param '%s', expected an integer", val, key)
+	}
+
+	return result, nil
+}
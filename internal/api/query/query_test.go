@@ -75,3 +75,63 @@ func TestGetBool(t *testing.T) {
 		})
 	}
 }
+
+func TestGetInt(t *testing.T) {
+	tests := []struct {
+		name        string
+		q           url.Values
+		key         string
+		fallback    int
+		expected    int
+		expectedErr error
+	}{
+		{
+			name:        "Key not present => fallback",
+			q:           url.Values{},
+			key:         "limit",
+			fallback:    100,
+			expected:    100,
+			expectedErr: nil,
+		},
+		{
+			name:        "Key present, value='20' => 20",
+			q:           url.Values{"limit": {"20"}},
+			key:         "limit",
+			fallback:    100,
+			expected:    20,
+			expectedErr: nil,
+		},
+		{
+			name:        "Key present, value='-1' => -1",
+			q:           url.Values{"limit": {"-1"}},
+			key:         "limit",
+			fallback:    100,
+			expected:    -1,
+			expectedErr: nil,
+		},
+		{
+			name:        "Key present, empty => fallback + error",
+			q:           url.Values{"limit": {}},
+			key:         "limit",
+			fallback:    100,
+			expected:    100,
+			expectedErr: errors.New("invalid value '' for query param 'limit', expected an integer"),
+		},
+		{
+			name:        "Invalid integer => fallback + error",
+			q:           url.Values{"limit": {"ten"}},
+			key:         "limit",
+			fallback:    100,
+			expected:    100,
+			expectedErr: errors.New("invalid value 'ten' for query param 'limit', expected an integer"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := GetInt(tt.q, tt.key, tt.fallback)
+			assert.Equal(t, tt.expected, val)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
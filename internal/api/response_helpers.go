@@ -11,6 +11,17 @@ import (
 
 var jsonSerializeOptions = &utils.JSONOptions{Indent: "    ", EscapeHTML: false}
 
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Code      string `json:"code"`
+	Kind      string `json:"kind"`
+	Field     string `json:"field,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
 func SendJson(w http.ResponseWriter, body any) {
 	header.SetContentType(w, header.ContentTypeJson)
 
@@ -19,31 +30,69 @@ func SendJson(w http.ResponseWriter, body any) {
 	}
 }
 
-func SendBadRequest(w http.ResponseWriter, err string) {
-	http.Error(w, err, http.StatusBadRequest)
+func SendJsonWithStatus(w http.ResponseWriter, status int, body any) {
+	header.SetContentType(w, header.ContentTypeJson)
+	w.WriteHeader(status)
+
+	if err := utils.ToJSON(w, body, jsonSerializeOptions); err != nil {
+		log.Printf("%d JsonEncodingError: %s", status, err)
+	}
+}
+
+func SendProblem(w http.ResponseWriter, status int, e apperr.Err) {
+	p := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Msg(),
+		Code:      e.Code(),
+		Kind:      e.Kind().String(),
+		Field:     e.Field(),
+		RequestID: w.Header().Get(header.RequestID),
+	}
+
+	header.SetContentType(w, header.ContentTypeProblemJson)
+	w.WriteHeader(status)
+
+	if err := utils.ToJSON(w, p, jsonSerializeOptions); err != nil {
+		log.Printf("%d JsonEncodingError: %s", status, err)
+	}
+}
+
+func InvalidQueryErr(key string, err error) apperr.Err {
+	return apperr.NewFieldValidationErr("Request_InvalidQueryParam", key, err.Error())
+}
+
+func SendInvalidQuery(w http.ResponseWriter, key string, err error) {
+	SendError(w, InvalidQueryErr(key, err))
 }
 
-func SendNotFound(w http.ResponseWriter, err string) {
-	http.Error(w, err, http.StatusNotFound)
+func SendInvalidBody(w http.ResponseWriter, err error) {
+	SendError(w, apperr.NewValidationErr("Request_InvalidBody", err.Error()))
 }
 
-func SendConflict(w http.ResponseWriter, err string) {
-	http.Error(w, err, http.StatusConflict)
+func SendUnauthorized(w http.ResponseWriter, e apperr.Err) {
+	w.Header().Set(header.WWWAuthenticate, "Bearer")
+	SendProblem(w, http.StatusUnauthorized, e)
 }
 
 func SendInternalServerError(w http.ResponseWriter, err apperr.Err) {
-	log.Printf("%d %s: %s", http.StatusInternalServerError, err.Code(), err.Msg())
-	http.Error(w, "", http.StatusInternalServerError)
+	log.Printf("%d %s %s: %s", http.StatusInternalServerError, w.Header().Get(header.RequestID), err.Code(), err.Msg())
+	SendProblem(w, http.StatusInternalServerError, err)
 }
 
 func SendError(w http.ResponseWriter, e apperr.Err) {
 	switch e.Kind() {
 	case apperr.Validation:
-		SendBadRequest(w, e.Msg())
+		SendProblem(w, http.StatusBadRequest, e)
 	case apperr.NotFound:
-		SendNotFound(w, e.Msg())
+		SendProblem(w, http.StatusNotFound, e)
 	case apperr.Conflict:
-		SendConflict(w, e.Msg())
+		SendProblem(w, http.StatusConflict, e)
+	case apperr.Unauthorized:
+		SendUnauthorized(w, e)
+	case apperr.Forbidden:
+		SendProblem(w, http.StatusForbidden, e)
 	default:
 		SendInternalServerError(w, e)
 	}
@@ -0,0 +1,292 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/dns"
+)
+
+var (
+	errBatchEmpty = apperr.NewValidationErr("RulesBatch_Empty", "batch has no operations")
+	// errBatchNotApplied is never returned to the caller, the failures are reported in the item results
+	errBatchNotApplied = apperr.NewValidationErr("RulesBatch_NotApplied", "batch has rules that cannot be applied")
+)
+
+func errBatchItemsConflict(r, other *dns.Rule, index int) apperr.Err {
+	return apperr.NewConflictErr("RulesBatch_ItemsConflict", fmt.Sprintf("rule '%s:%s' conflicts with the rule '%s:%s' (%s) of the item %d of the batch",
+		dnsRuleTypeName(r.Kind()), r.Domain(), dnsRuleTypeName(other.Kind()), other.Domain(), other.Mode(), index))
+}
+
+func errBatchInvalidOp(op string) apperr.Err {
+	return apperr.NewFieldValidationErr("RulesBatch_InvalidOp", "op", fmt.Sprintf("operation '%s' is invalid, expected 'add' or 'remove'", op))
+}
+
+type BatchOp string
+
+const (
+	BatchAdd    BatchOp = "add"
+	BatchRemove BatchOp = "remove"
+)
+
+func parseBatchOp(op string) (BatchOp, apperr.Err) {
+	switch BatchOp(strings.ToLower(strings.TrimSpace(op))) {
+	case BatchAdd:
+		return BatchAdd, nil
+	case BatchRemove:
+		return BatchRemove, nil
+	default:
+		return "", errBatchInvalidOp(op)
+	}
+}
+
+type BatchItemStatus string
+
+const (
+	BatchAdded          BatchItemStatus = "added"
+	BatchAlreadyPresent BatchItemStatus = "already_present"
+	BatchRemoved        BatchItemStatus = "removed"
+	BatchNotFound       BatchItemStatus = "not_found"
+	BatchInvalid        BatchItemStatus = "invalid"
+	BatchConflict       BatchItemStatus = "conflict"
+	BatchValid          BatchItemStatus = "valid"
+)
+
+type DNSRuleOp struct {
+	Op string `json:"op"`
+	DNSRule
+}
+
+type IPRuleOp struct {
+	Op string `json:"op"`
+	IPRule
+}
+
+type RulesBatch struct {
+	DNS []DNSRuleOp `json:"dns"`
+	IP  []IPRuleOp  `json:"ip"`
+}
+
+type BatchItemResult struct {
+	Index  int             `json:"index"`
+	Status BatchItemStatus `json:"status"`
+	Code   string          `json:"code,omitempty"`
+	Field  string          `json:"field,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Rules are the values produced from the input, e.g. the prefixes of an IP range
+	Rules []string `json:"rules,omitempty"`
+}
+
+type RulesBatchResult struct {
+	Applied bool              `json:"applied"`
+	DNS     []BatchItemResult `json:"dns"`
+	IP      []BatchItemResult `json:"ip"`
+}
+
+// RuleMoveResult tells which route modes the moved rules have been taken from
+type RuleMoveResult[T any] struct {
+	From  []string `json:"from"`
+	Rules []T      `json:"rules"`
+}
+
+func (r *RuleMoveResult[T]) addFrom(modes []config.RouteMode) {
+	for _, m := range modes {
+		if !slices.Contains(r.From, string(m)) {
+			r.From = append(r.From, string(m))
+		}
+	}
+}
+
+type batchItem struct {
+	op    BatchOp
+	rules []string
+	apply func(c *config.Conf, op BatchOp) (bool, apperr.Err)
+	// dnsRule is set for the DNS items only
+	dnsRule *dns.Rule
+}
+
+func dnsBatchItem(r *DNSRuleOp, move bool) (*batchItem, apperr.Err) {
+	op, err := parseBatchOp(r.Op)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := r.toConfigRule()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := newDNSRuleEntry(rule)
+	produced := []string{entry.Type + ":" + entry.Value}
+
+	return &batchItem{op: op, rules: produced, dnsRule: rule, apply: func(c *config.Conf, op BatchOp) (bool, apperr.Err) {
+		if op == BatchAdd {
+			return addDNSRule(c, rule, move)
+		}
+
+		return dns.Remove(c, rule), nil
+	}}, nil
+}
+
+func ipBatchItem(r *IPRuleOp) (*batchItem, apperr.Err) {
+	op, err := parseBatchOp(r.Op)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := r.toConfigRules()
+	if err != nil {
+		return nil, err
+	}
+
+	produced := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		produced = append(produced, rule.IP())
+	}
+
+	return &batchItem{op: op, rules: produced, apply: func(c *config.Conf, op BatchOp) (bool, apperr.Err) {
+		if op == BatchAdd {
+			return addIPRules(c, rules)
+		}
+
+		return removeIPRules(c, rules), nil
+	}}, nil
+}
+
+func validateBatchItems[T any](ops []T, toItem func(*T) (*batchItem, apperr.Err)) ([]*batchItem, []BatchItemResult, bool) {
+	items := make([]*batchItem, len(ops))
+	results := make([]BatchItemResult, len(ops))
+	valid := true
+
+	for i := range ops {
+		item, err := toItem(&ops[i])
+		if err != nil {
+			results[i] = BatchItemResult{Index: i, Status: BatchInvalid, Code: err.Code(), Field: err.Field(), Error: err.Msg()}
+			valid = false
+			continue
+		}
+
+		items[i] = item
+		results[i] = BatchItemResult{Index: i, Status: BatchValid, Rules: item.rules}
+	}
+
+	return items, results, valid
+}
+
+// checkBatchConflicts refuses the DNS rules added to a route mode while an earlier item of the batch adds
+// a conflicting rule to another one. Moving would make the later item remove the rule of the earlier one.
+func checkBatchConflicts(items []*batchItem, results []BatchItemResult) (valid bool) {
+	valid = true
+	for i, item := range items {
+		if item.op != BatchAdd {
+			continue
+		}
+
+		for j, earlier := range items[:i] {
+			if earlier.op != BatchAdd || !item.dnsRule.ConflictsWith(earlier.dnsRule) {
+				continue
+			}
+
+			err := errBatchItemsConflict(item.dnsRule, earlier.dnsRule, j)
+			results[i] = BatchItemResult{Index: i, Status: BatchConflict, Code: err.Code(), Error: err.Msg(), Rules: item.rules}
+			valid = false
+			break
+		}
+	}
+
+	return valid
+}
+
+// applyBatchItems reports the items that fail against the configuration, e.g. conflict with the existing rules
+// or route to an outbound that does not exist
+func applyBatchItems(c *config.Conf, items []*batchItem, results []BatchItemResult) (changed bool, failed bool) {
+	for i, item := range items {
+		applied, err := item.apply(c, item.op)
+		if err != nil {
+			status := BatchInvalid
+			if err.Kind() == apperr.Conflict {
+				status = BatchConflict
+			}
+
+			results[i] = BatchItemResult{Index: i, Status: status, Code: err.Code(), Field: err.Field(), Error: err.Msg(), Rules: item.rules}
+			failed = true
+			continue
+		}
+
+		changed = changed || applied
+
+		switch {
+		case item.op == BatchAdd && applied:
+			results[i].Status = BatchAdded
+		case item.op == BatchAdd:
+			results[i].Status = BatchAlreadyPresent
+		case applied:
+			results[i].Status = BatchRemoved
+		default:
+			results[i].Status = BatchNotFound
+		}
+	}
+
+	return changed, failed
+}
+
+// resetBatchResults marks the items that have not failed as valid only, nothing is applied
+func resetBatchResults(results []BatchItemResult) {
+	for i := range results {
+		if results[i].Status != BatchConflict && results[i].Status != BatchInvalid {
+			results[i].Status = BatchValid
+		}
+	}
+}
+
+// HasConflicts reports whether the batch has not been applied because of the conflicting rules
+func (r *RulesBatchResult) HasConflicts() bool {
+	return slices.ContainsFunc(r.DNS, func(item BatchItemResult) bool {
+		return item.Status == BatchConflict
+	})
+}
+
+// ApplyRulesBatch applies all operations or none of them, the DNS rules conflicting with the rules
+// of other modes are moved when move is set and refuse the batch otherwise
+func ApplyRulesBatch(b *RulesBatch, restart bool, move bool) (*RulesBatchResult, apperr.Err) {
+	if len(b.DNS) == 0 && len(b.IP) == 0 {
+		return nil, errBatchEmpty
+	}
+
+	dnsItems, dnsResults, dnsValid := validateBatchItems(b.DNS, func(r *DNSRuleOp) (*batchItem, apperr.Err) {
+		return dnsBatchItem(r, move)
+	})
+	ipItems, ipResults, ipValid := validateBatchItems(b.IP, ipBatchItem)
+
+	result := &RulesBatchResult{DNS: dnsResults, IP: ipResults}
+	if !dnsValid || !ipValid {
+		return result, nil
+	}
+
+	if !checkBatchConflicts(dnsItems, dnsResults) {
+		return result, nil
+	}
+
+	err := tryUpdateConfig("rules/batch", restart, func(c *config.Conf) (bool, apperr.Err) {
+		dnsChanged, dnsFailed := applyBatchItems(c, dnsItems, dnsResults)
+		ipChanged, ipFailed := applyBatchItems(c, ipItems, ipResults)
+		if dnsFailed || ipFailed {
+			resetBatchResults(dnsResults)
+			resetBatchResults(ipResults)
+			return false, errBatchNotApplied
+		}
+
+		return dnsChanged || ipChanged, nil
+	})
+	if err == errBatchNotApplied {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result.Applied = true
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func TestParseBatchOp(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    BatchOp
+		expectedErr apperr.Err
+	}{
+		{"Add", "add", BatchAdd, nil},
+		{"Add_TrimSpaces_LowerCase", " ADD\n", BatchAdd, nil},
+		{"Remove", "remove", BatchRemove, nil},
+		{"Empty", "", "", errBatchInvalidOp("")},
+		{"Unknown", "delete", "", errBatchInvalidOp("delete")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := parseBatchOp(tt.input)
+			assert.Equal(t, tt.expected, op)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
+
+func TestValidateBatchItems(t *testing.T) {
+	ops := []DNSRuleOp{
+		{Op: "add", DNSRule: DNSRule{RouteMode: "proxy", Domain: "google.com"}},
+		{Op: "bad", DNSRule: DNSRule{RouteMode: "proxy", Domain: "google.com"}},
+		{Op: "remove", DNSRule: DNSRule{RouteMode: "proxy", Domain: ""}},
+	}
+
+	items, results, valid := validateBatchItems(ops, func(r *DNSRuleOp) (*batchItem, apperr.Err) {
+		return dnsBatchItem(r, false)
+	})
+
+	assert.False(t, valid)
+	assert.NotNil(t, items[0])
+	assert.Nil(t, items[1])
+	assert.Nil(t, items[2])
+	assert.Equal(t, []BatchItemResult{
+		{Index: 0, Status: BatchValid, Rules: []string{"full:google.com"}},
+		{Index: 1, Status: BatchInvalid, Code: "RulesBatch_InvalidOp", Field: "op", Error: "operation 'bad' is invalid, expected 'add' or 'remove'"},
+		{Index: 2, Status: BatchInvalid, Code: errDNSEmptyRule.Code(), Field: "domain", Error: errDNSEmptyRule.Msg()},
+	}, results)
+}
+
+func TestApplyBatchItems(t *testing.T) {
+	c := &config.Conf{}
+	require.NoError(t, utils.FromJSON(strings.NewReader(`{"outbounds": [{"type": "direct", "tag": "direct"}, {"type": "socks", "tag": "proxy"}]}`), c))
+	c.Route.Rules = []config.RouteRule{
+		{Outbound: "proxy", IP_CIDR: []string{"8.8.8.8"}},
+	}
+
+	dnsOps := []DNSRuleOp{
+		{Op: "add", DNSRule: DNSRule{RouteMode: "proxy", Domain: "google.com"}},
+		{Op: "add", DNSRule: DNSRule{RouteMode: "proxy", Domain: "google.com"}},
+		{Op: "remove", DNSRule: DNSRule{RouteMode: "direct", Domain: "yandex.ru"}},
+	}
+	ipOps := []IPRuleOp{
+		{Op: "remove", IPRule: IPRule{RouteMode: "proxy", IP: "8.8.8.8"}},
+		{Op: "add", IPRule: IPRule{RouteMode: "block", IP: "10.10.0.0/16"}},
+		{Op: "add", IPRule: IPRule{RouteMode: "direct", IP: "192.168.0.0-192.168.1.255"}},
+	}
+
+	dnsItems, dnsResults, dnsValid := validateBatchItems(dnsOps, func(r *DNSRuleOp) (*batchItem, apperr.Err) {
+		return dnsBatchItem(r, false)
+	})
+	ipItems, ipResults, ipValid := validateBatchItems(ipOps, ipBatchItem)
+	assert.True(t, dnsValid)
+	assert.True(t, ipValid)
+
+	dnsChanged, dnsConflicted := applyBatchItems(c, dnsItems, dnsResults)
+	assert.True(t, dnsChanged)
+	assert.False(t, dnsConflicted)
+
+	ipChanged, _ := applyBatchItems(c, ipItems, ipResults)
+	assert.True(t, ipChanged)
+
+	assert.Equal(t, []BatchItemResult{
+		{Index: 0, Status: BatchAdded, Rules: []string{"full:google.com"}},
+		{Index: 1, Status: BatchAlreadyPresent, Rules: []string{"full:google.com"}},
+		{Index: 2, Status: BatchNotFound, Rules: []string{"full:yandex.ru"}},
+	}, dnsResults)
+	assert.Equal(t, []BatchItemResult{
+		{Index: 0, Status: BatchRemoved, Rules: []string{"8.8.8.8"}},
+		{Index: 1, Status: BatchAdded, Rules: []string{"10.10.0.0/16"}},
+		{Index: 2, Status: BatchAdded, Rules: []string{"192.168.0.0/23"}},
+	}, ipResults)
+
+	assert.Equal(t, config.Listable{"google.com"}, c.Route.Rules[0].Domain)
+	assert.Empty(t, c.Route.Rules[0].IP_CIDR)
+	assert.Equal(t, config.Listable{"10.10.0.0/16"}, c.Route.Rules[1].IP_CIDR)
+	assert.Equal(t, "reject", c.Route.Rules[1].Action)
+	assert.Equal(t, config.Listable{"192.168.0.0/23"}, c.Route.Rules[2].IP_CIDR)
+	assert.Equal(t, config.Listable{"google.com"}, c.DNS.Rules[0].Domain)
+	assert.Equal(t, "dns-remote", c.DNS.Rules[0].Server)
+}
@@ -1,7 +1,12 @@
 package app
 
 import (
+	"fmt"
+	"log"
+	"os"
+
 	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox"
 	"github.com/traf72/singbox-api/internal/singbox/config"
 )
 
@@ -13,3 +18,124 @@ func GetConfig() (*config.Conf, apperr.Err) {
 
 	return c.Conf, nil
 }
+
+func updateConfig(operation string, restart bool, update func(c *config.Conf) (changed bool)) apperr.Err {
+	return tryUpdateConfig(operation, restart, func(c *config.Conf) (bool, apperr.Err) {
+		return update(c), nil
+	})
+}
+
+// tryUpdateConfig is updateConfig for the updates that can be refused after looking at the configuration,
+// nothing is saved or restarted when update fails
+func tryUpdateConfig(operation string, restart bool, update func(c *config.Conf) (changed bool, err apperr.Err)) apperr.Err {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	changed, err := update(c.Conf)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		if restart {
+			return singbox.Restart()
+		}
+
+		return nil
+	}
+
+	if err := checkConfig(c.Conf); err != nil {
+		return err
+	}
+
+	previous, err := config.ReadRaw()
+	if err != nil {
+		return err
+	}
+
+	if err := config.Save(c, operation); err != nil {
+		return err
+	}
+
+	if restart {
+		return restartOrRollback(c, previous)
+	}
+
+	return nil
+}
+
+func checkConfig(c *config.Conf) apperr.Err {
+	path, err := config.WriteCandidate(c)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.Remove(path); err != nil {
+			log.Println("failed to remove candidate config file:", err)
+		}
+	}()
+
+	return singbox.Check(path)
+}
+
+// restartOrRollback puts the previous configuration back when sing-box fails with the one c has saved,
+// unless another request has changed it in the meantime
+func restartOrRollback(c *config.Config, previous []byte) apperr.Err {
+	err := singbox.Restart()
+	if err == nil {
+		err = singbox.WaitActive()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	log.Printf("sing-box failed to restart with the new configuration, rolling back: %s", err.Msg())
+
+	if rollbackErr := config.RestoreRaw(c, previous, "config/rollback"); rollbackErr != nil {
+		if rollbackErr.Kind() == apperr.Conflict {
+			return apperr.NewConflictErr("Singbox_RollbackConflict", fmt.Sprintf("sing-box failed to restart with the new configuration (%s), it is not rolled back as another request has modified it", err.Msg()))
+		}
+
+		return apperr.NewFatalErr("Singbox_RollbackFailed", fmt.Sprintf("sing-box failed to restart with the new configuration (%s) and the previous configuration could not be restored: %s", err.Msg(), rollbackErr.Msg()))
+	}
+
+	restartErr := singbox.Restart()
+	if restartErr == nil {
+		restartErr = singbox.WaitActive()
+	}
+
+	if restartErr != nil {
+		return apperr.NewFatalErr("Singbox_RollbackRestartFailed", fmt.Sprintf("sing-box failed to restart with the new configuration (%s), the previous configuration was restored but sing-box failed to restart with it: %s", err.Msg(), restartErr.Msg()))
+	}
+
+	return apperr.NewFatalErr("Singbox_RolledBack", fmt.Sprintf("sing-box failed to restart with the new configuration (%s), the previous configuration has been restored", err.Msg()))
+}
+
+func GetConfigHistory() ([]*config.SnapshotInfo, apperr.Err) {
+	return config.ListHistory()
+}
+
+func GetConfigSnapshot(id string) (*config.Snapshot, apperr.Err) {
+	return config.GetSnapshot(id)
+}
+
+func RestoreConfigSnapshot(id string, restart bool) apperr.Err {
+	s, err := config.GetSnapshot(id)
+	if err != nil {
+		return err
+	}
+
+	restored, err := s.Conf()
+	if err != nil {
+		return err
+	}
+
+	return updateConfig("config/restore "+id, restart, func(c *config.Conf) bool {
+		*c = *restored
+		return true
+	})
+}
@@ -0,0 +1,354 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config/dns"
+)
+
+const testConfig = `{
+    "log": {
+        "disabled": false,
+        "level": "info",
+        "output": "box.log",
+        "timestamp": true
+    },
+    "dns": {
+        "final": "dns-direct",
+        "rules": [],
+        "servers": []
+    },
+    "inbounds": [],
+    "outbounds": [
+        {
+            "type": "direct",
+            "tag": "direct"
+        },
+        {
+            "type": "socks",
+            "tag": "proxy"
+        },
+        {
+            "type": "socks",
+            "tag": "proxy-nl"
+        }
+    ],
+    "route": {
+        "auto_detect_interface": true,
+        "final": "direct",
+        "rules": []
+    }
+}
+`
+
+func writeScript(t *testing.T, dir, name, body string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+}
+
+// setupSingbox points the app at a temp config and fake sing-box tooling on PATH
+func setupSingbox(t *testing.T, activeState string, checkExitCode string) (configPath string) {
+	t.Helper()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("sing-box interaction is supported on linux only")
+	}
+
+	dir := t.TempDir()
+	configPath = filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(testConfig), 0o644))
+
+	bin := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(bin, 0o755))
+	writeScript(t, bin, "sudo", `"$@"`)
+	writeScript(t, bin, "systemctl", `[ "$1" = "is-active" ] && echo `+activeState+`; exit 0`)
+	writeScript(t, bin, "sing-box", `echo "check output"; exit `+checkExitCode)
+
+	t.Setenv("PATH", bin)
+	t.Setenv("CONFIG_PATH", configPath)
+	t.Setenv("CONFIG_HISTORY_LIMIT", "0")
+	t.Setenv("DISABLE_SINGBOX_INTERACTION", "false")
+	t.Setenv("SINGBOX_CHECK_CMD", "")
+	t.Setenv("SINGBOX_HEALTH_TIMEOUT", "100ms")
+	return configPath
+}
+
+func TestUpdateConfig_Applied(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	entries, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []DNSRuleEntry{{RouteMode: "proxy", Type: "full", Value: "google.com"}}, entries)
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), "google.com")
+}
+
+func TestUpdateConfig_CheckFailed(t *testing.T) {
+	path := setupSingbox(t, "active", "1")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, true, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "Singbox_ConfigCheckFailed", err.Code())
+	assert.Equal(t, "sing-box rejected the configuration: check output", err.Msg())
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, testConfig, string(saved))
+
+	candidates, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "config-candidate-*"))
+	assert.Empty(t, candidates)
+}
+
+// restartedActive makes sing-box active only after it is restarted the given number of times
+func restartedActive(t *testing.T, configPath string, restarts int) {
+	t.Helper()
+	writeScript(t, filepath.Join(filepath.Dir(configPath), "bin"), "systemctl", `n=0; [ -f "$0.restarts" ] && read n < "$0.restarts"
+case "$1" in
+	restart) echo $((n+1)) > "$0.restarts" ;;
+	is-active) [ "$n" -ge `+strconv.Itoa(restarts)+` ] && echo active || echo failed ;;
+esac
+exit 0`)
+}
+
+func TestUpdateConfig_RolledBack(t *testing.T) {
+	path := setupSingbox(t, "failed", "0")
+	restartedActive(t, path, 2)
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, true, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "Singbox_RolledBack", err.Code())
+	assert.Equal(t, "sing-box failed to restart with the new configuration (sing-box service is 'failed'), the previous configuration has been restored", err.Msg())
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, testConfig, string(saved))
+}
+
+func TestUpdateConfig_RollbackRestartFailed(t *testing.T) {
+	path := setupSingbox(t, "failed", "0")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, true, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "Singbox_RollbackRestartFailed", err.Code())
+	assert.Equal(t, "sing-box failed to restart with the new configuration (sing-box service is 'failed'), the previous configuration was restored but sing-box failed to restart with it: sing-box service is 'failed'", err.Msg())
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, testConfig, string(saved))
+}
+
+func TestUpdateConfig_RollbackConflict(t *testing.T) {
+	path := setupSingbox(t, "failed", "0")
+	bin := filepath.Join(filepath.Dir(path), "bin")
+
+	// Another request saves the configuration while sing-box is restarting
+	writeScript(t, bin, "systemctl", `[ "$1" = "restart" ] && echo >> "$CONFIG_PATH"
+[ "$1" = "is-active" ] && echo failed
+exit 0`)
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, true, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "Singbox_RollbackConflict", err.Code())
+	assert.Equal(t, apperr.Conflict, err.Kind())
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), "google.com")
+}
+
+func TestUpdateConfig_NoRestart(t *testing.T) {
+	path := setupSingbox(t, "failed", "0")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "google.com"}, false, false)
+	assert.Nil(t, err)
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), "google.com")
+}
+
+func TestAddDNSRule_Conflict(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "block", Domain: "domain:example.com"}, false, false)
+	require.Nil(t, err)
+
+	_, err = AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "api.example.com"}, false, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "DNSRule_Conflict", err.Code())
+	assert.Equal(t, "rule 'full:api.example.com' conflicts with the rules of other modes: domain:example.com (block); use move=true to move it to 'proxy'", err.Msg())
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.NotContains(t, string(saved), "api.example.com")
+
+	_, err = AddDNSRule(&DNSRule{RouteMode: "proxy", Domain: "api.example.com"}, false, true)
+	require.Nil(t, err)
+
+	saved, readErr = os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), "api.example.com")
+	assert.NotContains(t, string(saved), `"example.com"`)
+}
+
+func TestApplyRulesBatch_Conflict(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "direct", Domain: "yandex.ru"}, false, false)
+	require.Nil(t, err)
+
+	before, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+
+	batch := &RulesBatch{
+		DNS: []DNSRuleOp{{Op: "add", DNSRule: DNSRule{RouteMode: "proxy", Domain: "keyword:yandex"}}},
+		IP:  []IPRuleOp{{Op: "add", IPRule: IPRule{RouteMode: "proxy", IP: "8.8.8.8"}}},
+	}
+
+	result, err := ApplyRulesBatch(batch, false, false)
+	require.Nil(t, err)
+	assert.False(t, result.Applied)
+	assert.True(t, result.HasConflicts())
+	assert.Equal(t, BatchConflict, result.DNS[0].Status)
+	assert.Equal(t, "DNSRule_Conflict", result.DNS[0].Code)
+	assert.Equal(t, BatchValid, result.IP[0].Status)
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, string(before), string(saved))
+
+	result, err = ApplyRulesBatch(batch, false, true)
+	require.Nil(t, err)
+	assert.True(t, result.Applied)
+	assert.False(t, result.HasConflicts())
+
+	saved, readErr = os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.NotContains(t, string(saved), `"yandex.ru"`)
+	assert.Contains(t, string(saved), `"yandex"`)
+}
+
+func TestApplyRulesBatch_ItemsConflict(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	batch := &RulesBatch{
+		DNS: []DNSRuleOp{
+			{Op: "add", DNSRule: DNSRule{RouteMode: "direct", Domain: "yandex.ru"}},
+			{Op: "add", DNSRule: DNSRule{RouteMode: "proxy", Domain: "keyword:yandex"}},
+			{Op: "add", DNSRule: DNSRule{RouteMode: "direct", Domain: "domain:yandex.ru"}},
+		},
+	}
+
+	// Moving applies to the rules of the configuration, an item must not take the rule of an earlier one
+	for _, move := range []bool{false, true} {
+		result, err := ApplyRulesBatch(batch, false, move)
+		require.Nil(t, err)
+		assert.False(t, result.Applied)
+		assert.True(t, result.HasConflicts())
+		assert.Equal(t, BatchValid, result.DNS[0].Status)
+		assert.Equal(t, BatchItemResult{
+			Index:  1,
+			Status: BatchConflict,
+			Code:   "RulesBatch_ItemsConflict",
+			Error:  "rule 'keyword:yandex' conflicts with the rule 'full:yandex.ru' (direct) of the item 0 of the batch",
+			Rules:  []string{"keyword:yandex"},
+		}, result.DNS[1])
+		assert.Equal(t, BatchConflict, result.DNS[2].Status)
+		assert.Contains(t, result.DNS[2].Error, "of the item 1 of the batch")
+	}
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, testConfig, string(saved))
+}
+
+func TestMoveRules(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	_, err := AddDNSRule(&DNSRule{RouteMode: "direct", Domain: "example.com"}, false, false)
+	require.Nil(t, err)
+	_, err = AddIPRule(&IPRule{RouteMode: "block", IP: "10.0.0.0/24"}, false)
+	require.Nil(t, err)
+
+	dnsResult, err := MoveDNSRule(&DNSRule{RouteMode: "proxy", Domain: "example.com"}, false)
+	require.Nil(t, err)
+	assert.Equal(t, &RuleMoveResult[DNSRuleEntry]{From: []string{"direct"}, Rules: []DNSRuleEntry{{RouteMode: "proxy", Type: "full", Value: "example.com"}}}, dnsResult)
+
+	ipResult, err := MoveIPRule(&IPRule{RouteMode: "direct", IP: "10.0.0.0-10.0.0.255"}, false)
+	require.Nil(t, err)
+	assert.Equal(t, &RuleMoveResult[IPRuleEntry]{From: []string{"block"}, Rules: []IPRuleEntry{{RouteMode: "direct", IP: "10.0.0.0/24"}}}, ipResult)
+
+	dnsRules, err := ListDNSRules(&DNSRuleFilter{}, &Pagination{Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, []DNSRuleEntry{{RouteMode: "proxy", Type: "full", Value: "example.com"}}, dnsRules.Items)
+
+	ipRules, err := ListIPRules(&IPRuleFilter{}, &Pagination{Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, []IPRuleEntry{{RouteMode: "direct", IP: "10.0.0.0/24"}}, ipRules.Items)
+
+	before, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+
+	_, err = MoveDNSRule(&DNSRule{RouteMode: "proxy", Domain: "missing.com"}, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "DNSRule_NotFound", err.Code())
+
+	_, err = MoveIPRule(&IPRule{RouteMode: "proxy", IP: "10.0.0.0-10.0.1.255"}, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "IPRule_NotFound", err.Code())
+
+	after, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestOutboundTags(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+	t.Cleanup(func() { require.NoError(t, dns.LoadServers()) })
+	t.Setenv("DNS_SERVERS", "proxy-nl=dns-nl")
+	require.NoError(t, dns.LoadServers())
+
+	entries, err := AddDNSRule(&DNSRule{RouteMode: "proxy-nl", Domain: "openai.com"}, false, false)
+	require.Nil(t, err)
+	assert.Equal(t, []DNSRuleEntry{{RouteMode: "proxy-nl", Type: "full", Value: "openai.com"}}, entries)
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), `"outbound": "proxy-nl"`)
+	assert.Contains(t, string(saved), `"server": "dns-nl"`)
+
+	_, err = AddDNSRule(&DNSRule{RouteMode: "warp", Domain: "chatgpt.com"}, false, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "DNSRule_UnknownOutbound", err.Code())
+	assert.Equal(t, "outbound 'warp' does not exist", err.Msg())
+
+	_, err = AddIPRule(&IPRule{RouteMode: "warp", IP: "1.1.1.1"}, false)
+	require.NotNil(t, err)
+	assert.Equal(t, "IPRule_UnknownOutbound", err.Code())
+
+	batch := &RulesBatch{
+		DNS: []DNSRuleOp{{Op: "add", DNSRule: DNSRule{RouteMode: "proxy-nl", Domain: "chatgpt.com"}}},
+		IP:  []IPRuleOp{{Op: "add", IPRule: IPRule{RouteMode: "warp", IP: "1.1.1.1"}}},
+	}
+
+	result, err := ApplyRulesBatch(batch, false, false)
+	require.Nil(t, err)
+	assert.False(t, result.Applied)
+	assert.False(t, result.HasConflicts())
+	assert.Equal(t, BatchValid, result.DNS[0].Status)
+	assert.Equal(t, BatchInvalid, result.IP[0].Status)
+	assert.Equal(t, "IPRule_UnknownOutbound", result.IP[0].Code)
+
+	after, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, string(saved), string(after))
+}
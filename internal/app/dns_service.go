@@ -2,25 +2,51 @@ package app
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/traf72/singbox-api/internal/apperr"
-	"github.com/traf72/singbox-api/internal/singbox"
 	"github.com/traf72/singbox-api/internal/singbox/config"
 	"github.com/traf72/singbox-api/internal/singbox/config/dns"
 )
 
 var (
-	errDNSEmptyRule = apperr.NewValidationErr("DNSRule_Empty", "DNS rule is empty")
-	errDNSEmptyType = apperr.NewValidationErr("DNSRule_EmptyType", "DNS rule type is empty")
+	errDNSEmptyRule = apperr.NewFieldValidationErr("DNSRule_Empty", "domain", "DNS rule is empty")
+	errDNSEmptyType = apperr.NewFieldValidationErr("DNSRule_EmptyType", "domain", "DNS rule type is empty")
 )
 
 func errDNSUnknownType(t string) apperr.Err {
-	return apperr.NewValidationErr("DNSRule_UnknownType", fmt.Sprintf("DNS rule type '%s' is unknown", t))
+	return apperr.NewFieldValidationErr("DNSRule_UnknownType", "domain", fmt.Sprintf("DNS rule type '%s' is unknown", t))
 }
 
 func errDNSTooManyParts(t string) apperr.Err {
-	return apperr.NewValidationErr("DNSRule_TooManyParts", fmt.Sprintf("DNS rule '%s' has too many parts", t))
+	return apperr.NewFieldValidationErr("DNSRule_TooManyParts", "domain", fmt.Sprintf("DNS rule '%s' has too many parts", t))
+}
+
+func errDNSInvalidURL(u string) apperr.Err {
+	return apperr.NewFieldValidationErr("DNSRule_InvalidURL", "domain", fmt.Sprintf("URL '%s' is invalid or has no host", u))
+}
+
+func errDNSInvalidWildcard(d string) apperr.Err {
+	return apperr.NewFieldValidationErr("DNSRule_InvalidWildcard", "domain", fmt.Sprintf("wildcard '%s' is invalid, only a leading '*.' is supported", d))
+}
+
+func errDNSConflict(r *dns.Rule, conflicts []*dns.Rule) apperr.Err {
+	names := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		names = append(names, fmt.Sprintf("%s:%s (%s)", dnsRuleTypeName(c.Kind()), c.Domain(), c.Mode()))
+	}
+
+	return apperr.NewConflictErr("DNSRule_Conflict", fmt.Sprintf("rule '%s:%s' conflicts with the rules of other modes: %s; use move=true to move it to '%s'",
+		dnsRuleTypeName(r.Kind()), r.Domain(), strings.Join(names, ", "), r.Mode()))
+}
+
+func errDNSUnknownOutbound(err error) apperr.Err {
+	return apperr.NewFieldValidationErr("DNSRule_UnknownOutbound", "routeMode", err.Error())
+}
+
+func errDNSRuleNotFound(r *dns.Rule) apperr.Err {
+	return apperr.NewNotFoundErr("DNSRule_NotFound", fmt.Sprintf("rule '%s:%s' is not found in any route mode", dnsRuleTypeName(r.Kind()), r.Domain()))
 }
 
 type DNSRule struct {
@@ -35,7 +61,23 @@ func (r *DNSRule) toConfigRule() (*dns.Rule, apperr.Err) {
 
 	routeMode, err := config.RouteModeFromString(r.RouteMode)
 	if err != nil {
-		return nil, apperr.NewValidationErr("DNSRule_InvalidRouteMode", err.Error())
+		return nil, apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", err.Error())
+	}
+
+	if domain := strings.TrimSpace(r.Domain); strings.Contains(domain, "://") {
+		u, err := url.Parse(domain)
+		if err != nil || u.Hostname() == "" {
+			return nil, errDNSInvalidURL(domain)
+		}
+
+		return dns.NewRule(dns.Domain, routeMode, strings.TrimSuffix(u.Hostname(), "."))
+	} else if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+		if strings.Contains(suffix, "*") {
+			return nil, errDNSInvalidWildcard(domain)
+		}
+
+		// A suffix with the leading dot matches the subdomains only, as the wildcard does
+		return dns.NewRule(dns.Suffix, routeMode, "."+suffix)
 	}
 
 	parts := strings.Split(r.Domain, ":")
@@ -87,23 +129,138 @@ func parseDNSRuleType(input string) (dns.RuleType, apperr.Err) {
 	}
 }
 
-func AddDNSRule(r *DNSRule, restart bool) apperr.Err {
-	rule, err := r.toConfigRule()
+func dnsRuleTypeName(t dns.RuleType) string {
+	switch t {
+	case dns.Domain:
+		return "full"
+	case dns.Keyword:
+		return "keyword"
+	case dns.Suffix:
+		return "domain"
+	case dns.Regex:
+		return "regexp"
+	default:
+		return ""
+	}
+}
+
+type DNSRuleFilter struct {
+	RouteMode string
+	Type      string
+	Search    string
+}
+
+type DNSRuleEntry struct {
+	RouteMode string `json:"routeMode"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	// Unicode is the internationalized domain the punycode value stands for
+	Unicode string `json:"unicode,omitempty"`
+}
+
+func (f *DNSRuleFilter) toPredicate() (func(*dns.Rule) bool, apperr.Err) {
+	var mode config.RouteMode
+	if strings.TrimSpace(f.RouteMode) != "" {
+		m, err := config.RouteModeFromString(f.RouteMode)
+		if err != nil {
+			return nil, apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", err.Error())
+		}
+
+		mode = m
+	}
+
+	ruleType := dns.RuleType(-1)
+	if strings.TrimSpace(f.Type) != "" {
+		t, err := parseDNSRuleType(f.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		ruleType = t
+	}
+
+	search := strings.ToLower(strings.TrimSpace(f.Search))
+
+	return func(r *dns.Rule) bool {
+		if mode != "" && r.Mode() != mode {
+			return false
+		}
+
+		if ruleType != -1 && r.Kind() != ruleType {
+			return false
+		}
+
+		return strings.Contains(r.Domain(), search) || strings.Contains(r.Unicode(), search)
+	}, nil
+}
+
+func ListDNSRules(f *DNSRuleFilter, p *Pagination) (*Page[DNSRuleEntry], apperr.Err) {
+	match, err := f.toPredicate()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = dns.AddRule(rule); err != nil {
-		return err
+	if err := p.validate(); err != nil {
+		return nil, err
 	}
 
-	if restart {
-		if err = singbox.Restart(); err != nil {
-			return err
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []DNSRuleEntry{}
+	for _, r := range dns.List(c.Conf) {
+		if match(r) {
+			entries = append(entries, newDNSRuleEntry(r))
 		}
 	}
 
-	return nil
+	return paginate(entries, p), nil
+}
+
+func newDNSRuleEntry(r *dns.Rule) DNSRuleEntry {
+	entry := DNSRuleEntry{RouteMode: string(r.Mode()), Type: dnsRuleTypeName(r.Kind()), Value: r.Domain()}
+	if u := r.Unicode(); u != r.Domain() {
+		entry.Unicode = u
+	}
+
+	return entry
+}
+
+// addDNSRule refuses to add a rule routing some domains to another mode than the existing rules do,
+// unless move is set, then the conflicting rules are removed
+func addDNSRule(c *config.Conf, r *dns.Rule, move bool) (bool, apperr.Err) {
+	if err := r.Mode().ValidateIn(c); err != nil {
+		return false, errDNSUnknownOutbound(err)
+	}
+
+	if move {
+		return dns.AddReplacing(c, r), nil
+	}
+
+	if conflicts := dns.Conflicts(c, r); len(conflicts) > 0 {
+		return false, errDNSConflict(r, conflicts)
+	}
+
+	return dns.Add(c, r), nil
+}
+
+// AddDNSRule returns the rule that has been produced from the input, e.g. a URL becomes the full domain of its host
+func AddDNSRule(r *DNSRule, restart bool, move bool) ([]DNSRuleEntry, apperr.Err) {
+	rule, err := r.toConfigRule()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tryUpdateConfig("dns-rules/add", restart, func(c *config.Conf) (bool, apperr.Err) {
+		return addDNSRule(c, rule, move)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []DNSRuleEntry{newDNSRuleEntry(rule)}, nil
 }
 
 func RemoveDNSRule(r *DNSRule, restart bool) apperr.Err {
@@ -112,15 +269,35 @@ func RemoveDNSRule(r *DNSRule, restart bool) apperr.Err {
 		return err
 	}
 
-	if err = dns.RemoveRule(rule); err != nil {
-		return err
+	return updateConfig("dns-rules/remove", restart, func(c *config.Conf) bool {
+		return dns.Remove(c, rule)
+	})
+}
+
+// MoveDNSRule relocates an existing rule to the route mode of the request in a single configuration update
+func MoveDNSRule(r *DNSRule, restart bool) (*RuleMoveResult[DNSRuleEntry], apperr.Err) {
+	rule, err := r.toConfigRule()
+	if err != nil {
+		return nil, err
 	}
 
-	if restart {
-		if err = singbox.Restart(); err != nil {
-			return err
+	result := &RuleMoveResult[DNSRuleEntry]{From: []string{}, Rules: []DNSRuleEntry{newDNSRuleEntry(rule)}}
+	err = tryUpdateConfig("dns-rules/move", restart, func(c *config.Conf) (bool, apperr.Err) {
+		if err := rule.Mode().ValidateIn(c); err != nil {
+			return false, errDNSUnknownOutbound(err)
 		}
+
+		from, found, changed := dns.Move(c, rule)
+		if !found {
+			return false, errDNSRuleNotFound(rule)
+		}
+
+		result.addFrom(from)
+		return changed, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return result, nil
 }
@@ -90,6 +90,60 @@ func Test_DNSRuleToConfigRule(t *testing.T) {
 			}(),
 			expectedErr: nil,
 		},
+		{
+			name: "IDN_Full",
+			rule: &DNSRule{Domain: "full:Пример.рф", RouteMode: "direct"},
+			expected: func() *dns.Rule {
+				t, _ := dns.NewRule(dns.Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai")
+				return t
+			}(),
+			expectedErr: nil,
+		},
+		{
+			name: "IDN_URL",
+			rule: &DNSRule{Domain: "https://пример.рф/путь", RouteMode: "direct"},
+			expected: func() *dns.Rule {
+				t, _ := dns.NewRule(dns.Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai")
+				return t
+			}(),
+			expectedErr: nil,
+		},
+		{
+			name: "URL_HostAsDomain",
+			rule: &DNSRule{Domain: " https://Foo.Example.com:8443/path?q=1 ", RouteMode: "proxy"},
+			expected: func() *dns.Rule {
+				t, _ := dns.NewRule(dns.Domain, config.RouteProxy, "foo.example.com")
+				return t
+			}(),
+			expectedErr: nil,
+		},
+		{
+			name:        "URL_NoHost",
+			rule:        &DNSRule{Domain: "file:///etc/hosts", RouteMode: "proxy"},
+			expected:    nil,
+			expectedErr: errDNSInvalidURL("file:///etc/hosts"),
+		},
+		{
+			name:        "URL_InvalidHost",
+			rule:        &DNSRule{Domain: "http://1.2.3.4/", RouteMode: "proxy"},
+			expected:    nil,
+			expectedErr: apperr.NewFieldValidationErr("DNSRule_InvalidDomain", "domain", "domain '1.2.3.4' is invalid"),
+		},
+		{
+			name: "Wildcard_Suffix",
+			rule: &DNSRule{Domain: "*.Example.com", RouteMode: "direct"},
+			expected: func() *dns.Rule {
+				t, _ := dns.NewRule(dns.Suffix, config.RouteDirect, ".example.com")
+				return t
+			}(),
+			expectedErr: nil,
+		},
+		{
+			name:        "Wildcard_Inner",
+			rule:        &DNSRule{Domain: "*.*.example.com", RouteMode: "direct"},
+			expected:    nil,
+			expectedErr: errDNSInvalidWildcard("*.*.example.com"),
+		},
 		{
 			name:        "Rule_Empty",
 			rule:        &DNSRule{Domain: "", RouteMode: "direct"},
@@ -130,25 +184,25 @@ func Test_DNSRuleToConfigRule(t *testing.T) {
 			name:        "RouteMode_Empty",
 			rule:        &DNSRule{Domain: "domain:google.com", RouteMode: ""},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("DNSRule_InvalidRouteMode", "route mode is empty"),
+			expectedErr: apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "route mode is empty"),
 		},
 		{
 			name:        "RouteMode_SpaceOnly",
 			rule:        &DNSRule{Domain: "domain:google.com", RouteMode: "\r\n\t "},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("DNSRule_InvalidRouteMode", "route mode is empty"),
+			expectedErr: apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "route mode is empty"),
 		},
 		{
-			name:        "RouteMode_Unknown",
-			rule:        &DNSRule{Domain: "domain:google.com", RouteMode: "bad"},
+			name:        "RouteMode_Invalid",
+			rule:        &DNSRule{Domain: "domain:google.com", RouteMode: "proxy nl"},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("DNSRule_InvalidRouteMode", "route mode 'bad' is unknown"),
+			expectedErr: apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "route mode 'proxy nl' is not a valid outbound tag"),
 		},
 		{
 			name:        "Domain_Empty",
 			rule:        &DNSRule{Domain: "domain:", RouteMode: "direct"},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("DNSRule_EmptyDomain", "domain is empty"),
+			expectedErr: apperr.NewFieldValidationErr("DNSRule_EmptyDomain", "domain", "domain is empty"),
 		},
 	}
 
@@ -160,3 +214,49 @@ func Test_DNSRuleToConfigRule(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSRuleFilter_ToPredicate(t *testing.T) {
+	rule := func(kind dns.RuleType, mode config.RouteMode, domain string) *dns.Rule {
+		r, _ := dns.NewRule(kind, mode, domain)
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		filter      DNSRuleFilter
+		rule        *dns.Rule
+		expected    bool
+		expectedErr apperr.Err
+	}{
+		{"NoFilter", DNSRuleFilter{}, rule(dns.Domain, config.RouteProxy, "google.com"), true, nil},
+		{"Mode_Match", DNSRuleFilter{RouteMode: "PROXY"}, rule(dns.Domain, config.RouteProxy, "google.com"), true, nil},
+		{"Mode_NoMatch", DNSRuleFilter{RouteMode: "direct"}, rule(dns.Domain, config.RouteProxy, "google.com"), false, nil},
+		{"Type_Match", DNSRuleFilter{Type: "domain"}, rule(dns.Suffix, config.RouteProxy, "google.com"), true, nil},
+		{"Type_NoMatch", DNSRuleFilter{Type: "full"}, rule(dns.Suffix, config.RouteProxy, "google.com"), false, nil},
+		{"Search_Match", DNSRuleFilter{Search: " GOOG "}, rule(dns.Keyword, config.RouteBlock, "google"), true, nil},
+		{"Search_NoMatch", DNSRuleFilter{Search: "yandex"}, rule(dns.Keyword, config.RouteBlock, "google"), false, nil},
+		{"Search_IDN_Unicode", DNSRuleFilter{Search: "ПРИМЕР"}, rule(dns.Domain, config.RouteDirect, "пример.рф"), true, nil},
+		{"Search_IDN_Punycode", DNSRuleFilter{Search: "xn--e1afmkfd"}, rule(dns.Domain, config.RouteDirect, "пример.рф"), true, nil},
+		{"All_Match", DNSRuleFilter{RouteMode: "block", Type: "keyword", Search: "oo"}, rule(dns.Keyword, config.RouteBlock, "google"), true, nil},
+		{"Mode_Invalid", DNSRuleFilter{RouteMode: "proxy nl"}, nil, false, apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "route mode 'proxy nl' is not a valid outbound tag")},
+		{"Type_Invalid", DNSRuleFilter{Type: "bad"}, nil, false, errDNSUnknownType("bad")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.filter.toPredicate()
+			assert.Equal(t, tt.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, tt.expected, match(tt.rule))
+			}
+		})
+	}
+}
+
+func TestNewDNSRuleEntry(t *testing.T) {
+	idn, _ := dns.NewRule(dns.Domain, config.RouteDirect, "пример.рф")
+	assert.Equal(t, DNSRuleEntry{RouteMode: "direct", Type: "full", Value: "xn--e1afmkfd.xn--p1ai", Unicode: "пример.рф"}, newDNSRuleEntry(idn))
+
+	ascii, _ := dns.NewRule(dns.Suffix, config.RouteProxy, "google.com")
+	assert.Equal(t, DNSRuleEntry{RouteMode: "proxy", Type: "domain", Value: "google.com"}, newDNSRuleEntry(ascii))
+}
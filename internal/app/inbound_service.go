@@ -0,0 +1,47 @@
+package app
+
+import (
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/inbound"
+)
+
+type InboundShare struct {
+	Tag  string `json:"tag"`
+	Link string `json:"link"`
+}
+
+// ShareInbound makes the link the clients connect to the inbound with as the user, the first one when user is empty.
+// host is the address the clients reach the server at, it may be omitted when the inbound listens on a public one.
+func ShareInbound(tag, host, user string) (*InboundShare, apperr.Err) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := inbound.Get(c.Conf, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := inbound.Link(in, host, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InboundShare{Tag: in.Tag, Link: link}, nil
+}
+
+// ShareInboundQR encodes the share link as a square PNG QR code of the size in pixels
+func ShareInboundQR(tag, host, user string, size int) ([]byte, apperr.Err) {
+	if size < minQRSize || size > maxQRSize {
+		return nil, errQRSize(size)
+	}
+
+	share, err := ShareInbound(tag, host, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeQR(share.Link, size)
+}
@@ -1,75 +1,234 @@
 package app
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/traf72/singbox-api/internal/apperr"
-	"github.com/traf72/singbox-api/internal/singbox"
 	"github.com/traf72/singbox-api/internal/singbox/config"
 	"github.com/traf72/singbox-api/internal/singbox/config/ip"
 )
 
 var (
-	errIPEmptyRule = apperr.NewValidationErr("IPRule_Empty", "IP is empty")
+	errIPEmptyRule = apperr.NewFieldValidationErr("IPRule_Empty", "ip", "IP is empty")
 )
 
+func errIPUnknownOutbound(err error) apperr.Err {
+	return apperr.NewFieldValidationErr("IPRule_UnknownOutbound", "routeMode", err.Error())
+}
+
+func errIPRuleNotFound(r *ip.Rule) apperr.Err {
+	return apperr.NewNotFoundErr("IPRule_NotFound", fmt.Sprintf("IP '%s' is not found in any route mode", r.IP()))
+}
+
 type IPRule struct {
 	RouteMode string `json:"routeMode"`
 	IP        string `json:"ip"`
 }
 
-func (r *IPRule) toConfigRule() (*ip.Rule, apperr.Err) {
+func (r *IPRule) toConfigRules() ([]*ip.Rule, apperr.Err) {
 	if strings.TrimSpace(r.IP) == "" {
 		return nil, errIPEmptyRule
 	}
 
 	routeMode, err := config.RouteModeFromString(r.RouteMode)
 	if err != nil {
-		return nil, apperr.NewValidationErr("IPRule_InvalidRouteMode", err.Error())
+		return nil, apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", err.Error())
 	}
 
-	rule, appErr := ip.NewRule(routeMode, r.IP)
+	rules, appErr := ip.NewRules(routeMode, r.IP)
 	if appErr != nil {
 		return nil, appErr
 	}
 
-	return rule, nil
+	return rules, nil
+}
+
+type IPRuleFilter struct {
+	RouteMode string
+	Search    string
+}
+
+type IPRuleEntry struct {
+	RouteMode string `json:"routeMode"`
+	IP        string `json:"ip"`
+}
+
+func (f *IPRuleFilter) toPredicate() (func(*ip.Rule) bool, apperr.Err) {
+	var mode config.RouteMode
+	if strings.TrimSpace(f.RouteMode) != "" {
+		m, err := config.RouteModeFromString(f.RouteMode)
+		if err != nil {
+			return nil, apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", err.Error())
+		}
+
+		mode = m
+	}
+
+	// IPv6 rules are listed in lowercase
+	search := strings.ToLower(strings.TrimSpace(f.Search))
+
+	return func(r *ip.Rule) bool {
+		if mode != "" && r.Mode() != mode {
+			return false
+		}
+
+		return strings.Contains(r.IP(), search)
+	}, nil
 }
 
-func AddIPRule(r *IPRule, restart bool) apperr.Err {
-	rule, err := r.toConfigRule()
+func ListIPRules(f *IPRuleFilter, p *Pagination) (*Page[IPRuleEntry], apperr.Err) {
+	match, err := f.toPredicate()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = ip.AddRule(rule); err != nil {
-		return err
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
 	}
 
-	if restart {
-		if err = singbox.Restart(); err != nil {
-			return err
+	entries := []IPRuleEntry{}
+	for _, r := range ip.List(c.Conf) {
+		if match(r) {
+			entries = append(entries, newIPRuleEntry(r))
+		}
+	}
+
+	return paginate(entries, p), nil
+}
+
+func newIPRuleEntry(r *ip.Rule) IPRuleEntry {
+	return IPRuleEntry{RouteMode: string(r.Mode()), IP: r.IP()}
+}
+
+func validateIPRules(c *config.Conf, rules []*ip.Rule) apperr.Err {
+	for _, r := range rules {
+		if err := r.Mode().ValidateIn(c); err != nil {
+			return errIPUnknownOutbound(err)
 		}
 	}
 
 	return nil
 }
 
+func addIPRules(c *config.Conf, rules []*ip.Rule) (added bool, err apperr.Err) {
+	if err := validateIPRules(c, rules); err != nil {
+		return false, err
+	}
+
+	for _, r := range rules {
+		added = ip.Add(c, r) || added
+	}
+
+	return added, nil
+}
+
+func removeIPRules(c *config.Conf, rules []*ip.Rule) (removed bool) {
+	for _, r := range rules {
+		removed = ip.Remove(c, r) || removed
+	}
+
+	return removed
+}
+
+// AddIPRule returns the rules that have been produced from the input, a range of addresses becomes several prefixes
+func AddIPRule(r *IPRule, restart bool) ([]IPRuleEntry, apperr.Err) {
+	rules, err := r.toConfigRules()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tryUpdateConfig("ip-rules/add", restart, func(c *config.Conf) (bool, apperr.Err) {
+		return addIPRules(c, rules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]IPRuleEntry, 0, len(rules))
+	for _, rule := range rules {
+		entries = append(entries, newIPRuleEntry(rule))
+	}
+
+	return entries, nil
+}
+
 func RemoveIPRule(r *IPRule, restart bool) apperr.Err {
-	rule, err := r.toConfigRule()
+	rules, err := r.toConfigRules()
 	if err != nil {
 		return err
 	}
 
-	if err = ip.RemoveRule(rule); err != nil {
-		return err
+	return updateConfig("ip-rules/remove", restart, func(c *config.Conf) bool {
+		return removeIPRules(c, rules)
+	})
+}
+
+func AnalyzeIPRules() (*ip.Analysis, apperr.Err) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return ip.Analyze(c.Conf), nil
+}
+
+// AggregateIPRules rewrites every IP list of the route rules into the minimal equivalent set of prefixes
+func AggregateIPRules(restart bool) ([]ip.Aggregation, apperr.Err) {
+	result := []ip.Aggregation{}
+	err := updateConfig("ip-rules/aggregate", restart, func(c *config.Conf) bool {
+		if changes := ip.Aggregate(c); changes != nil {
+			result = changes
+		}
+
+		return len(result) > 0
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	if restart {
-		if err = singbox.Restart(); err != nil {
-			return err
+	return result, nil
+}
+
+// MoveIPRule relocates existing IPs to the route mode of the request in a single configuration update,
+// every prefix of a range has to exist
+func MoveIPRule(r *IPRule, restart bool) (*RuleMoveResult[IPRuleEntry], apperr.Err) {
+	rules, err := r.toConfigRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RuleMoveResult[IPRuleEntry]{From: []string{}, Rules: make([]IPRuleEntry, 0, len(rules))}
+	for _, rule := range rules {
+		result.Rules = append(result.Rules, newIPRuleEntry(rule))
+	}
+
+	err = tryUpdateConfig("ip-rules/move", restart, func(c *config.Conf) (changed bool, err apperr.Err) {
+		if err := validateIPRules(c, rules); err != nil {
+			return false, err
 		}
+
+		for _, rule := range rules {
+			from, found, moved := ip.Move(c, rule)
+			if !found {
+				return false, errIPRuleNotFound(rule)
+			}
+
+			result.addFrom(from)
+			changed = changed || moved
+		}
+
+		return changed, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return result, nil
 }
@@ -9,18 +9,18 @@ import (
 	"github.com/traf72/singbox-api/internal/singbox/config/ip"
 )
 
-func Test_IPRuleToConfigRule(t *testing.T) {
+func Test_IPRuleToConfigRules(t *testing.T) {
 	tests := []struct {
 		name        string
 		rule        *IPRule
-		expected    *ip.Rule
+		expected    []*ip.Rule
 		expectedErr apperr.Err
 	}{
 		{
 			name: "IP_Proxy_TrimSpace_LowerCase",
 			rule: &IPRule{IP: "\t 142.250.0.0\r\n", RouteMode: "\t PROXY\n"},
-			expected: func() *ip.Rule {
-				t, _ := ip.NewRule(config.RouteProxy, "142.250.0.0")
+			expected: func() []*ip.Rule {
+				t, _ := ip.NewRules(config.RouteProxy, "142.250.0.0")
 				return t
 			}(),
 			expectedErr: nil,
@@ -28,8 +28,8 @@ func Test_IPRuleToConfigRule(t *testing.T) {
 		{
 			name: "IP_Direct",
 			rule: &IPRule{IP: "142.250.0.0", RouteMode: "direct"},
-			expected: func() *ip.Rule {
-				t, _ := ip.NewRule(config.RouteDirect, "142.250.0.0")
+			expected: func() []*ip.Rule {
+				t, _ := ip.NewRules(config.RouteDirect, "142.250.0.0")
 				return t
 			}(),
 			expectedErr: nil,
@@ -37,12 +37,28 @@ func Test_IPRuleToConfigRule(t *testing.T) {
 		{
 			name: "IP_Block",
 			rule: &IPRule{IP: "142.250.0.0", RouteMode: "block"},
-			expected: func() *ip.Rule {
-				t, _ := ip.NewRule(config.RouteBlock, "142.250.0.0")
+			expected: func() []*ip.Rule {
+				t, _ := ip.NewRules(config.RouteBlock, "142.250.0.0")
 				return t
 			}(),
 			expectedErr: nil,
 		},
+		{
+			name: "IPRange_Direct",
+			rule: &IPRule{IP: " 10.0.0.0-10.0.2.255 ", RouteMode: "direct"},
+			expected: func() []*ip.Rule {
+				t1, _ := ip.NewRule(config.RouteDirect, "10.0.0.0/23")
+				t2, _ := ip.NewRule(config.RouteDirect, "10.0.2.0/24")
+				return []*ip.Rule{t1, t2}
+			}(),
+			expectedErr: nil,
+		},
+		{
+			name:        "IPRange_Invalid",
+			rule:        &IPRule{IP: "10.0.0.9-10.0.0.1", RouteMode: "direct"},
+			expected:    nil,
+			expectedErr: apperr.NewFieldValidationErr("IPRule_InvalidRange", "ip", "IP range '10.0.0.9-10.0.0.1' is invalid, expected 'first-last' addresses of the same family"),
+		},
 		{
 			name:        "IP_Empty",
 			rule:        &IPRule{IP: "", RouteMode: "direct"},
@@ -59,27 +75,60 @@ func Test_IPRuleToConfigRule(t *testing.T) {
 			name:        "RouteMode_Empty",
 			rule:        &IPRule{IP: "domain:google.com", RouteMode: ""},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("IPRule_InvalidRouteMode", "route mode is empty"),
+			expectedErr: apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "route mode is empty"),
 		},
 		{
 			name:        "RouteMode_SpaceOnly",
 			rule:        &IPRule{IP: "domain:google.com", RouteMode: "\r\n\t "},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("IPRule_InvalidRouteMode", "route mode is empty"),
+			expectedErr: apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "route mode is empty"),
 		},
 		{
-			name:        "RouteMode_Unknown",
-			rule:        &IPRule{IP: "domain:google.com", RouteMode: "bad"},
+			name:        "RouteMode_Invalid",
+			rule:        &IPRule{IP: "domain:google.com", RouteMode: "proxy nl"},
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("IPRule_InvalidRouteMode", "route mode 'bad' is unknown"),
+			expectedErr: apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "route mode 'proxy nl' is not a valid outbound tag"),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r, err := tt.rule.toConfigRule()
+			r, err := tt.rule.toConfigRules()
 			assert.Equal(t, tt.expected, r)
 			assert.Equal(t, tt.expectedErr, err)
 		})
 	}
 }
+
+func TestIPRuleFilter_ToPredicate(t *testing.T) {
+	rule := func(mode config.RouteMode, value string) *ip.Rule {
+		r, _ := ip.NewRule(mode, value)
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		filter      IPRuleFilter
+		rule        *ip.Rule
+		expected    bool
+		expectedErr apperr.Err
+	}{
+		{"NoFilter", IPRuleFilter{}, rule(config.RouteProxy, "142.250.0.0/15"), true, nil},
+		{"Mode_Match", IPRuleFilter{RouteMode: "Proxy"}, rule(config.RouteProxy, "142.250.0.0/15"), true, nil},
+		{"Mode_NoMatch", IPRuleFilter{RouteMode: "block"}, rule(config.RouteProxy, "142.250.0.0/15"), false, nil},
+		{"Search_Match", IPRuleFilter{Search: "142.250"}, rule(config.RouteDirect, "142.250.0.0/15"), true, nil},
+		{"Search_IPv6_CaseInsensitive", IPRuleFilter{Search: "2001:DB8"}, rule(config.RouteDirect, "2001:db8::/32"), true, nil},
+		{"Search_NoMatch", IPRuleFilter{Search: "10."}, rule(config.RouteDirect, "142.250.0.0/15"), false, nil},
+		{"Mode_Invalid", IPRuleFilter{RouteMode: "proxy nl"}, nil, false, apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "route mode 'proxy nl' is not a valid outbound tag")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.filter.toPredicate()
+			assert.Equal(t, tt.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, tt.expected, match(tt.rule))
+			}
+		})
+	}
+}
@@ -1,49 +1,204 @@
 package app
 
 import (
+	"fmt"
 	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/traf72/singbox-api/internal/apperr"
 	"github.com/traf72/singbox-api/internal/singbox"
 	"github.com/traf72/singbox-api/internal/singbox/config"
 )
 
-func GetLog() (io.ReadCloser, apperr.Err) {
-	return singbox.GetLog()
+const MaxLogStreamTail = 1000
+
+func errLogStreamInvalidTail(n int) apperr.Err {
+	return apperr.NewFieldValidationErr("LogStream_InvalidTail", "tail", fmt.Sprintf("tail '%d' is invalid, expected a value between 0 and %d", n, MaxLogStreamTail))
 }
 
-func EnableLog(restart bool, truncate bool, level string) apperr.Err {
-	if truncate {
-		if err := TruncateLog(); err != nil {
-			return err
+type LogStreamFilter struct {
+	Level  string
+	Search string
+	Regex  string
+	Tail   int
+}
+
+func (f *LogStreamFilter) toLogFilter() (singbox.LogFilter, apperr.Err) {
+	filter := singbox.LogFilter{Search: f.Search}
+
+	if f.Level != "" {
+		l := config.LogLevel(f.Level)
+		if err := l.Validate(); err != nil {
+			return filter, err
 		}
+
+		filter.MinLevel = l.String()
 	}
 
-	if err := config.EnableLog(config.LogLevel(level)); err != nil {
-		return err
+	if f.Regex != "" {
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return filter, apperr.NewFieldValidationErr("LogStream_InvalidRegex", "regex", fmt.Sprintf("regex '%s' is invalid: %s", f.Regex, err))
+		}
+
+		filter.Regex = re
 	}
 
-	if restart {
-		if err := singbox.Restart(); err != nil {
-			return err
+	return filter, nil
+}
+
+func OpenLogStream(f *LogStreamFilter) (*singbox.LogStream, apperr.Err) {
+	if f.Tail < 0 || f.Tail > MaxLogStreamTail {
+		return nil, errLogStreamInvalidTail(f.Tail)
+	}
+
+	filter, err := f.toLogFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	return singbox.NewLogStream(filter, f.Tail)
+}
+
+func errLogEntriesInvalidTime(field, value string) apperr.Err {
+	return apperr.NewFieldValidationErr("LogEntries_InvalidTime", field, fmt.Sprintf("%s '%s' is invalid, expected an RFC 3339 time", field, value))
+}
+
+type LogEntriesFilter struct {
+	From     string
+	To       string
+	Level    string
+	Outbound string
+	Domain   string
+}
+
+func parseFilterTime(field, value string) (time.Time, apperr.Err) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, errLogEntriesInvalidTime(field, value)
+	}
+
+	return t, nil
+}
+
+func (f *LogEntriesFilter) toPredicate() (func(*singbox.LogRecord) bool, apperr.Err) {
+	from, err := parseFilterTime("from", f.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseFilterTime("to", f.To)
+	if err != nil {
+		return nil, err
+	}
+
+	minLevel := -1
+	if f.Level != "" {
+		l := config.LogLevel(f.Level)
+		if err := l.Validate(); err != nil {
+			return nil, err
 		}
+
+		minLevel = singbox.LogLevelIndex(l.String())
 	}
 
-	return nil
+	outbound := strings.TrimSpace(f.Outbound)
+	domain := strings.ToLower(strings.Trim(strings.TrimSpace(f.Domain), "."))
+
+	return func(r *singbox.LogRecord) bool {
+		if (!from.IsZero() || !to.IsZero()) && r.Time == nil {
+			return false
+		}
+
+		if !from.IsZero() && r.Time.Before(from) {
+			return false
+		}
+
+		if !to.IsZero() && r.Time.After(to) {
+			return false
+		}
+
+		if singbox.LogLevelIndex(r.Level) < minLevel {
+			return false
+		}
+
+		if outbound != "" && r.Outbound != outbound {
+			return false
+		}
+
+		if domain != "" {
+			host := strings.ToLower(r.Host)
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
 }
 
-func DisableLog(restart bool) apperr.Err {
-	if err := config.DisableLog(); err != nil {
-		return err
+// ListLogEntries returns the parsed log records, the newest first
+func ListLogEntries(f *LogEntriesFilter, p *Pagination) (*Page[*singbox.LogRecord], apperr.Err) {
+	match, err := f.toPredicate()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	// Only the records up to the end of the page are read, the newest ones are at the end of the file
+	records, total, err := singbox.ReadLogRecords(match, p.Offset+p.Limit)
+	if err == singbox.ErrLogNotFound {
+		records, total = nil, 0
+	} else if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(records)
+	page := &Page[*singbox.LogRecord]{Total: total, Offset: p.Offset, Limit: p.Limit, Items: []*singbox.LogRecord{}}
+	if p.Offset < len(records) {
+		page.Items = records[p.Offset:]
 	}
 
-	if restart {
-		if err := singbox.Restart(); err != nil {
+	return page, nil
+}
+
+func GetLog() (io.ReadCloser, apperr.Err) {
+	return singbox.GetLog()
+}
+
+func EnableLog(restart bool, truncate bool, level string) apperr.Err {
+	l := config.LogLevel(level)
+	if l != "" {
+		if err := l.Validate(); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if truncate {
+		if err := TruncateLog(); err != nil {
+			return err
+		}
+	}
+
+	return updateConfig("logs/enable", restart, func(c *config.Conf) bool {
+		return config.EnableLog(c, l)
+	})
+}
+
+func DisableLog(restart bool) apperr.Err {
+	return updateConfig("logs/disable", restart, func(c *config.Conf) bool {
+		return config.DisableLog(c)
+	})
 }
 
 func TruncateLog() apperr.Err {
@@ -55,15 +210,12 @@ func TruncateLog() apperr.Err {
 }
 
 func SetLogLevel(l string, restart bool) apperr.Err {
-	if err := config.SetLogLevel(config.LogLevel(l)); err != nil {
+	level := config.LogLevel(l)
+	if err := level.Validate(); err != nil {
 		return err
 	}
 
-	if restart {
-		if err := singbox.Restart(); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return updateConfig("logs/level", restart, func(c *config.Conf) bool {
+		return config.SetLogLevel(c, level)
+	})
 }
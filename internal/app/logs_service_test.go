@@ -0,0 +1,120 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenLogStream(t *testing.T) {
+	t.Setenv("LOG_PATH", "/var/log/sing-box.log")
+
+	tests := []struct {
+		name          string
+		filter        LogStreamFilter
+		expectedCode  string
+		expectedField string
+	}{
+		{"Valid", LogStreamFilter{Level: "Warn", Search: "dns", Regex: `outbound/\w+`, Tail: 100}, "", ""},
+		{"Tail_Negative", LogStreamFilter{Tail: -1}, "LogStream_InvalidTail", "tail"},
+		{"Tail_TooBig", LogStreamFilter{Tail: MaxLogStreamTail + 1}, "LogStream_InvalidTail", "tail"},
+		{"Level_Invalid", LogStreamFilter{Level: "verbose"}, "LogLevel_Invalid", "level"},
+		{"Regex_Invalid", LogStreamFilter{Regex: "(unclosed"}, "LogStream_InvalidRegex", "regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := OpenLogStream(&tt.filter)
+			if tt.expectedCode == "" {
+				assert.Nil(t, err)
+				assert.NotNil(t, stream)
+				return
+			}
+
+			require.NotNil(t, err)
+			assert.Equal(t, tt.expectedCode, err.Code())
+			assert.Equal(t, tt.expectedField, err.Field())
+		})
+	}
+}
+
+func TestListLogEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	t.Setenv("LOG_PATH", path)
+	require.NoError(t, os.WriteFile(path, []byte(
+		"+0000 2024-10-08 12:00:00 INFO [1 0ms] outbound/direct[direct]: outbound connection to example.com:80\n"+
+			"+0000 2024-10-08 12:00:01 ERROR [2 1ms] outbound/vless[proxy]: outbound connection to api.example.com:443\n"+
+			"+0000 2024-10-08 12:00:02 WARN [3 0ms] outbound/vless[proxy]: outbound connection to notexample.com:443\n"+
+			"+0000 2024-10-08 12:00:03 INFO [4 0ms] outbound/vless[proxy]: outbound connection to google.com:443\n"+
+			"INFO [5 0ms] outbound/vless[proxy]: outbound connection to example.com:443\n",
+	), 0o644))
+
+	all := &Pagination{Offset: 0, Limit: DefaultPageLimit}
+
+	tests := []struct {
+		name          string
+		filter        LogEntriesFilter
+		p             *Pagination
+		expectedHosts []string
+		expectedTotal int
+	}{
+		{"All_NewestFirst", LogEntriesFilter{}, all, []string{"example.com", "google.com", "notexample.com", "api.example.com", "example.com"}, 5},
+		{"Level", LogEntriesFilter{Level: "WARN"}, all, []string{"notexample.com", "api.example.com"}, 2},
+		{"Outbound", LogEntriesFilter{Outbound: "direct"}, all, []string{"example.com"}, 1},
+		{"Domain_WithSubdomains", LogEntriesFilter{Domain: "Example.com."}, all, []string{"example.com", "api.example.com", "example.com"}, 3},
+		{"TimeRange", LogEntriesFilter{From: "2024-10-08T12:00:01Z", To: "2024-10-08T15:00:02+03:00"}, all, []string{"notexample.com", "api.example.com"}, 2},
+		{"Pagination", LogEntriesFilter{}, &Pagination{Offset: 1, Limit: 2}, []string{"google.com", "notexample.com"}, 5},
+		{"Pagination_PastEnd", LogEntriesFilter{}, &Pagination{Offset: 5, Limit: 2}, []string{}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ListLogEntries(&tt.filter, tt.p)
+			require.Nil(t, err)
+
+			hosts := []string{}
+			for _, r := range page.Items {
+				hosts = append(hosts, r.Host)
+			}
+
+			assert.Equal(t, tt.expectedHosts, hosts)
+			assert.Equal(t, tt.expectedTotal, page.Total)
+		})
+	}
+}
+
+func TestListLogEntries_Errors(t *testing.T) {
+	all := &Pagination{Offset: 0, Limit: DefaultPageLimit}
+
+	tests := []struct {
+		name          string
+		filter        LogEntriesFilter
+		expectedCode  string
+		expectedField string
+	}{
+		{"From_Invalid", LogEntriesFilter{From: "yesterday"}, "LogEntries_InvalidTime", "from"},
+		{"To_Invalid", LogEntriesFilter{To: "2024-10-08 12:00:00"}, "LogEntries_InvalidTime", "to"},
+		{"Level_Invalid", LogEntriesFilter{Level: "verbose"}, "LogLevel_Invalid", "level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ListLogEntries(&tt.filter, all)
+			require.NotNil(t, err)
+			assert.Equal(t, tt.expectedCode, err.Code())
+			assert.Equal(t, tt.expectedField, err.Field())
+		})
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		t.Setenv("LOG_PATH", filepath.Join(t.TempDir(), "missing.log"))
+
+		page, err := ListLogEntries(&LogEntriesFilter{}, all)
+		require.Nil(t, err)
+		assert.Equal(t, 0, page.Total)
+		assert.Empty(t, page.Items)
+	})
+}
@@ -0,0 +1,260 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+)
+
+var (
+	errImportEmpty = apperr.NewValidationErr("OutboundImport_Empty", "no links to import")
+	// errImportNotApplied is never returned to the caller, the failures are reported in the item results
+	errImportNotApplied = apperr.NewValidationErr("OutboundImport_NotApplied", "outbounds cannot be added")
+)
+
+const (
+	minQRSize = 64
+	maxQRSize = 2048
+)
+
+func errQRSize(size int) apperr.Err {
+	return apperr.NewFieldValidationErr("OutboundShare_InvalidSize", "size", fmt.Sprintf("size %d is out of the range %d-%d", size, minQRSize, maxQRSize))
+}
+
+func errQREncoding(msg string) apperr.Err {
+	return apperr.NewFatalErr("OutboundShare_QREncoding", msg)
+}
+
+func errImportTags(tags, links int) apperr.Err {
+	return apperr.NewFieldValidationErr("OutboundImport_TooManyTags", "tags", fmt.Sprintf("%d tags are given for %d links", tags, links))
+}
+
+type OutboundImport struct {
+	Links []string `json:"links"`
+	// Tags replace the tags made from the links by index, an empty one keeps the made tag
+	Tags []string `json:"tags"`
+}
+
+type OutboundImportItem struct {
+	Index    int              `json:"index"`
+	Status   BatchItemStatus  `json:"status"`
+	Code     string           `json:"code,omitempty"`
+	Field    string           `json:"field,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Outbound *config.Outbound `json:"outbound,omitempty"`
+
+	generated bool
+}
+
+type OutboundImportResult struct {
+	Added     bool                 `json:"added"`
+	Outbounds []OutboundImportItem `json:"outbounds"`
+}
+
+func (r *OutboundImportResult) HasStatus(s BatchItemStatus) bool {
+	return slices.ContainsFunc(r.Outbounds, func(item OutboundImportItem) bool {
+		return item.Status == s
+	})
+}
+
+func parseImportLinks(imp *OutboundImport) ([]OutboundImportItem, bool) {
+	items := make([]OutboundImportItem, len(imp.Links))
+	valid := true
+
+	for i, link := range imp.Links {
+		o, err := outbound.ParseLink(link)
+		if err == nil {
+			if i < len(imp.Tags) && imp.Tags[i] != "" {
+				o.Tag = imp.Tags[i]
+			}
+
+			err = outbound.Validate(o)
+		}
+
+		items[i] = OutboundImportItem{Index: i, Status: BatchValid, Outbound: o, generated: i >= len(imp.Tags) || imp.Tags[i] == ""}
+		if err != nil {
+			items[i].Status, items[i].Code, items[i].Field, items[i].Error = BatchInvalid, err.Code(), err.Field(), err.Msg()
+			valid = false
+		}
+	}
+
+	return items, valid
+}
+
+// addImportedOutbounds numbers the made tags the configuration already has, the given ones must be unique
+func addImportedOutbounds(c *config.Conf, items []OutboundImportItem) (failed bool) {
+	for i := range items {
+		item := &items[i]
+		if item.generated {
+			item.Outbound.Tag = outbound.UniqueTag(c, item.Outbound.Tag)
+		}
+
+		if err := outbound.Add(c, item.Outbound); err != nil {
+			item.Status = BatchInvalid
+			if err.Kind() == apperr.Conflict {
+				item.Status = BatchConflict
+			}
+
+			item.Code, item.Field, item.Error = err.Code(), err.Field(), err.Msg()
+			failed = true
+			continue
+		}
+
+		item.Status = BatchAdded
+	}
+
+	return failed
+}
+
+// ImportOutbounds makes the outbounds from the share links, they are only previewed unless add is set,
+// then all of them are added or none
+func ImportOutbounds(imp *OutboundImport, add bool, restart bool) (*OutboundImportResult, apperr.Err) {
+	if len(imp.Links) == 0 {
+		return nil, errImportEmpty
+	}
+
+	if len(imp.Tags) > len(imp.Links) {
+		return nil, errImportTags(len(imp.Tags), len(imp.Links))
+	}
+
+	items, valid := parseImportLinks(imp)
+	result := &OutboundImportResult{Outbounds: items}
+	if !valid {
+		return result, nil
+	}
+
+	reset := func() {
+		for i := range items {
+			if items[i].Status == BatchAdded {
+				items[i].Status = BatchValid
+			}
+		}
+	}
+
+	if !add {
+		c, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		addImportedOutbounds(c.Conf, items)
+		reset()
+		return result, nil
+	}
+
+	err := tryUpdateConfig("outbounds/import", restart, func(c *config.Conf) (bool, apperr.Err) {
+		if addImportedOutbounds(c, items) {
+			reset()
+			return false, errImportNotApplied
+		}
+
+		return true, nil
+	})
+	if err == errImportNotApplied {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result.Added = true
+	return result, nil
+}
+
+func ListOutbounds() ([]*config.Outbound, apperr.Err) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Conf.Outbounds == nil {
+		return []*config.Outbound{}, nil
+	}
+
+	return c.Conf.Outbounds, nil
+}
+
+func GetOutbound(tag string) (*config.Outbound, apperr.Err) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return outbound.Get(c.Conf, tag)
+}
+
+func AddOutbound(o *config.Outbound, restart bool) apperr.Err {
+	return tryUpdateConfig("outbounds/add "+o.Tag, restart, func(c *config.Conf) (bool, apperr.Err) {
+		if err := outbound.Add(c, o); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+// UpdateOutbound replaces the outbound as a whole, the keys the body does not have are removed
+func UpdateOutbound(tag string, o *config.Outbound, restart bool) apperr.Err {
+	return tryUpdateConfig("outbounds/update "+tag, restart, func(c *config.Conf) (bool, apperr.Err) {
+		if err := outbound.Update(c, tag, o); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+func RemoveOutbound(tag string, restart bool) apperr.Err {
+	return tryUpdateConfig("outbounds/remove "+tag, restart, func(c *config.Conf) (bool, apperr.Err) {
+		if err := outbound.Remove(c, tag); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+type OutboundShare struct {
+	Tag  string `json:"tag"`
+	Link string `json:"link"`
+}
+
+func ShareOutbound(tag string) (*OutboundShare, apperr.Err) {
+	o, err := GetOutbound(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := outbound.Link(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboundShare{Tag: o.Tag, Link: link}, nil
+}
+
+// ShareOutboundQR encodes the share link as a square PNG QR code of the size in pixels
+func ShareOutboundQR(tag string, size int) ([]byte, apperr.Err) {
+	if size < minQRSize || size > maxQRSize {
+		return nil, errQRSize(size)
+	}
+
+	share, err := ShareOutbound(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeQR(share.Link, size)
+}
+
+func encodeQR(link string, size int) ([]byte, apperr.Err) {
+	png, err := qrcode.Encode(link, qrcode.Medium, size)
+	if err != nil {
+		return nil, errQREncoding(err.Error())
+	}
+
+	return png, nil
+}
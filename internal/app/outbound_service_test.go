@@ -0,0 +1,139 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func TestOutbounds(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+
+	o := new(config.Outbound)
+	require.NoError(t, utils.FromJSON(strings.NewReader(`{
+		"type": "vless",
+		"tag": "proxy-us",
+		"server": "198.51.100.1",
+		"server_port": 443,
+		"uuid": "bf000d23-0752-40b4-affe-68f7707a9661",
+		"transport": {"type": "grpc", "service_name": "tun"}
+	}`), o))
+
+	require.Nil(t, AddOutbound(o, false))
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Contains(t, string(saved), `"service_name": "tun"`)
+
+	outbounds, err := ListOutbounds()
+	require.Nil(t, err)
+	assert.Len(t, outbounds, 4)
+
+	server, ok := o.Server()
+	require.True(t, ok)
+	server.ServerPort = 8443
+	require.Nil(t, UpdateOutbound("proxy-us", o, false))
+
+	updated, err := GetOutbound("proxy-us")
+	require.Nil(t, err)
+	server, _ = updated.Server()
+	assert.Equal(t, 8443, server.ServerPort)
+
+	_, err = AddDNSRule(&DNSRule{RouteMode: "proxy-us", Domain: "openai.com"}, false, false)
+	require.Nil(t, err)
+
+	err = RemoveOutbound("proxy-us", false)
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_InUse", err.Code())
+
+	require.Nil(t, RemoveOutbound("proxy-nl", false))
+	_, err = GetOutbound("proxy-nl")
+	assert.Equal(t, "Outbound_NotFound", err.Code())
+}
+
+func TestImportOutbounds(t *testing.T) {
+	path := setupSingbox(t, "active", "0")
+	vless := "vless://bf000d23-0752-40b4-affe-68f7707a9661@198.51.100.1:443?security=reality&sni=www.microsoft.com&pbk=jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0&sid=0123#Proxy"
+	trojan := "trojan://secret@example.com:443#US"
+
+	before, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+
+	result, err := ImportOutbounds(&OutboundImport{Links: []string{vless, trojan}}, false, false)
+	require.Nil(t, err)
+	assert.False(t, result.Added)
+	require.Len(t, result.Outbounds, 2)
+	assert.Equal(t, BatchValid, result.Outbounds[0].Status)
+	assert.Equal(t, "proxy-2", result.Outbounds[0].Outbound.Tag)
+	assert.Equal(t, "us", result.Outbounds[1].Outbound.Tag)
+
+	after, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.Equal(t, string(before), string(after))
+
+	result, err = ImportOutbounds(&OutboundImport{Links: []string{vless, "vmess://x"}}, true, false)
+	require.Nil(t, err)
+	assert.False(t, result.Added)
+	assert.Equal(t, BatchValid, result.Outbounds[0].Status)
+	assert.Equal(t, BatchInvalid, result.Outbounds[1].Status)
+	assert.Equal(t, "OutboundLink_UnsupportedScheme", result.Outbounds[1].Code)
+
+	result, err = ImportOutbounds(&OutboundImport{Links: []string{vless, trojan}, Tags: []string{"", "direct"}}, true, false)
+	require.Nil(t, err)
+	assert.False(t, result.Added)
+	assert.Equal(t, BatchValid, result.Outbounds[0].Status)
+	assert.Equal(t, BatchConflict, result.Outbounds[1].Status)
+
+	outbounds, err := ListOutbounds()
+	require.Nil(t, err)
+	assert.Len(t, outbounds, 3)
+
+	result, err = ImportOutbounds(&OutboundImport{Links: []string{vless, vless}, Tags: []string{"", "proxy-us"}}, true, false)
+	require.Nil(t, err)
+	assert.True(t, result.Added)
+	assert.Equal(t, BatchAdded, result.Outbounds[0].Status)
+	assert.Equal(t, "proxy-2", result.Outbounds[0].Outbound.Tag)
+	assert.Equal(t, "proxy-us", result.Outbounds[1].Outbound.Tag)
+
+	o, err := GetOutbound("proxy-2")
+	require.Nil(t, err)
+	assert.Equal(t, "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", o.TLS().Reality.PublicKey)
+
+	_, err = ImportOutbounds(&OutboundImport{}, false, false)
+	assert.Equal(t, errImportEmpty, err)
+
+	_, err = ImportOutbounds(&OutboundImport{Links: []string{trojan}, Tags: []string{"a", "b"}}, false, false)
+	assert.Equal(t, errImportTags(2, 1), err)
+}
+
+func TestShareOutbound(t *testing.T) {
+	setupSingbox(t, "active", "0")
+	link := "trojan://secret@example.com:443?sni=example.com#us"
+
+	_, err := ImportOutbounds(&OutboundImport{Links: []string{link}}, true, false)
+	require.Nil(t, err)
+
+	share, err := ShareOutbound("us")
+	require.Nil(t, err)
+	assert.Equal(t, &OutboundShare{Tag: "us", Link: "trojan://secret@example.com:443?security=tls&sni=example.com#us"}, share)
+
+	png, err := ShareOutboundQR("us", 128)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(png), "\x89PNG\r\n\x1a\n"))
+
+	_, err = ShareOutboundQR("us", 32)
+	assert.Equal(t, errQRSize(32), err)
+
+	_, err = ShareOutbound("direct")
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_ShareUnsupported", err.Code())
+
+	_, err = ShareOutbound("unknown")
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_NotFound", err.Code())
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var errInvalidOffset = apperr.NewFieldValidationErr("Pagination_InvalidOffset", "offset", "offset must not be negative")
+
+func errInvalidLimit(l int) apperr.Err {
+	return apperr.NewFieldValidationErr("Pagination_InvalidLimit", "limit", fmt.Sprintf("limit '%d' is invalid, expected a value between 1 and %d", l, MaxPageLimit))
+}
+
+type Pagination struct {
+	Offset int
+	Limit  int
+}
+
+type Page[T any] struct {
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Items  []T `json:"items"`
+}
+
+func (p *Pagination) validate() apperr.Err {
+	if p.Offset < 0 {
+		return errInvalidOffset
+	}
+
+	if p.Limit < 1 || p.Limit > MaxPageLimit {
+		return errInvalidLimit(p.Limit)
+	}
+
+	return nil
+}
+
+func paginate[T any](items []T, p *Pagination) *Page[T] {
+	page := &Page[T]{Total: len(items), Offset: p.Offset, Limit: p.Limit, Items: []T{}}
+	if p.Offset >= len(items) {
+		return page
+	}
+
+	end := min(p.Offset+p.Limit, len(items))
+	page.Items = items[p.Offset:end]
+	return page
+}
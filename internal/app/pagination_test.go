@@ -0,0 +1,50 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+func TestPagination_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Pagination
+		expected apperr.Err
+	}{
+		{"Valid", Pagination{Offset: 0, Limit: 10}, nil},
+		{"Valid_MaxLimit", Pagination{Offset: 5000, Limit: MaxPageLimit}, nil},
+		{"NegativeOffset", Pagination{Offset: -1, Limit: 10}, errInvalidOffset},
+		{"ZeroLimit", Pagination{Offset: 0, Limit: 0}, errInvalidLimit(0)},
+		{"TooBigLimit", Pagination{Offset: 0, Limit: MaxPageLimit + 1}, errInvalidLimit(MaxPageLimit + 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.p.validate())
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name     string
+		p        Pagination
+		expected *Page[int]
+	}{
+		{"FirstPage", Pagination{Offset: 0, Limit: 2}, &Page[int]{Total: 5, Offset: 0, Limit: 2, Items: []int{1, 2}}},
+		{"MiddlePage", Pagination{Offset: 2, Limit: 2}, &Page[int]{Total: 5, Offset: 2, Limit: 2, Items: []int{3, 4}}},
+		{"LastPartialPage", Pagination{Offset: 4, Limit: 2}, &Page[int]{Total: 5, Offset: 4, Limit: 2, Items: []int{5}}},
+		{"OffsetOutOfRange", Pagination{Offset: 10, Limit: 2}, &Page[int]{Total: 5, Offset: 10, Limit: 2, Items: []int{}}},
+		{"LimitExceedsTotal", Pagination{Offset: 0, Limit: 100}, &Page[int]{Total: 5, Offset: 0, Limit: 100, Items: []int{1, 2, 3, 4, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, paginate(items, &tt.p))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/simulate"
+)
+
+var errRouteTestEmptyQuery = apperr.NewFieldValidationErr("RouteTest_EmptyQuery", "host", "host or ip must be specified")
+
+func errRouteTestInvalidIP(ip string) apperr.Err {
+	return apperr.NewFieldValidationErr("RouteTest_InvalidIP", "ip", fmt.Sprintf("ip '%s' is invalid", ip))
+}
+
+type RouteQuery struct {
+	Host     string
+	IP       string
+	Inbound  string
+	Protocol string
+}
+
+func (q *RouteQuery) toQuery() (*simulate.Query, apperr.Err) {
+	result := &simulate.Query{
+		Host:     strings.ToLower(strings.TrimSuffix(strings.TrimSpace(q.Host), ".")),
+		Inbound:  strings.TrimSpace(q.Inbound),
+		Protocol: strings.ToLower(strings.TrimSpace(q.Protocol)),
+	}
+
+	if ip := strings.TrimSpace(q.IP); ip != "" {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, errRouteTestInvalidIP(q.IP)
+		}
+
+		result.IP = addr
+	}
+
+	// An IP literal in place of the host is matched by the IP rules only, sing-box does not resolve it
+	if addr, err := netip.ParseAddr(strings.Trim(result.Host, "[]")); err == nil {
+		result.Host = ""
+		if !result.IP.IsValid() {
+			result.IP = addr
+		}
+	}
+
+	if result.Host == "" && !result.IP.IsValid() {
+		return nil, errRouteTestEmptyQuery
+	}
+
+	return result, nil
+}
+
+// TestRoute evaluates the rules of the current configuration against the query the way sing-box does
+func TestRoute(q *RouteQuery) (*simulate.Result, apperr.Err) {
+	query, err := q.toQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return simulate.Run(c.Conf, query), nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config/simulate"
+)
+
+func TestRouteQuery_ToQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       RouteQuery
+		expected    *simulate.Query
+		expectedErr apperr.Err
+	}{
+		{"Host_TrimSpaces_LowerCase", RouteQuery{Host: " WWW.Example.com.\n"}, &simulate.Query{Host: "www.example.com"}, nil},
+		{"HostAndIP", RouteQuery{Host: "example.com", IP: "1.2.3.4"}, &simulate.Query{Host: "example.com", IP: netip.MustParseAddr("1.2.3.4")}, nil},
+		{"IP", RouteQuery{IP: " 2001:db8::1 "}, &simulate.Query{IP: netip.MustParseAddr("2001:db8::1")}, nil},
+		{"HostIsIP", RouteQuery{Host: "1.2.3.4"}, &simulate.Query{IP: netip.MustParseAddr("1.2.3.4")}, nil},
+		{"HostIsIPv6_Brackets", RouteQuery{Host: "[2001:db8::1]"}, &simulate.Query{IP: netip.MustParseAddr("2001:db8::1")}, nil},
+		{"InboundAndProtocol", RouteQuery{Host: "example.com", Inbound: " tun-in ", Protocol: "TLS"}, &simulate.Query{Host: "example.com", Inbound: "tun-in", Protocol: "tls"}, nil},
+		{"Empty", RouteQuery{Host: " ", IP: ""}, nil, errRouteTestEmptyQuery},
+		{"InvalidIP", RouteQuery{Host: "example.com", IP: "1.2.3"}, nil, errRouteTestInvalidIP("1.2.3")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.query.toQuery()
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
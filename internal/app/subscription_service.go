@@ -0,0 +1,178 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/subscription"
+)
+
+const subscriptionCheckInterval = time.Minute
+
+func ListSubscriptions() ([]*subscription.Subscription, apperr.Err) {
+	return subscription.List()
+}
+
+func GetSubscription(id string) (*subscription.Subscription, apperr.Err) {
+	return subscription.Get(id)
+}
+
+func AddSubscription(s *subscription.Subscription) apperr.Err {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	return subscription.Add(c.Conf, s)
+}
+
+func UpdateSubscription(id string, s *subscription.Subscription) apperr.Err {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	return subscription.Update(c.Conf, id, s)
+}
+
+// RemoveSubscription keeps the outbounds of the subscription in the configuration unless purge is set
+func RemoveSubscription(id string, purge bool, restart bool) apperr.Err {
+	s, err := subscription.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if purge {
+		err := tryUpdateConfig("subscriptions/purge "+id, restart, func(c *config.Conf) (bool, apperr.Err) {
+			return subscription.Purge(c, s)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return subscription.Remove(id)
+}
+
+func RefreshSubscription(id string, restart bool) (*subscription.Result, apperr.Err) {
+	return refreshSubscription(id, restart, false)
+}
+
+// refreshSubscription records the outcome in the subscription status, the scheduled refreshes
+// restart sing-box only when the configuration has been changed
+func refreshSubscription(id string, restart bool, scheduled bool) (*subscription.Result, apperr.Err) {
+	s, err := subscription.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := subscription.Status{Outbounds: s.Outbounds, SelectorCreated: s.SelectorCreated}
+	result, err := fetchAndReconcile(s, restart, scheduled, &status)
+
+	now := time.Now().UTC()
+	status.LastRefresh = &now
+	if err != nil {
+		status.LastError = err.Msg()
+	}
+
+	if statusErr := subscription.SetStatus(id, status); statusErr != nil {
+		log.Printf("failed to record the status of the subscription '%s': %s", id, statusErr.Msg())
+	}
+
+	return result, err
+}
+
+func fetchAndReconcile(s *subscription.Subscription, restart bool, scheduled bool, status *subscription.Status) (*subscription.Result, apperr.Err) {
+	data, err := subscription.Fetch(s.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	outbounds, skipped, err := subscription.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduled && restart {
+		c, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		// The reconciliation records the outbounds in the subscription, the dry run works on a copy
+		dryRun := *s
+		dry, err := subscription.Reconcile(c.Conf, &dryRun, clone(outbounds))
+		if err != nil {
+			return dry, err
+		}
+
+		restart = dry.Changed
+	}
+
+	var result *subscription.Result
+	err = tryUpdateConfig("subscriptions/refresh "+s.ID, restart, func(c *config.Conf) (bool, apperr.Err) {
+		var reconcileErr apperr.Err
+		if result, reconcileErr = subscription.Reconcile(c, s, outbounds); reconcileErr != nil {
+			return false, reconcileErr
+		}
+
+		return result.Changed, nil
+	})
+
+	// The outbounds of the configuration left as it was still belong to the subscription as they did
+	if err == nil {
+		status.Outbounds = s.Outbounds
+		status.SelectorCreated = s.SelectorCreated
+	}
+
+	if result != nil {
+		result.Skipped = append(skipped, result.Skipped...)
+	}
+
+	return result, err
+}
+
+// clone copies the outbounds through JSON, the reconciliation changes their tags
+func clone(outbounds []*config.Outbound) []*config.Outbound {
+	copies := make([]*config.Outbound, 0, len(outbounds))
+	for _, o := range outbounds {
+		c := new(config.Outbound)
+		if b, err := o.MarshalJSON(); err == nil && c.UnmarshalJSON(b) == nil {
+			copies = append(copies, c)
+		}
+	}
+
+	return copies
+}
+
+func refreshDueSubscriptions(now time.Time) {
+	subs, err := subscription.List()
+	if err != nil {
+		log.Println("failed to list the subscriptions:", err.Msg())
+		return
+	}
+
+	for _, s := range subs {
+		if !s.Due(now) {
+			continue
+		}
+
+		if _, err := refreshSubscription(s.ID, true, true); err != nil {
+			log.Printf("failed to refresh the subscription '%s': %s", s.ID, err.Msg())
+		}
+	}
+}
+
+// StartSubscriptions refreshes the subscriptions having an interval in the background
+func StartSubscriptions() {
+	go func() {
+		ticker := time.NewTicker(subscriptionCheckInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			refreshDueSubscriptions(now)
+		}
+	}()
+}
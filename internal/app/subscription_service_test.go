@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+	"github.com/traf72/singbox-api/internal/subscription"
+)
+
+type provider struct {
+	mu      sync.Mutex
+	content string
+}
+
+func (p *provider) set(links string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.content = base64.StdEncoding.EncodeToString([]byte(links))
+}
+
+func (p *provider) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.Write([]byte(p.content))
+}
+
+func TestSubscriptions(t *testing.T) {
+	setupSingbox(t, "active", "0")
+	t.Setenv("SUBSCRIPTIONS_FILE", filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	p := new(provider)
+	p.set("trojan://secret@198.51.100.2:443#DE\nss://YWVzLTI1Ni1nY206c2VjcmV0@198.51.100.3:8388#NL\n")
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	s := &subscription.Subscription{URL: server.URL, Prefix: "sub-", Selector: "sub", Interval: "1h"}
+	require.Nil(t, AddSubscription(s))
+
+	result, err := RefreshSubscription(s.ID, false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de", "sub-nl"}, result.Added)
+
+	c, err := GetConfig()
+	require.Nil(t, err)
+	selector, err := outbound.Get(c, "sub")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de", "sub-nl"}, selector.Members())
+
+	stored, err := GetSubscription(s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de", "sub-nl"}, stored.Outbounds)
+	assert.True(t, stored.SelectorCreated)
+	assert.Empty(t, stored.LastError)
+	require.NotNil(t, stored.LastRefresh)
+	assert.False(t, stored.Due(time.Now()))
+	assert.True(t, stored.Due(time.Now().Add(time.Hour)))
+
+	p.set("trojan://secret@198.51.100.2:443#DE\nvmess://x\n")
+	result, err = RefreshSubscription(s.ID, false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-nl"}, result.Removed)
+	assert.Equal(t, []subscription.Skipped{{Index: 1, Code: "OutboundLink_UnsupportedScheme", Error: "line is not a supported share link"}}, result.Skipped)
+
+	p.set("")
+	_, refreshErr := RefreshSubscription(s.ID, false)
+	require.NotNil(t, refreshErr)
+	assert.Equal(t, "Subscription_NoOutbounds", refreshErr.Code())
+
+	stored, err = GetSubscription(s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de"}, stored.Outbounds)
+	assert.Equal(t, refreshErr.Msg(), stored.LastError)
+
+	_, err = GetOutbound("sub-de")
+	assert.Nil(t, err)
+
+	require.Nil(t, RemoveSubscription(s.ID, true, false))
+	_, err = GetOutbound("sub-de")
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_NotFound", err.Code())
+
+	_, err = GetOutbound("sub")
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_NotFound", err.Code())
+
+	_, err = GetSubscription(s.ID)
+	require.NotNil(t, err)
+	assert.Equal(t, "Subscription_NotFound", err.Code())
+}
+
+func TestRefreshDueSubscriptions(t *testing.T) {
+	setupSingbox(t, "active", "0")
+	t.Setenv("SUBSCRIPTIONS_FILE", filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	p := new(provider)
+	p.set("trojan://secret@198.51.100.2:443#DE")
+	server := httptest.NewServer(p)
+	defer server.Close()
+
+	scheduled := &subscription.Subscription{URL: server.URL, Prefix: "sched-", Interval: "1h"}
+	manual := &subscription.Subscription{URL: server.URL, Prefix: "manual-"}
+	require.Nil(t, AddSubscription(scheduled))
+	require.Nil(t, AddSubscription(manual))
+
+	refreshDueSubscriptions(time.Now())
+
+	_, err := GetOutbound("sched-de")
+	assert.Nil(t, err)
+	_, err = GetOutbound("manual-de")
+	assert.NotNil(t, err)
+
+	stored, err := GetSubscription(manual.ID)
+	require.Nil(t, err)
+	assert.Nil(t, stored.LastRefresh)
+}
@@ -7,19 +7,42 @@ const (
 	NotFound
 	Conflict
 	Fatal
+	Unauthorized
+	Forbidden
 )
 
+func (k ErrKind) String() string {
+	switch k {
+	case Validation:
+		return "validation"
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Fatal:
+		return "fatal"
+	case Unauthorized:
+		return "unauthorized"
+	case Forbidden:
+		return "forbidden"
+	default:
+		return "unknown"
+	}
+}
+
 type Err interface {
 	error
 	Msg() string
 	Code() string
 	Kind() ErrKind
+	Field() string
 }
 
 type appErr struct {
-	msg  string
-	code string
-	kind ErrKind
+	msg   string
+	code  string
+	kind  ErrKind
+	field string
 }
 
 func (e *appErr) Error() string {
@@ -38,10 +61,18 @@ func (e *appErr) Kind() ErrKind {
 	return e.kind
 }
 
+func (e *appErr) Field() string {
+	return e.field
+}
+
 func NewValidationErr(code, msg string) Err {
 	return &appErr{code: code, msg: msg, kind: Validation}
 }
 
+func NewFieldValidationErr(code, field, msg string) Err {
+	return &appErr{code: code, msg: msg, kind: Validation, field: field}
+}
+
 func NewNotFoundErr(code, msg string) Err {
 	return &appErr{code: code, msg: msg, kind: NotFound}
 }
@@ -53,3 +84,11 @@ func NewConflictErr(code, msg string) Err {
 func NewFatalErr(code, msg string) Err {
 	return &appErr{code: code, msg: msg, kind: Fatal}
 }
+
+func NewUnauthorizedErr(code, msg string) Err {
+	return &appErr{code: code, msg: msg, kind: Unauthorized}
+}
+
+func NewForbiddenErr(code, msg string) Err {
+	return &appErr{code: code, msg: msg, kind: Forbidden}
+}
@@ -8,12 +8,13 @@ import (
 
 func TestAppErr(t *testing.T) {
 	tests := []struct {
-		name         string
-		appErr       Err
-		expectedMsg  string
-		expectedCode string
-		expectedKind ErrKind
-		expectedErr  string
+		name          string
+		appErr        Err
+		expectedMsg   string
+		expectedCode  string
+		expectedKind  ErrKind
+		expectedErr   string
+		expectedField string
 	}{
 		{
 			name:         "Validation Error",
@@ -23,6 +24,15 @@ func TestAppErr(t *testing.T) {
 			expectedKind: Validation,
 			expectedErr:  "Invalid input",
 		},
+		{
+			name:          "Field Validation Error",
+			appErr:        NewFieldValidationErr("VAL002", "domain", "Invalid domain"),
+			expectedMsg:   "Invalid domain",
+			expectedCode:  "VAL002",
+			expectedKind:  Validation,
+			expectedErr:   "Invalid domain",
+			expectedField: "domain",
+		},
 		{
 			name:         "Not Found Error",
 			appErr:       NewNotFoundErr("NOT001", "Resource not found"),
@@ -47,6 +57,22 @@ func TestAppErr(t *testing.T) {
 			expectedKind: Fatal,
 			expectedErr:  "Internal server failure",
 		},
+		{
+			name:         "Unauthorized Error",
+			appErr:       NewUnauthorizedErr("AUT001", "Token is missing"),
+			expectedMsg:  "Token is missing",
+			expectedCode: "AUT001",
+			expectedKind: Unauthorized,
+			expectedErr:  "Token is missing",
+		},
+		{
+			name:         "Forbidden Error",
+			appErr:       NewForbiddenErr("AUT002", "Scope is missing"),
+			expectedMsg:  "Scope is missing",
+			expectedCode: "AUT002",
+			expectedKind: Forbidden,
+			expectedErr:  "Scope is missing",
+		},
 	}
 
 	for _, tt := range tests {
@@ -55,6 +81,28 @@ func TestAppErr(t *testing.T) {
 			assert.Equal(t, tt.expectedCode, tt.appErr.Code(), "unexpected code")
 			assert.Equal(t, tt.expectedKind, tt.appErr.Kind(), "unexpected kind")
 			assert.Equal(t, tt.expectedErr, tt.appErr.Error(), "unexpected error string")
+			assert.Equal(t, tt.expectedField, tt.appErr.Field(), "unexpected field")
+		})
+	}
+}
+
+func TestErrKind_String(t *testing.T) {
+	tests := []struct {
+		kind     ErrKind
+		expected string
+	}{
+		{Validation, "validation"},
+		{NotFound, "not_found"},
+		{Conflict, "conflict"},
+		{Fatal, "fatal"},
+		{Unauthorized, "unauthorized"},
+		{Forbidden, "forbidden"},
+		{ErrKind(-1), "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.kind.String())
 		})
 	}
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"bytes"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,9 +16,11 @@ import (
 type Conf struct {
 	Log       *logging    `json:"log"`
 	DNS       dns         `json:"dns"`
-	Inbounds  []*inbound  `json:"inbounds"`
-	Outbounds []*outbound `json:"outbounds"`
+	Inbounds  []*Inbound  `json:"inbounds"`
+	Outbounds []*Outbound `json:"outbounds"`
 	Route     route       `json:"route"`
+
+	raw rawObject
 }
 
 type logging struct {
@@ -23,27 +28,33 @@ type logging struct {
 	Level     string `json:"level"`
 	Output    string `json:"output"`
 	Timestamp bool   `json:"timestamp"`
+
+	raw rawObject
 }
 
 type dns struct {
 	IndependentCache bool        `json:"independent_cache,omitempty"`
 	CacheCapacity    int         `json:"cache_capacity,omitempty"`
 	ReverseMapping   bool        `json:"reverse_mapping,omitempty"`
-	Final            string      `json:"final"`
-	Rules            []DNSRule   `json:"rules"`
-	Servers          []dnsServer `json:"servers"`
+	Final            string      `json:"final,omitempty"`
+	Rules            []DNSRule   `json:"rules,omitempty"`
+	Servers          []dnsServer `json:"servers,omitempty"`
+
+	raw rawObject
 }
 
 type Rule struct {
-	Domain        []string `json:"domain,omitempty"`
-	DomainKeyword []string `json:"domain_keyword,omitempty"`
-	DomainRegex   []string `json:"domain_regex,omitempty"`
-	DomainSuffix  []string `json:"domain_suffix,omitempty"`
+	Domain        Listable `json:"domain,omitempty"`
+	DomainKeyword Listable `json:"domain_keyword,omitempty"`
+	DomainRegex   Listable `json:"domain_regex,omitempty"`
+	DomainSuffix  Listable `json:"domain_suffix,omitempty"`
 }
 
 type DNSRule struct {
 	Rule
 	Server string `json:"server"`
+
+	raw rawObject
 }
 
 type dnsServer struct {
@@ -51,68 +62,76 @@ type dnsServer struct {
 	AddressResolver string `json:"address_resolver,omitempty"`
 	Detour          string `json:"detour,omitempty"`
 	Tag             string `json:"tag"`
-}
 
-type inbound struct {
-	Listen                 string   `json:"listen,omitempty"`
-	ListenPort             int      `json:"listen_port,omitempty"`
-	AutoRoute              bool     `json:"auto_route,omitempty"`
-	AutoRedirect           bool     `json:"auto_redirect,omitempty"`
-	EndpointIndependentNAT *bool    `json:"endpoint_independent_nat,omitempty"`
-	Address                []string `json:"address,omitempty"`
-	InterfaceName          string   `json:"interface_name,omitempty"`
-	MTU                    int      `json:"mtu,omitempty"`
-	Stack                  string   `json:"stack,omitempty"`
-	StrictRoute            *bool    `json:"strict_route,omitempty"`
-	Tag                    string   `json:"tag"`
-	Type                   string   `json:"type"`
-}
-
-type outbound struct {
-	Flow           string `json:"flow,omitempty"`
-	PacketEncoding string `json:"packet_encoding,omitempty"`
-	Server         string `json:"server,omitempty"`
-	ServerPort     int    `json:"server_port,omitempty"`
-	Tag            string `json:"tag"`
-	TLS            *tls   `json:"tls,omitempty"`
-	Type           string `json:"type"`
-	UUID           string `json:"uuid,omitempty"`
+	raw rawObject
 }
 
-type tls struct {
-	ALPN       []string `json:"alpn"`
+type TLS struct {
+	ALPN       []string `json:"alpn,omitempty"`
 	Enabled    bool     `json:"enabled"`
-	Reality    *reality `json:"reality,omitempty"`
-	ServerName string   `json:"server_name"`
-	UTLS       *utls    `json:"utls,omitempty"`
+	Insecure   bool     `json:"insecure,omitempty"`
+	Reality    *Reality `json:"reality,omitempty"`
+	ServerName string   `json:"server_name,omitempty"`
+	UTLS       *UTLS    `json:"utls,omitempty"`
+
+	raw rawObject
 }
 
-type reality struct {
+type Reality struct {
 	Enabled   bool   `json:"enabled"`
 	PublicKey string `json:"public_key"`
 	ShortID   string `json:"short_id"`
+
+	raw rawObject
 }
 
-type utls struct {
+type UTLS struct {
 	Enabled     bool   `json:"enabled"`
 	Fingerprint string `json:"fingerprint"`
+
+	raw rawObject
 }
 
 type route struct {
-	AutoDetectInterface bool        `json:"auto_detect_interface"`
-	Final               string      `json:"final"`
-	Rules               []RouteRule `json:"rules"`
+	AutoDetectInterface bool        `json:"auto_detect_interface,omitempty"`
+	Final               string      `json:"final,omitempty"`
+	Rules               []RouteRule `json:"rules,omitempty"`
+	RuleSet             []RuleSet   `json:"rule_set,omitempty"`
+
+	raw rawObject
+}
+
+type RuleSet struct {
+	Tag            string `json:"tag"`
+	Type           string `json:"type"`
+	DownloadDetour string `json:"download_detour,omitempty"`
+
+	raw rawObject
 }
 
 type RouteRule struct {
 	Rule
-	IP_CIDR  []string `json:"ip_cidr,omitempty"`
-	Inbound  []string `json:"inbound,omitempty"`
+	IP_CIDR  Listable `json:"ip_cidr,omitempty"`
+	Inbound  Listable `json:"inbound,omitempty"`
 	Outbound string   `json:"outbound,omitempty"`
-	Protocol string   `json:"protocol,omitempty"`
+	Protocol Listable `json:"protocol,omitempty"`
 	Action   string   `json:"action,omitempty"`
 	Strategy string   `json:"strategy,omitempty"`
 	Timeout  string   `json:"timeout,omitempty"`
+	// Rules are the rules of a logical rule
+	Rules []RouteRule `json:"rules,omitempty"`
+
+	raw rawObject
+}
+
+// Keys returns the keys of the rule in the configuration file, including the ones RouteRule does not model
+func (r *RouteRule) Keys() []string {
+	return slices.Clone(r.raw.keys)
+}
+
+// Keys returns the keys of the rule in the configuration file, including the ones DNSRule does not model
+func (r *DNSRule) Keys() []string {
+	return slices.Clone(r.raw.keys)
 }
 
 type Config struct {
@@ -122,7 +141,10 @@ type Config struct {
 
 var serializeOptions = &utils.JSONOptions{Indent: "    ", EscapeHTML: false}
 
-var errEmptyPath = apperr.NewFatalErr("Config_EmptyPath", "path to the configuration file is not specified")
+var (
+	errEmptyPath = apperr.NewFatalErr("Config_EmptyPath", "path to the configuration file is not specified")
+	errModified  = apperr.NewConflictErr("Config_Conflict", "the configuration has been modified by another request")
+)
 
 func errStatReading(err string) apperr.Err {
 	return apperr.NewFatalErr("Config_StatReadError", err)
@@ -155,53 +177,142 @@ func Load() (*Config, apperr.Err) {
 
 var saveMutex sync.Mutex
 
-func Save(c *Config) apperr.Err {
+func Save(c *Config, operation string) apperr.Err {
 	path, appErr := getConfPath()
 	if appErr != nil {
 		return appErr
 	}
 
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
 	stat, err := os.Stat(path)
 	if err != nil {
 		return errStatReading(err.Error())
 	}
 
 	if stat.ModTime() != c.lastModified {
-		return apperr.NewConflictErr("Config_Conflict", "the configuration has been modified by another request")
+		return errModified
+	}
+
+	var buf bytes.Buffer
+	if err := utils.ToJSON(&buf, c.Conf, serializeOptions); err != nil {
+		return apperr.NewFatalErr("Config_JsonEncodeError", err.Error())
+	}
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return apperr.NewFatalErr("Config_ReadError", err.Error())
+	}
+
+	if err := writeFile(path, buf.Bytes(), stat.Mode().Perm()); err != nil {
+		return err
+	}
+
+	recordHistory(path, operation, previous, stat.ModTime(), buf.Bytes())
+
+	// The saved configuration is the one RestoreRaw may replace
+	if saved, err := os.Stat(path); err == nil {
+		c.lastModified = saved.ModTime()
+	}
+
+	return nil
+}
+
+func WriteCandidate(c *Conf) (string, apperr.Err) {
+	path, appErr := getConfPath()
+	if appErr != nil {
+		return "", appErr
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "config-candidate-*.json")
+	if err != nil {
+		return "", apperr.NewFatalErr("Config_CandidateCreateError", err.Error())
+	}
+	defer file.Close()
+
+	if err := utils.ToJSON(file, c, serializeOptions); err != nil {
+		os.Remove(file.Name())
+		return "", apperr.NewFatalErr("Config_JsonEncodeError", err.Error())
+	}
+
+	return file.Name(), nil
+}
+
+func ReadRaw() ([]byte, apperr.Err) {
+	path, appErr := getConfPath()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, apperr.NewFatalErr("Config_ReadError", err.Error())
+	}
+
+	return data, nil
+}
+
+// RestoreRaw writes the data over the configuration c has saved,
+// it is refused when the file has been changed since
+func RestoreRaw(c *Config, data []byte, operation string) apperr.Err {
+	path, appErr := getConfPath()
+	if appErr != nil {
+		return appErr
 	}
 
 	saveMutex.Lock()
 	defer saveMutex.Unlock()
 
-	tempPath := path + ".tmp"
-	tmpFile, err := os.Create(tempPath)
+	stat, err := os.Stat(path)
 	if err != nil {
-		return apperr.NewFatalErr("Config_TmpFileCreateError", err.Error())
+		return errStatReading(err.Error())
 	}
 
-	removeTmpFile := func() {
-		if err != nil {
-			if removeErr := os.Remove(tempPath); removeErr != nil {
-				log.Println("failed to remove temp config file:", removeErr)
-			}
-		}
+	if stat.ModTime() != c.lastModified {
+		return errModified
 	}
 
-	defer removeTmpFile()
-	defer tmpFile.Close()
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return apperr.NewFatalErr("Config_ReadError", err.Error())
+	}
 
-	if err := utils.ToJSON(tmpFile, c.Conf, serializeOptions); err != nil {
-		return apperr.NewFatalErr("Config_JsonEncodeError", err.Error())
+	if err := writeFile(path, data, stat.Mode().Perm()); err != nil {
+		return err
+	}
+
+	recordHistory(path, operation, previous, stat.ModTime(), data)
+	return nil
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) apperr.Err {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, perm); err != nil {
+		return apperr.NewFatalErr("Config_TmpFileWriteError", err.Error())
 	}
 
-	tmpFile.Close()
 	if err := os.Rename(tempPath, path); err != nil {
+		if removeErr := os.Remove(tempPath); removeErr != nil {
+			log.Println("failed to remove temp config file:", removeErr)
+		}
+
 		return apperr.NewFatalErr("Config_TmpFileRenameError", err.Error())
 	}
 
 	return nil
 }
 
+// Dir is the directory of the configuration file, the files the service keeps are next to it by default
+func Dir() (string, apperr.Err) {
+	path, err := getConfPath()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Dir(path), nil
+}
+
 func getConfPath() (string, apperr.Err) {
 	path := os.Getenv("CONFIG_PATH")
 	if path == "" {
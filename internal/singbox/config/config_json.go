@@ -0,0 +1,181 @@
+package config
+
+func (c *Conf) UnmarshalJSON(b []byte) error {
+	type plain Conf
+	return unmarshalObject(b, &c.raw, (*plain)(c))
+}
+
+func (c Conf) MarshalJSON() ([]byte, error) {
+	type plain Conf
+	return marshalObject(&c.raw, (*plain)(&c))
+}
+
+func (l *logging) UnmarshalJSON(b []byte) error {
+	type plain logging
+	return unmarshalObject(b, &l.raw, (*plain)(l))
+}
+
+func (l logging) MarshalJSON() ([]byte, error) {
+	type plain logging
+	return marshalObject(&l.raw, (*plain)(&l))
+}
+
+func (d *dns) UnmarshalJSON(b []byte) error {
+	type plain dns
+	return unmarshalObject(b, &d.raw, (*plain)(d))
+}
+
+func (d dns) MarshalJSON() ([]byte, error) {
+	type plain dns
+	return marshalObject(&d.raw, (*plain)(&d))
+}
+
+func (d *DNSRule) UnmarshalJSON(b []byte) error {
+	type plain DNSRule
+	return unmarshalObject(b, &d.raw, (*plain)(d))
+}
+
+func (d DNSRule) MarshalJSON() ([]byte, error) {
+	type plain DNSRule
+	return marshalObject(&d.raw, (*plain)(&d))
+}
+
+func (d *dnsServer) UnmarshalJSON(b []byte) error {
+	type plain dnsServer
+	return unmarshalObject(b, &d.raw, (*plain)(d))
+}
+
+func (d dnsServer) MarshalJSON() ([]byte, error) {
+	type plain dnsServer
+	return marshalObject(&d.raw, (*plain)(&d))
+}
+
+func (i *Inbound) UnmarshalJSON(b []byte) error {
+	type plain Inbound
+	return unmarshalObject(b, &i.raw, (*plain)(i))
+}
+
+func (i Inbound) MarshalJSON() ([]byte, error) {
+	type plain Inbound
+	return marshalObject(&i.raw, (*plain)(&i))
+}
+
+func (u *InboundUser) UnmarshalJSON(b []byte) error {
+	type plain InboundUser
+	return unmarshalObject(b, &u.raw, (*plain)(u))
+}
+
+func (u InboundUser) MarshalJSON() ([]byte, error) {
+	type plain InboundUser
+	return marshalObject(&u.raw, (*plain)(&u))
+}
+
+func (t *InboundTLS) UnmarshalJSON(b []byte) error {
+	type plain InboundTLS
+	return unmarshalObject(b, &t.raw, (*plain)(t))
+}
+
+func (t InboundTLS) MarshalJSON() ([]byte, error) {
+	type plain InboundTLS
+	return marshalObject(&t.raw, (*plain)(&t))
+}
+
+func (r *InboundReality) UnmarshalJSON(b []byte) error {
+	type plain InboundReality
+	return unmarshalObject(b, &r.raw, (*plain)(r))
+}
+
+func (r InboundReality) MarshalJSON() ([]byte, error) {
+	type plain InboundReality
+	return marshalObject(&r.raw, (*plain)(&r))
+}
+
+func (t *TLS) UnmarshalJSON(b []byte) error {
+	type plain TLS
+	return unmarshalObject(b, &t.raw, (*plain)(t))
+}
+
+func (t TLS) MarshalJSON() ([]byte, error) {
+	type plain TLS
+	return marshalObject(&t.raw, (*plain)(&t))
+}
+
+func (r *Reality) UnmarshalJSON(b []byte) error {
+	type plain Reality
+	return unmarshalObject(b, &r.raw, (*plain)(r))
+}
+
+func (r Reality) MarshalJSON() ([]byte, error) {
+	type plain Reality
+	return marshalObject(&r.raw, (*plain)(&r))
+}
+
+func (u *UTLS) UnmarshalJSON(b []byte) error {
+	type plain UTLS
+	return unmarshalObject(b, &u.raw, (*plain)(u))
+}
+
+func (u UTLS) MarshalJSON() ([]byte, error) {
+	type plain UTLS
+	return marshalObject(&u.raw, (*plain)(&u))
+}
+
+func (h *Hysteria2Obfs) UnmarshalJSON(b []byte) error {
+	type plain Hysteria2Obfs
+	return unmarshalObject(b, &h.raw, (*plain)(h))
+}
+
+func (h Hysteria2Obfs) MarshalJSON() ([]byte, error) {
+	type plain Hysteria2Obfs
+	return marshalObject(&h.raw, (*plain)(&h))
+}
+
+func (p *WireGuardPeer) UnmarshalJSON(b []byte) error {
+	type plain WireGuardPeer
+	return unmarshalObject(b, &p.raw, (*plain)(p))
+}
+
+func (p WireGuardPeer) MarshalJSON() ([]byte, error) {
+	type plain WireGuardPeer
+	return marshalObject(&p.raw, (*plain)(&p))
+}
+
+func (t *Transport) UnmarshalJSON(b []byte) error {
+	type plain Transport
+	return unmarshalObject(b, &t.raw, (*plain)(t))
+}
+
+func (t Transport) MarshalJSON() ([]byte, error) {
+	type plain Transport
+	return marshalObject(&t.raw, (*plain)(&t))
+}
+
+func (r *route) UnmarshalJSON(b []byte) error {
+	type plain route
+	return unmarshalObject(b, &r.raw, (*plain)(r))
+}
+
+func (r route) MarshalJSON() ([]byte, error) {
+	type plain route
+	return marshalObject(&r.raw, (*plain)(&r))
+}
+
+func (r *RuleSet) UnmarshalJSON(b []byte) error {
+	type plain RuleSet
+	return unmarshalObject(b, &r.raw, (*plain)(r))
+}
+
+func (r RuleSet) MarshalJSON() ([]byte, error) {
+	type plain RuleSet
+	return marshalObject(&r.raw, (*plain)(&r))
+}
+
+func (r *RouteRule) UnmarshalJSON(b []byte) error {
+	type plain RouteRule
+	return unmarshalObject(b, &r.raw, (*plain)(r))
+}
+
+func (r RouteRule) MarshalJSON() ([]byte, error) {
+	type plain RouteRule
+	return marshalObject(&r.raw, (*plain)(&r))
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+func loadTestConf(t *testing.T, data []byte) *Conf {
+	t.Helper()
+
+	c := new(Conf)
+	require.NoError(t, utils.FromJSON(bytes.NewReader(data), c))
+	return c
+}
+
+func saveTestConf(t *testing.T, c *Conf) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, utils.ToJSON(&buf, c, serializeOptions))
+	return buf.Bytes()
+}
+
+func TestConf_RoundTrip(t *testing.T) {
+	original, err := os.ReadFile("testdata/config.json")
+	require.NoError(t, err)
+
+	t.Run("Canonical", func(t *testing.T) {
+		assert.Equal(t, string(original), string(saveTestConf(t, loadTestConf(t, original))))
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		var compact bytes.Buffer
+		require.NoError(t, json.Compact(&compact, original))
+
+		var result bytes.Buffer
+		require.NoError(t, json.Compact(&result, saveTestConf(t, loadTestConf(t, compact.Bytes()))))
+		assert.Equal(t, compact.String(), result.String())
+	})
+
+	t.Run("Twice", func(t *testing.T) {
+		once := saveTestConf(t, loadTestConf(t, original))
+		assert.Equal(t, string(once), string(saveTestConf(t, loadTestConf(t, once))))
+	})
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Minimal", `{}`},
+		{"RouteOnly", `{"route":{"rules":[]}}`},
+		{"NoDNS", `{"log":{"level":"info"},"outbounds":[{"type":"direct","tag":"direct"}],"route":{"final":"direct"}}`},
+		{"NoRoute", `{"dns":{"servers":[{"address":"local","tag":"local"}]}}`},
+		{
+			"StringOrList",
+			`{"dns":{"rules":[{"domain":"example.com","domain_suffix":[".ru"],"server":"local"}]},"route":{"rules":[` +
+				`{"protocol":["tls","quic"],"inbound":"in","outbound":"proxy"},` +
+				`{"protocol":"dns","inbound":["in","tun-in"],"action":"hijack-dns"},` +
+				`{"domain_keyword":"yandex","domain_regex":["^a$"],"ip_cidr":"10.0.0.0/8","outbound":"direct"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result bytes.Buffer
+			require.NoError(t, json.Compact(&result, saveTestConf(t, loadTestConf(t, []byte(tt.input)))))
+			assert.Equal(t, tt.input, result.String())
+		})
+	}
+}
+
+func TestConf_StringOrList(t *testing.T) {
+	c := loadTestConf(t, []byte(`{"route":{"rules":[{"domain":"example.com","inbound":"in","protocol":"tls","outbound":"proxy"}]}}`))
+
+	rule := &c.Route.Rules[0]
+	assert.Equal(t, Listable{"example.com"}, rule.Domain)
+	assert.Equal(t, Listable{"in"}, rule.Inbound)
+	assert.Equal(t, Listable{"tls"}, rule.Protocol)
+
+	rule.Domain = append(rule.Domain, "example.org")
+	rule.Inbound = nil
+
+	var result bytes.Buffer
+	require.NoError(t, json.Compact(&result, saveTestConf(t, c)))
+	assert.Equal(t, `{"route":{"rules":[{"domain":["example.com","example.org"],"protocol":"tls","outbound":"proxy"}]}}`, result.String())
+}
+
+func TestConf_NewSections(t *testing.T) {
+	c := loadTestConf(t, []byte(`{"outbounds":[{"type":"direct","tag":"direct"}]}`))
+	c.Route.Rules = append(c.Route.Rules, RouteRule{Rule: Rule{Domain: Listable{"example.com"}}, Outbound: "direct"})
+
+	var result bytes.Buffer
+	require.NoError(t, json.Compact(&result, saveTestConf(t, c)))
+	assert.Equal(t, `{"outbounds":[{"type":"direct","tag":"direct"}],"route":{"rules":[{"domain":["example.com"],"outbound":"direct"}]}}`, result.String())
+}
+
+func TestConf_RoundTrip_KeepsUnknownFieldsOnChange(t *testing.T) {
+	original, err := os.ReadFile("testdata/config.json")
+	require.NoError(t, err)
+
+	c := loadTestConf(t, original)
+	c.Route.Rules[4].Domain = append(c.Route.Rules[4].Domain, "example.com")
+	c.Route.Rules[6].DomainRegex = nil
+	c.DNS.Rules[2].DomainKeyword = nil
+	c.Route.Rules = append(c.Route.Rules, RouteRule{Rule: Rule{Domain: []string{"new.com"}}, Outbound: "proxy"})
+	c.Log.Level = "info"
+
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(saveTestConf(t, c), &result))
+
+	var expected map[string]any
+	require.NoError(t, json.Unmarshal(original, &expected))
+
+	route := expected["route"].(map[string]any)
+	rules := route["rules"].([]any)
+	rules[4].(map[string]any)["domain"] = []any{"chatgpt.com", "пример.рф", "example.com"}
+	delete(rules[6].(map[string]any), "domain_regex")
+	route["rules"] = append(rules, map[string]any{"domain": []any{"new.com"}, "outbound": "proxy"})
+	delete(expected["dns"].(map[string]any)["rules"].([]any)[2].(map[string]any), "domain_keyword")
+	expected["log"].(map[string]any)["level"] = "info"
+
+	assert.Equal(t, expected, result)
+}
+
+func TestMarshalObject_NewObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   any
+		expected string
+	}{
+		{"RouteRule_Outbound", RouteRule{Outbound: "proxy", Rule: Rule{Domain: []string{"google.com"}}}, `{"domain":["google.com"],"outbound":"proxy"}`},
+		{"RouteRule_Reject", RouteRule{Action: "reject"}, `{"action":"reject"}`},
+		{"DNSRule_EmptyServer", DNSRule{}, `{"server":""}`},
+		{"Logging", logging{Level: "info"}, `{"disabled":false,"level":"info","output":"","timestamp":false}`},
+		{"TLS_HTML", TLS{ServerName: "<a&b>"}, `{"enabled":false,"server_name":"<a&b>"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := json.Marshal(tt.source)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestMarshalObject_KeepsEmptyKnownFields(t *testing.T) {
+	input := `{"independent_cache":false,"final":"","rules":[],"servers":null,"x":{"y":[1,2.50,"z"]}}`
+
+	d := new(dns)
+	require.NoError(t, json.Unmarshal([]byte(input), d))
+
+	result, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(result))
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const maxDiffPaths = 20
+
+type DiffSummary struct {
+	Added   int      `json:"added"`
+	Removed int      `json:"removed"`
+	Changed int      `json:"changed"`
+	Paths   []string `json:"paths,omitempty"`
+}
+
+func diff(previous, current []byte) DiffSummary {
+	before, after := make(map[string]string), make(map[string]string)
+	flatten("", decodeAny(previous), before)
+	flatten("", decodeAny(current), after)
+
+	var summary DiffSummary
+	var paths []string
+
+	for path, value := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			summary.Added++
+			paths = append(paths, "+ "+path)
+		case old != value:
+			summary.Changed++
+			paths = append(paths, "~ "+path)
+		}
+	}
+
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			summary.Removed++
+			paths = append(paths, "- "+path)
+		}
+	}
+
+	slices.SortFunc(paths, func(a, b string) int {
+		return strings.Compare(a[2:], b[2:])
+	})
+
+	if len(paths) > maxDiffPaths {
+		paths = paths[:maxDiffPaths]
+	}
+
+	summary.Paths = paths
+	return summary
+}
+
+func decodeAny(data []byte) any {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+
+	return v
+}
+
+// flatten maps every leaf of a JSON document to its path. Arrays of scalars (domain lists and
+// such) are treated as sets, so adding or removing one entry does not shift all the others.
+func flatten(path string, v any, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if path == "" {
+				flatten(k, child, out)
+			} else {
+				flatten(path+"."+k, child, out)
+			}
+		}
+	case []any:
+		if isScalarArray(val) {
+			for _, item := range val {
+				out[fmt.Sprintf("%s[%s]", path, scalarString(item))] = ""
+			}
+
+			return
+		}
+
+		for i, child := range val {
+			flatten(fmt.Sprintf("%s[%d]", path, i), child, out)
+		}
+	default:
+		out[path] = scalarString(val)
+	}
+}
+
+func isScalarArray(items []any) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return false
+		}
+	}
+
+	return true
+}
+
+func scalarString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		expected DiffSummary
+	}{
+		{
+			name:     "NoChanges",
+			previous: `{"route":{"rules":[{"domain":["a.com","b.com"],"outbound":"proxy"}]}}`,
+			current:  `{"route":{"rules":[{"domain":["a.com","b.com"],"outbound":"proxy"}]}}`,
+			expected: DiffSummary{},
+		},
+		{
+			name:     "DomainAddedToSet",
+			previous: `{"route":{"rules":[{"domain":["a.com","b.com"],"outbound":"proxy"}]}}`,
+			current:  `{"route":{"rules":[{"domain":["c.com","a.com","b.com"],"outbound":"proxy"}]}}`,
+			expected: DiffSummary{Added: 1, Paths: []string{`+ route.rules[0].domain["c.com"]`}},
+		},
+		{
+			name:     "DomainRemovedAndValueChanged",
+			previous: `{"log":{"level":"info"},"route":{"rules":[{"domain":["a.com","b.com"],"outbound":"proxy"}]}}`,
+			current:  `{"log":{"level":"warn"},"route":{"rules":[{"domain":["b.com"],"outbound":"proxy"}]}}`,
+			expected: DiffSummary{Removed: 1, Changed: 1, Paths: []string{`~ log.level`, `- route.rules[0].domain["a.com"]`}},
+		},
+		{
+			name:     "RuleAdded",
+			previous: `{"route":{"rules":[]}}`,
+			current:  `{"route":{"rules":[{"ip_cidr":["10.0.0.0/8"],"action":"reject"}]}}`,
+			expected: DiffSummary{Added: 2, Paths: []string{`+ route.rules[0].action`, `+ route.rules[0].ip_cidr["10.0.0.0/8"]`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, diff([]byte(tt.previous), []byte(tt.current)))
+		})
+	}
+}
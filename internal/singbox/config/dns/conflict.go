@@ -0,0 +1,117 @@
+package dns
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+// Conflicts returns the rules of the other route modes that match some of the domains the rule matches:
+// exact duplicates and the rules shadowing the rule or shadowed by it, e.g. the suffix example.com and
+// the full domain api.example.com. Regular expressions only conflict with the same expression.
+func Conflicts(c *config.Conf, r *Rule) []*Rule {
+	var conflicts []*Rule
+	for _, other := range List(c) {
+		if r.ConflictsWith(other) {
+			conflicts = append(conflicts, other)
+		}
+	}
+
+	return conflicts
+}
+
+// ConflictsWith reports whether the rules are of different route modes and match some of the same domains
+func (r *Rule) ConflictsWith(other *Rule) bool {
+	return other.mode != r.mode && (covers(other, r) || covers(r, other))
+}
+
+// AddReplacing adds the rule after removing its conflicts from the other route modes
+func AddReplacing(c *config.Conf, r *Rule) (changed bool) {
+	for _, conflict := range Conflicts(c, r) {
+		removeAll(c, conflict)
+		changed = true
+	}
+
+	return Add(c, r) || changed
+}
+
+// Move relocates the rule from the groups of the other route modes holding it to the groups of its mode.
+// found is false when no mode has the rule, then nothing is changed.
+func Move(c *config.Conf, r *Rule) (from []config.RouteMode, found bool, changed bool) {
+	for _, existing := range List(c) {
+		if existing.kind != r.kind || existing.domain != r.domain {
+			continue
+		}
+
+		found = true
+		if existing.mode != r.mode {
+			from = append(from, existing.mode)
+			removeAll(c, existing)
+		}
+	}
+
+	if !found {
+		return nil, false, false
+	}
+
+	return from, true, Add(c, r) || len(from) > 0
+}
+
+// removeAll removes the rule from every rule group of its mode, not only from the one Remove works with
+func removeAll(c *config.Conf, r *Rule) {
+	remove := func(rs *config.Rule) {
+		rules := getRulesForType(r.kind, rs)
+		*rules = slices.DeleteFunc(*rules, r.matches)
+	}
+
+	for i := range c.Route.Rules {
+		if mode, ok := config.RouteModeOfRouteRule(&c.Route.Rules[i]); ok && mode == r.mode {
+			remove(&c.Route.Rules[i].Rule)
+		}
+	}
+
+	// A DNS server shared by several modes resolves the domain for the mode of the rule too
+	for i := range c.DNS.Rules {
+		if slices.Contains(routeModesOfDNSServer(c.DNS.Rules[i].Server), r.mode) {
+			remove(&c.DNS.Rules[i].Rule)
+		}
+	}
+}
+
+// covers reports whether the rule a matches every domain the rule b matches
+func covers(a, b *Rule) bool {
+	switch a.kind {
+	case Domain:
+		return b.kind == Domain && a.domain == b.domain
+	case Suffix:
+		switch b.kind {
+		case Domain:
+			return matchSuffix(a.domain, b.domain)
+		case Suffix:
+			return a.domain == b.domain || matchSuffix(a.domain, strings.TrimPrefix(b.domain, "."))
+		}
+	case Keyword:
+		switch b.kind {
+		case Domain, Keyword:
+			return strings.Contains(b.domain, a.domain)
+		case Suffix:
+			// Every domain matching the suffix contains it
+			return strings.Contains(strings.TrimPrefix(b.domain, "."), a.domain)
+		}
+	case Regex:
+		return b.kind == Regex && a.domain == b.domain
+	}
+
+	return false
+}
+
+// matchSuffix follows sing-box: the suffix matches the domain itself and its subdomains,
+// the suffix starting with a dot matches the subdomains only
+func matchSuffix(suffix, domain string) bool {
+	if strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(domain, suffix)
+	}
+
+	return domain == suffix || strings.HasSuffix(domain, "."+suffix)
+}
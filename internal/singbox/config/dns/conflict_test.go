@@ -0,0 +1,132 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        Rule
+		b        Rule
+		expected bool
+	}{
+		{"Domain_Same", Rule{Domain, config.RouteProxy, "example.com"}, Rule{Domain, config.RouteDirect, "example.com"}, true},
+		{"Domain_Other", Rule{Domain, config.RouteProxy, "example.com"}, Rule{Domain, config.RouteDirect, "api.example.com"}, false},
+		{"Domain_Suffix", Rule{Domain, config.RouteProxy, "example.com"}, Rule{Suffix, config.RouteDirect, "example.com"}, false},
+		{"Suffix_Domain", Rule{Suffix, config.RouteProxy, "example.com"}, Rule{Domain, config.RouteDirect, "api.example.com"}, true},
+		{"Suffix_DomainItself", Rule{Suffix, config.RouteProxy, "example.com"}, Rule{Domain, config.RouteDirect, "example.com"}, true},
+		{"Suffix_DomainNotLabel", Rule{Suffix, config.RouteProxy, "example.com"}, Rule{Domain, config.RouteDirect, "myexample.com"}, false},
+		{"DotSuffix_DomainItself", Rule{Suffix, config.RouteProxy, ".example.com"}, Rule{Domain, config.RouteDirect, "example.com"}, false},
+		{"Suffix_Subsuffix", Rule{Suffix, config.RouteProxy, "com"}, Rule{Suffix, config.RouteDirect, "example.com"}, true},
+		{"Suffix_DotSuffix", Rule{Suffix, config.RouteProxy, "example.com"}, Rule{Suffix, config.RouteDirect, ".example.com"}, true},
+		{"DotSuffix_Suffix", Rule{Suffix, config.RouteProxy, ".example.com"}, Rule{Suffix, config.RouteDirect, "example.com"}, false},
+		{"Keyword_Domain", Rule{Keyword, config.RouteProxy, "google"}, Rule{Domain, config.RouteDirect, "mail.google.com"}, true},
+		{"Keyword_Suffix", Rule{Keyword, config.RouteProxy, "google"}, Rule{Suffix, config.RouteDirect, ".google.com"}, true},
+		{"Keyword_Keyword", Rule{Keyword, config.RouteProxy, "goo"}, Rule{Keyword, config.RouteDirect, "google"}, true},
+		{"Keyword_Other", Rule{Keyword, config.RouteProxy, "yandex"}, Rule{Domain, config.RouteDirect, "mail.google.com"}, false},
+		{"Regex_Same", Rule{Regex, config.RouteProxy, "^.*$"}, Rule{Regex, config.RouteDirect, "^.*$"}, true},
+		{"Regex_Domain", Rule{Regex, config.RouteProxy, "^.*$"}, Rule{Domain, config.RouteDirect, "example.com"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, covers(&tt.a, &tt.b))
+		})
+	}
+}
+
+func conflictTestConf() *config.Conf {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Action: "reject", Rule: config.Rule{DomainSuffix: []string{"example.com"}}},
+		{Outbound: "direct", Rule: config.Rule{Domain: []string{"api.example.com"}, DomainKeyword: []string{"yandex"}}},
+		{Outbound: "proxy", Rule: config.Rule{Domain: []string{"google.com"}}},
+		{Action: "reject", Rule: config.Rule{Domain: []string{"api.example.com"}}},
+	}
+	c.DNS.Rules = []config.DNSRule{
+		{Server: "dns-block", Rule: config.Rule{DomainSuffix: []string{"example.com"}}},
+		{Server: "dns-direct", Rule: config.Rule{Domain: []string{"api.example.com"}, DomainKeyword: []string{"yandex"}}},
+	}
+
+	return c
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     Rule
+		expected []*Rule
+	}{
+		{
+			"ShadowedAndDuplicate",
+			Rule{Domain, config.RouteProxy, "api.example.com"},
+			[]*Rule{{Suffix, config.RouteBlock, "example.com"}, {Domain, config.RouteDirect, "api.example.com"}, {Domain, config.RouteBlock, "api.example.com"}},
+		},
+		{
+			"Shadowing",
+			Rule{Suffix, config.RouteProxy, "com"},
+			[]*Rule{{Suffix, config.RouteBlock, "example.com"}, {Domain, config.RouteDirect, "api.example.com"}, {Domain, config.RouteBlock, "api.example.com"}},
+		},
+		{"Keyword", Rule{Domain, config.RouteProxy, "mail.yandex.ru"}, []*Rule{{Keyword, config.RouteDirect, "yandex"}}},
+		{"SameMode", Rule{Domain, config.RouteProxy, "google.com"}, nil},
+		{"None", Rule{Domain, config.RouteProxy, "openai.com"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Conflicts(conflictTestConf(), &tt.rule))
+		})
+	}
+}
+
+func TestAddReplacing(t *testing.T) {
+	c := conflictTestConf()
+	rule, _ := NewRule(Domain, config.RouteProxy, "api.example.com")
+
+	assert.True(t, AddReplacing(c, rule))
+	assert.Empty(t, Conflicts(c, rule))
+
+	assert.Empty(t, c.Route.Rules[0].DomainSuffix)
+	assert.Empty(t, c.Route.Rules[1].Domain)
+	assert.Equal(t, config.Listable{"yandex"}, c.Route.Rules[1].DomainKeyword)
+	assert.Equal(t, config.Listable{"google.com", "api.example.com"}, c.Route.Rules[2].Domain)
+	assert.Empty(t, c.Route.Rules[3].Domain)
+	assert.Empty(t, c.DNS.Rules[0].DomainSuffix)
+	assert.Empty(t, c.DNS.Rules[1].Domain)
+	assert.Equal(t, []config.DNSRule{{Server: "dns-remote", Rule: config.Rule{Domain: []string{"api.example.com"}}}}, c.DNS.Rules[2:])
+
+	assert.False(t, AddReplacing(c, rule))
+}
+
+func TestMove(t *testing.T) {
+	c := conflictTestConf()
+
+	rule, _ := NewRule(Domain, config.RouteProxy, "api.example.com")
+	from, found, changed := Move(c, rule)
+	assert.Equal(t, []config.RouteMode{config.RouteDirect, config.RouteBlock}, from)
+	assert.True(t, found)
+	assert.True(t, changed)
+
+	assert.Equal(t, config.Listable{"example.com"}, c.Route.Rules[0].DomainSuffix)
+	assert.Empty(t, c.Route.Rules[1].Domain)
+	assert.Equal(t, config.Listable{"google.com", "api.example.com"}, c.Route.Rules[2].Domain)
+	assert.Empty(t, c.Route.Rules[3].Domain)
+	assert.Empty(t, c.DNS.Rules[1].Domain)
+	assert.Equal(t, []config.DNSRule{{Server: "dns-remote", Rule: config.Rule{Domain: []string{"api.example.com"}}}}, c.DNS.Rules[2:])
+
+	from, found, changed = Move(c, rule)
+	assert.Empty(t, from)
+	assert.True(t, found)
+	assert.False(t, changed)
+
+	missing, _ := NewRule(Domain, config.RouteProxy, "openai.com")
+	from, found, changed = Move(c, missing)
+	assert.Empty(t, from)
+	assert.False(t, found)
+	assert.False(t, changed)
+	assert.Len(t, c.Route.Rules, 4)
+}
@@ -2,29 +2,33 @@ package dns
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/traf72/singbox-api/internal/apperr"
 	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/utils"
+	"golang.org/x/net/idna"
 )
 
 var (
-	errEmptyDomain     = apperr.NewValidationErr("DNSRule_EmptyDomain", "domain is empty")
-	errInvalidRuleType = apperr.NewValidationErr("DNSRule_InvalidType", "rule type is invalid")
+	errEmptyDomain     = apperr.NewFieldValidationErr("DNSRule_EmptyDomain", "domain", "domain is empty")
+	errInvalidRuleType = apperr.NewFieldValidationErr("DNSRule_InvalidType", "domain", "rule type is invalid")
 )
 
 func errDomainHasSpaces(t string) apperr.Err {
-	return apperr.NewValidationErr("DNSRule_DomainHasSpaces", fmt.Sprintf("domain '%s' has spaces", t))
+	return apperr.NewFieldValidationErr("DNSRule_DomainHasSpaces", "domain", fmt.Sprintf("domain '%s' has spaces", t))
 }
 
 func errInvalidDomain(d string) apperr.Err {
-	return apperr.NewValidationErr("DNSRule_InvalidDomain", fmt.Sprintf("domain '%s' is invalid", d))
+	return apperr.NewFieldValidationErr("DNSRule_InvalidDomain", "domain", fmt.Sprintf("domain '%s' is invalid", d))
 }
 
 func errInvalidRegexp(r string) apperr.Err {
-	return apperr.NewValidationErr("DNSRule_InvalidRegexp", fmt.Sprintf("regexp is '%s' invalid", r))
+	return apperr.NewFieldValidationErr("DNSRule_InvalidRegexp", "domain", fmt.Sprintf("regexp is '%s' invalid", r))
 }
 
 type RuleType int
@@ -51,8 +55,9 @@ type Rule struct {
 	domain string
 }
 
+// NewRule converts internationalized domains of the Domain and Suffix kinds to punycode, sing-box does not match Unicode
 func NewRule(kind RuleType, mode config.RouteMode, domain string) (*Rule, apperr.Err) {
-	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = normalizeDomain(kind, domain)
 	rule := &Rule{kind: kind, mode: mode, domain: domain}
 	if err := rule.validate(); err != nil {
 		return nil, err
@@ -61,7 +66,81 @@ func NewRule(kind RuleType, mode config.RouteMode, domain string) (*Rule, apperr
 	return rule, nil
 }
 
-var domainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`)
+func (r *Rule) Kind() RuleType {
+	return r.kind
+}
+
+func (r *Rule) Mode() config.RouteMode {
+	return r.mode
+}
+
+func (r *Rule) Domain() string {
+	return r.domain
+}
+
+// Unicode returns the domain with the punycode labels decoded, it is the same as Domain for the ASCII domains
+func (r *Rule) Unicode() string {
+	if !hasIDN(r.kind) || !strings.Contains(r.domain, "xn--") {
+		return r.domain
+	}
+
+	domain, dot := strings.CutPrefix(r.domain, ".")
+	u, err := idna.Display.ToUnicode(domain)
+	if err != nil {
+		return r.domain
+	}
+
+	if dot {
+		return "." + u
+	}
+
+	return u
+}
+
+func hasIDN(kind RuleType) bool {
+	return kind == Domain || kind == Suffix
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeDomain brings the domain to the form it is stored in, so the Unicode and punycode spellings are the same rule.
+// A domain that cannot be converted is left as it is and rejected by the validation.
+func normalizeDomain(kind RuleType, domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if !hasIDN(kind) || isASCII(domain) {
+		return domain
+	}
+
+	d, dot := strings.CutPrefix(domain, ".")
+	ascii, err := idnaProfile.ToASCII(d)
+	if err != nil {
+		return domain
+	}
+
+	if dot {
+		return "." + ascii
+	}
+
+	return ascii
+}
+
+func (r *Rule) matches(domain string) bool {
+	return normalizeDomain(r.kind, domain) == r.domain
+}
+
+// The top-level domain is either letters or punycode
+var domainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+(?:[a-zA-Z]{2,}|xn--[a-zA-Z0-9-]{1,59})$`)
+
+// idnaProfile is the lookup profile that also rejects empty and too long labels
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
 
 func (r *Rule) validate() apperr.Err {
 	if !r.kind.isValid() {
@@ -69,7 +148,7 @@ func (r *Rule) validate() apperr.Err {
 	}
 
 	if err := r.mode.Validate(); err != nil {
-		return apperr.NewValidationErr("DNSRule_InvalidRouteMode", err.Error())
+		return apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", err.Error())
 	}
 
 	if r.domain == "" {
@@ -84,6 +163,10 @@ func (r *Rule) validate() apperr.Err {
 		return errInvalidDomain(r.domain)
 	}
 
+	if r.kind == Suffix && !isASCII(r.domain) {
+		return errInvalidDomain(r.domain)
+	}
+
 	if r.kind == Regex {
 		_, err := regexp.Compile(r.domain)
 		if err != nil {
@@ -94,39 +177,62 @@ func (r *Rule) validate() apperr.Err {
 	return nil
 }
 
-var dnsRoute = map[config.RouteMode]string{
+var defaultDNSServers = map[config.RouteMode]string{
 	config.RouteDirect: "dns-direct",
 	config.RouteProxy:  "dns-remote",
 	config.RouteBlock:  "dns-block",
 }
 
-func AddRule(r *Rule) apperr.Err {
-	c, err := config.Load()
+// servers maps the route modes to the DNS servers resolving their domains, LoadServers sets them
+var servers = maps.Clone(defaultDNSServers)
+
+// LoadServers reads DNS_SERVERS once at startup, the entries add to the defaults or override them,
+// e.g. "proxy-nl=dns-nl,warp=dns-warp". The servers are left as they are when an entry is malformed.
+func LoadServers() error {
+	s, err := parseServers(utils.GetEnv("DNS_SERVERS", ""))
 	if err != nil {
 		return err
 	}
 
-	added := add(r, c.Conf)
-	if added {
-		if err := config.Save(c); err != nil {
-			return err
+	servers = s
+	return nil
+}
+
+func parseServers(value string) (map[config.RouteMode]string, error) {
+	result := maps.Clone(defaultDNSServers)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
 		}
+
+		tag, server, ok := strings.Cut(entry, "=")
+		tag, server = strings.TrimSpace(tag), strings.TrimSpace(server)
+		if !ok || tag == "" || server == "" {
+			return nil, fmt.Errorf("DNS_SERVERS entry '%s' is invalid, expected 'outbound=server'", strings.TrimSpace(entry))
+		}
+
+		result[config.RouteMode(tag)] = server
 	}
 
-	return nil
+	return result, nil
+}
+
+// dnsServer returns false for the outbounds without a DNS server, their domains get the route rule only
+// and are resolved by the final DNS server
+func dnsServer(m config.RouteMode) (string, bool) {
+	server, ok := servers[m]
+	return server, ok
 }
 
-func add(r *Rule, c *config.Conf) (added bool) {
+func Add(c *config.Conf, r *Rule) (added bool) {
 	addedToRoute := addToRoute(r, c)
 	addedToDNS := addToDNS(r, c)
 	return addedToRoute || addedToDNS
 }
 
 func addToRoute(r *Rule, c *config.Conf) bool {
-	rules := getRouteRules(r, c)
-	ruleIdx := slices.IndexFunc(*rules, func(d string) bool {
-		return strings.EqualFold(strings.TrimSpace(d), r.domain)
-	})
+	rules := getRouteRules(r, c, true)
+	ruleIdx := slices.IndexFunc(*rules, r.matches)
 
 	if ruleIdx == -1 {
 		*rules = append(*rules, r.domain)
@@ -137,10 +243,12 @@ func addToRoute(r *Rule, c *config.Conf) bool {
 }
 
 func addToDNS(r *Rule, c *config.Conf) bool {
-	rules := getDNSRules(r, c)
-	ruleIdx := slices.IndexFunc(*rules, func(d string) bool {
-		return strings.EqualFold(strings.TrimSpace(d), r.domain)
-	})
+	rules := getDNSRules(r, c, true)
+	if rules == nil {
+		return false
+	}
+
+	ruleIdx := slices.IndexFunc(*rules, r.matches)
 
 	if ruleIdx == -1 {
 		*rules = append(*rules, r.domain)
@@ -150,33 +258,19 @@ func addToDNS(r *Rule, c *config.Conf) bool {
 	return false
 }
 
-func RemoveRule(r *Rule) apperr.Err {
-	c, err := config.Load()
-	if err != nil {
-		return err
-	}
-
-	removed := remove(r, c.Conf)
-	if removed {
-		if err := config.Save(c); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func remove(r *Rule, c *config.Conf) (removed bool) {
+func Remove(c *config.Conf, r *Rule) (removed bool) {
 	removedFromRoute := removeFromRoute(r, c)
 	removedFromDNS := removeFromDNS(r, c)
 	return removedFromRoute || removedFromDNS
 }
 
 func removeFromRoute(r *Rule, c *config.Conf) bool {
-	rules := getRouteRules(r, c)
-	ruleIdx := slices.IndexFunc(*rules, func(d string) bool {
-		return strings.EqualFold(strings.TrimSpace(d), r.domain)
-	})
+	rules := getRouteRules(r, c, false)
+	if rules == nil {
+		return false
+	}
+
+	ruleIdx := slices.IndexFunc(*rules, r.matches)
 
 	if ruleIdx == -1 {
 		return false
@@ -187,10 +281,12 @@ func removeFromRoute(r *Rule, c *config.Conf) bool {
 }
 
 func removeFromDNS(r *Rule, c *config.Conf) bool {
-	rules := getDNSRules(r, c)
-	ruleIdx := slices.IndexFunc(*rules, func(d string) bool {
-		return strings.EqualFold(strings.TrimSpace(d), r.domain)
-	})
+	rules := getDNSRules(r, c, false)
+	if rules == nil {
+		return false
+	}
+
+	ruleIdx := slices.IndexFunc(*rules, r.matches)
 
 	if ruleIdx == -1 {
 		return false
@@ -200,13 +296,71 @@ func removeFromDNS(r *Rule, c *config.Conf) bool {
 	return true
 }
 
-func getRouteRules(r *Rule, c *config.Conf) *[]string {
+func List(c *config.Conf) []*Rule {
+	var rules []*Rule
+	seen := make(map[Rule]bool)
+
+	collect := func(mode config.RouteMode, rs *config.Rule) {
+		for _, kind := range []RuleType{Domain, Suffix, Keyword, Regex} {
+			for _, d := range *getRulesForType(kind, rs) {
+				rule := Rule{kind: kind, mode: mode, domain: normalizeDomain(kind, d)}
+				if seen[rule] {
+					continue
+				}
+
+				seen[rule] = true
+				rules = append(rules, &rule)
+			}
+		}
+	}
+
+	for i := range c.Route.Rules {
+		if mode, ok := config.RouteModeOfRouteRule(&c.Route.Rules[i]); ok {
+			collect(mode, &c.Route.Rules[i].Rule)
+		}
+	}
+
+	for i := range c.DNS.Rules {
+		if mode, ok := routeModeOfDNSServer(c.DNS.Rules[i].Server); ok {
+			collect(mode, &c.DNS.Rules[i].Rule)
+		}
+	}
+
+	return rules
+}
+
+// routeModeOfDNSServer is false when the server is not mapped or resolves for several modes
+func routeModeOfDNSServer(server string) (config.RouteMode, bool) {
+	modes := routeModesOfDNSServer(server)
+	if len(modes) != 1 {
+		return "", false
+	}
+
+	return modes[0], true
+}
+
+func routeModesOfDNSServer(server string) []config.RouteMode {
+	var modes []config.RouteMode
+	for mode, s := range servers {
+		if s == server {
+			modes = append(modes, mode)
+		}
+	}
+
+	return modes
+}
+
+func getRouteRules(r *Rule, c *config.Conf, create bool) *config.Listable {
 	mode := string(r.mode)
 	ruleSetIdx := slices.IndexFunc(c.Route.Rules, func(rr config.RouteRule) bool {
 		return rr.Outbound == mode || (r.mode == config.RouteBlock && rr.Action == "reject")
 	})
 
 	if ruleSetIdx == -1 {
+		if !create {
+			return nil
+		}
+
 		newRule := config.RouteRule{Rule: config.Rule{}}
 		if r.mode == config.RouteBlock {
 			newRule.Action = "reject"
@@ -222,14 +376,23 @@ func getRouteRules(r *Rule, c *config.Conf) *[]string {
 	return getRulesForType(r.kind, &ruleSet.Rule)
 }
 
-func getDNSRules(r *Rule, c *config.Conf) *[]string {
+func getDNSRules(r *Rule, c *config.Conf, create bool) *config.Listable {
+	server, ok := dnsServer(r.mode)
+	if !ok {
+		return nil
+	}
+
 	ruleSetIdx := slices.IndexFunc(c.DNS.Rules, func(dr config.DNSRule) bool {
-		return dr.Server == dnsRoute[r.mode]
+		return dr.Server == server
 	})
 
 	if ruleSetIdx == -1 {
+		if !create {
+			return nil
+		}
+
 		c.DNS.Rules = append(c.DNS.Rules, config.DNSRule{
-			Server: dnsRoute[r.mode],
+			Server: server,
 			Rule:   config.Rule{},
 		})
 		ruleSetIdx = len(c.DNS.Rules) - 1
@@ -239,7 +402,7 @@ func getDNSRules(r *Rule, c *config.Conf) *[]string {
 	return getRulesForType(r.kind, &ruleSet.Rule)
 }
 
-func getRulesForType(t RuleType, r *config.Rule) *[]string {
+func getRulesForType(t RuleType, r *config.Rule) *config.Listable {
 	switch t {
 	case Suffix:
 		return &r.DomainSuffix
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/traf72/singbox-api/internal/apperr"
 	"github.com/traf72/singbox-api/internal/singbox/config"
 )
@@ -43,7 +44,7 @@ func TestRule_Validate(t *testing.T) {
 		{"Rule_WithLineBreak", Rule{kind: Keyword, mode: config.RouteProxy, domain: "google\ncom"}, errDomainHasSpaces("google\ncom")},
 		{"Rule_WithTab", Rule{kind: Keyword, mode: config.RouteProxy, domain: "google\tcom"}, errDomainHasSpaces("google\tcom")},
 		{"Kind_Invalid", Rule{kind: RuleType(-1), mode: config.RouteProxy, domain: "google.com"}, errInvalidRuleType},
-		{"RouteMode_Invalid", Rule{kind: Suffix, mode: "Unknown", domain: "google.com"}, apperr.NewValidationErr("DNSRule_InvalidRouteMode", "invalid route mode 'Unknown'")},
+		{"RouteMode_Invalid", Rule{kind: Suffix, mode: "proxy nl", domain: "google.com"}, apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "invalid route mode 'proxy nl'")},
 		{"Domain_Invalid", Rule{kind: Domain, mode: config.RouteProxy, domain: ".com"}, errInvalidDomain(".com")},
 		{"Regex_Invalid", Rule{kind: Regex, mode: config.RouteProxy, domain: "[a-z"}, errInvalidRegexp("[a-z")},
 	}
@@ -73,8 +74,14 @@ func TestNewRule(t *testing.T) {
 		{"WhiteSpaceOnlyDomain", Keyword, config.RouteProxy, " \n\r\t", nil, errEmptyDomain},
 		{"DomainWithSpace", Suffix, config.RouteProxy, "google com", nil, errDomainHasSpaces("google com")},
 		{"Kind_Invalid", RuleType(-1), config.RouteProxy, "google.com", nil, errInvalidRuleType},
-		{"RouteMode_Invalid", Suffix, "Unknown", "google.com", nil, apperr.NewValidationErr("DNSRule_InvalidRouteMode", "invalid route mode 'Unknown'")},
+		{"RouteMode_Invalid", Suffix, "proxy nl", "google.com", nil, apperr.NewFieldValidationErr("DNSRule_InvalidRouteMode", "routeMode", "invalid route mode 'proxy nl'")},
 		{"Domain_Invalid", Domain, config.RouteProxy, "@com", nil, errInvalidDomain("@com")},
+		{"Domain_IDN", Domain, config.RouteDirect, " Пример.РФ ", &Rule{Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai"}, nil},
+		{"Domain_Punycode", Domain, config.RouteDirect, "XN--E1AFMKFD.xn--p1ai", &Rule{Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai"}, nil},
+		{"Suffix_IDN", Suffix, config.RouteProxy, "рф", &Rule{Suffix, config.RouteProxy, "xn--p1ai"}, nil},
+		{"Suffix_IDN_LeadingDot", Suffix, config.RouteProxy, ".münchen.de", &Rule{Suffix, config.RouteProxy, ".xn--mnchen-3ya.de"}, nil},
+		{"Suffix_IDN_Invalid", Suffix, config.RouteProxy, "пример..рф", nil, errInvalidDomain("пример..рф")},
+		{"Keyword_Unicode_Kept", Keyword, config.RouteProxy, "Пример", &Rule{Keyword, config.RouteProxy, "пример"}, nil},
 	}
 
 	for _, tt := range tests {
@@ -85,3 +92,168 @@ func TestNewRule(t *testing.T) {
 		})
 	}
 }
+
+func TestList(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Inbound: []string{"tun-in"}, Action: "sniff"},
+		{Outbound: "proxy", Rule: config.Rule{Domain: []string{"google.com"}, DomainSuffix: []string{"youtube.com"}}},
+		{Action: "reject", Rule: config.Rule{DomainKeyword: []string{"ads"}}},
+		{Outbound: "direct", Rule: config.Rule{DomainRegex: []string{"^.*\\.ru$"}, Domain: []string{" Yandex.ru ", "пример.рф", "xn--e1afmkfd.xn--p1ai"}}},
+	}
+	c.DNS.Rules = []config.DNSRule{
+		{Server: "dns-remote", Rule: config.Rule{Domain: []string{"google.com"}, DomainSuffix: []string{"youtube.com", "openai.com"}}},
+		{Server: "dns-block", Rule: config.Rule{DomainKeyword: []string{"ads"}}},
+		{Server: "dns-local", Rule: config.Rule{Domain: []string{"router.lan"}}},
+	}
+
+	expected := []*Rule{
+		{Domain, config.RouteProxy, "google.com"},
+		{Suffix, config.RouteProxy, "youtube.com"},
+		{Keyword, config.RouteBlock, "ads"},
+		{Domain, config.RouteDirect, "yandex.ru"},
+		{Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai"},
+		{Regex, config.RouteDirect, "^.*\\.ru$"},
+		{Suffix, config.RouteProxy, "openai.com"},
+	}
+
+	assert.Equal(t, expected, List(c))
+}
+
+func TestAddRemove(t *testing.T) {
+	c := &config.Conf{}
+	rule, _ := NewRule(Suffix, config.RouteProxy, "youtube.com")
+
+	assert.False(t, Remove(c, rule))
+	assert.Empty(t, c.Route.Rules)
+	assert.Empty(t, c.DNS.Rules)
+
+	assert.True(t, Add(c, rule))
+	assert.False(t, Add(c, rule))
+	assert.Equal(t, []config.RouteRule{{Outbound: "proxy", Rule: config.Rule{DomainSuffix: []string{"youtube.com"}}}}, c.Route.Rules)
+	assert.Equal(t, []config.DNSRule{{Server: "dns-remote", Rule: config.Rule{DomainSuffix: []string{"youtube.com"}}}}, c.DNS.Rules)
+
+	assert.True(t, Remove(c, rule))
+	assert.False(t, Remove(c, rule))
+	assert.Empty(t, c.Route.Rules[0].DomainSuffix)
+	assert.Empty(t, c.DNS.Rules[0].DomainSuffix)
+}
+
+// setServers loads DNS_SERVERS, the servers of the environment are loaded back after the test
+func setServers(t *testing.T, value string) {
+	t.Helper()
+	t.Cleanup(func() { require.NoError(t, LoadServers()) })
+	t.Setenv("DNS_SERVERS", value)
+	require.NoError(t, LoadServers())
+}
+
+func TestLoadServers(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      map[config.RouteMode]string
+		expectedError string
+	}{
+		{"Empty", " ", defaultDNSServers, ""},
+		{
+			"Entries",
+			"proxy-nl = dns-nl, proxy=dns-proxy",
+			map[config.RouteMode]string{config.RouteDirect: "dns-direct", config.RouteProxy: "dns-proxy", config.RouteBlock: "dns-block", "proxy-nl": "dns-nl"},
+			"",
+		},
+		{"NoServer", "proxy-nl=dns-nl, warp=", nil, "DNS_SERVERS entry 'warp=' is invalid, expected 'outbound=server'"},
+		{"NoSeparator", "proxy-nl=dns-nl, bad", nil, "DNS_SERVERS entry 'bad' is invalid, expected 'outbound=server'"},
+		{"TrailingComma", "proxy-nl=dns-nl,", map[config.RouteMode]string{config.RouteDirect: "dns-direct", config.RouteProxy: "dns-remote", config.RouteBlock: "dns-block", "proxy-nl": "dns-nl"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { require.NoError(t, LoadServers()) })
+			t.Setenv("DNS_SERVERS", tt.value)
+
+			err := LoadServers()
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				assert.Equal(t, defaultDNSServers, servers)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, servers)
+		})
+	}
+}
+
+func TestAddRemove_OutboundTag(t *testing.T) {
+	setServers(t, "proxy-nl=dns-nl")
+	c := &config.Conf{}
+
+	nl, _ := NewRule(Domain, "proxy-nl", "openai.com")
+	assert.True(t, Add(c, nl))
+	assert.Equal(t, []config.RouteRule{{Outbound: "proxy-nl", Rule: config.Rule{Domain: []string{"openai.com"}}}}, c.Route.Rules)
+	assert.Equal(t, []config.DNSRule{{Server: "dns-nl", Rule: config.Rule{Domain: []string{"openai.com"}}}}, c.DNS.Rules)
+
+	// Without a DNS server the domain is resolved by the final one
+	warp, _ := NewRule(Domain, "warp", "chatgpt.com")
+	assert.True(t, Add(c, warp))
+	assert.Equal(t, config.RouteRule{Outbound: "warp", Rule: config.Rule{Domain: []string{"chatgpt.com"}}}, c.Route.Rules[1])
+	assert.Len(t, c.DNS.Rules, 1)
+
+	assert.Equal(t, []*Rule{nl, warp}, List(c))
+
+	assert.True(t, Remove(c, nl))
+	assert.True(t, Remove(c, warp))
+	assert.Empty(t, List(c))
+}
+
+func TestList_SharedDNSServer(t *testing.T) {
+	setServers(t, "proxy-nl=dns-remote")
+	c := &config.Conf{}
+	c.DNS.Rules = []config.DNSRule{
+		{Server: "dns-remote", Rule: config.Rule{Domain: []string{"google.com"}}},
+		{Server: "dns-direct", Rule: config.Rule{Domain: []string{"yandex.ru"}}},
+	}
+
+	assert.Equal(t, []*Rule{{Domain, config.RouteDirect, "yandex.ru"}}, List(c))
+}
+
+func TestAddRemove_IDN(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{{Outbound: "direct", Rule: config.Rule{Domain: []string{"пример.рф"}}}}
+	c.DNS.Rules = []config.DNSRule{{Server: "dns-direct", Rule: config.Rule{Domain: []string{"xn--e1afmkfd.xn--p1ai"}}}}
+
+	unicode, _ := NewRule(Domain, config.RouteDirect, "пример.рф")
+	punycode, _ := NewRule(Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai")
+	assert.Equal(t, unicode, punycode)
+
+	assert.False(t, Add(c, unicode))
+	assert.Equal(t, config.Listable{"пример.рф"}, c.Route.Rules[0].Domain)
+	assert.Equal(t, config.Listable{"xn--e1afmkfd.xn--p1ai"}, c.DNS.Rules[0].Domain)
+
+	assert.True(t, Remove(c, punycode))
+	assert.Empty(t, c.Route.Rules[0].Domain)
+	assert.Empty(t, c.DNS.Rules[0].Domain)
+
+	assert.True(t, Add(c, unicode))
+	assert.Equal(t, config.Listable{"xn--e1afmkfd.xn--p1ai"}, c.Route.Rules[0].Domain)
+}
+
+func TestRule_Unicode(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     Rule
+		expected string
+	}{
+		{"Domain", Rule{Domain, config.RouteDirect, "xn--e1afmkfd.xn--p1ai"}, "пример.рф"},
+		{"Suffix_LeadingDot", Rule{Suffix, config.RouteDirect, ".xn--mnchen-3ya.de"}, ".münchen.de"},
+		{"ASCII", Rule{Domain, config.RouteDirect, "google.com"}, "google.com"},
+		{"Keyword", Rule{Keyword, config.RouteDirect, "xn--p1ai"}, "xn--p1ai"},
+		{"InvalidPunycode", Rule{Domain, config.RouteDirect, "xn--zz.com"}, "xn--zz.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rule.Unicode())
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+const defaultHistoryLimit = 50
+
+const snapshotIDLayout = "20060102T150405.000000000Z"
+
+var snapshotIDRegex = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z$`)
+
+func errSnapshotNotFound(id string) apperr.Err {
+	return apperr.NewNotFoundErr("ConfigHistory_NotFound", fmt.Sprintf("snapshot '%s' not found", id))
+}
+
+type SnapshotInfo struct {
+	ID        string      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Operation string      `json:"operation"`
+	Diff      DiffSummary `json:"diff"`
+}
+
+type Snapshot struct {
+	SnapshotInfo
+	Config json.RawMessage `json:"config"`
+}
+
+func (s *Snapshot) Conf() (*Conf, apperr.Err) {
+	c := new(Conf)
+	if err := json.Unmarshal(s.Config, c); err != nil {
+		return nil, apperr.NewFatalErr("ConfigHistory_JsonDecodeError", err.Error())
+	}
+
+	return c, nil
+}
+
+func getHistoryDir(configPath string) string {
+	return utils.GetEnv("CONFIG_HISTORY_DIR", filepath.Join(filepath.Dir(configPath), "history"))
+}
+
+func getHistoryLimit() int {
+	limit, err := utils.GetEnvInt("CONFIG_HISTORY_LIMIT", defaultHistoryLimit)
+	if err != nil {
+		log.Println("invalid CONFIG_HISTORY_LIMIT, using the default:", err)
+		return defaultHistoryLimit
+	}
+
+	return limit
+}
+
+const baselineOperation = "baseline"
+
+// recordHistory keeps the saved configuration as a snapshot. The first snapshot is preceded by the baseline
+// one keeping the configuration as it was before the first save, so the hand-written file can be restored.
+func recordHistory(configPath, operation string, previous []byte, previousModified time.Time, current []byte) {
+	limit := getHistoryLimit()
+	if limit <= 0 {
+		return
+	}
+
+	dir := getHistoryDir(configPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Println("failed to create config history directory:", err)
+		return
+	}
+
+	ids, err := listSnapshotIDs(dir)
+	if err != nil {
+		log.Println("failed to list config snapshots:", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	if len(ids) == 0 {
+		// The baseline must be older than the snapshot of the save
+		baselineTime := previousModified.UTC()
+		if !baselineTime.Before(now) {
+			baselineTime = now.Add(-time.Nanosecond)
+		}
+
+		if !writeSnapshot(dir, baselineTime, baselineOperation, DiffSummary{}, previous) {
+			return
+		}
+	}
+
+	if !writeSnapshot(dir, now, operation, diff(previous, current), current) {
+		return
+	}
+
+	if ids, err = listSnapshotIDs(dir); err != nil {
+		log.Println("failed to list config snapshots:", err)
+		return
+	}
+
+	for len(ids) > limit {
+		if err := os.Remove(filepath.Join(dir, ids[len(ids)-1]+".json")); err != nil {
+			log.Println("failed to remove old config snapshot:", err)
+		}
+
+		ids = ids[:len(ids)-1]
+	}
+}
+
+func writeSnapshot(dir string, timestamp time.Time, operation string, d DiffSummary, config []byte) bool {
+	s := &Snapshot{
+		SnapshotInfo: SnapshotInfo{
+			ID:        timestamp.Format(snapshotIDLayout),
+			Timestamp: timestamp,
+			Operation: operation,
+			Diff:      d,
+		},
+		Config: config,
+	}
+
+	file, err := os.Create(filepath.Join(dir, s.ID+".json"))
+	if err != nil {
+		log.Println("failed to create config snapshot:", err)
+		return false
+	}
+	defer file.Close()
+
+	if err := utils.ToJSON(file, s, serializeOptions); err != nil {
+		log.Println("failed to write config snapshot:", err)
+		return false
+	}
+
+	return true
+}
+
+func listSnapshotIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if ok && !e.IsDir() && snapshotIDRegex.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	// IDs are timestamps, so the reverse lexical order is the newest first
+	slices.Sort(ids)
+	slices.Reverse(ids)
+	return ids, nil
+}
+
+func ListHistory() ([]*SnapshotInfo, apperr.Err) {
+	path, appErr := getConfPath()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	dir := getHistoryDir(path)
+	ids, err := listSnapshotIDs(dir)
+	if err != nil {
+		return nil, apperr.NewFatalErr("ConfigHistory_ReadError", err.Error())
+	}
+
+	result := make([]*SnapshotInfo, 0, len(ids))
+	for _, id := range ids {
+		s, appErr := readSnapshot(dir, id)
+		if appErr != nil {
+			log.Printf("skipping unreadable config snapshot '%s': %s", id, appErr.Msg())
+			continue
+		}
+
+		result = append(result, &s.SnapshotInfo)
+	}
+
+	return result, nil
+}
+
+func GetSnapshot(id string) (*Snapshot, apperr.Err) {
+	path, appErr := getConfPath()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if !snapshotIDRegex.MatchString(id) {
+		return nil, errSnapshotNotFound(id)
+	}
+
+	return readSnapshot(getHistoryDir(path), id)
+}
+
+func readSnapshot(dir, id string) (*Snapshot, apperr.Err) {
+	file, err := os.Open(filepath.Join(dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, errSnapshotNotFound(id)
+	} else if err != nil {
+		return nil, apperr.NewFatalErr("ConfigHistory_OpenError", err.Error())
+	}
+	defer file.Close()
+
+	s := new(Snapshot)
+	if err := utils.FromJSON(file, s); err != nil {
+		return nil, apperr.NewFatalErr("ConfigHistory_JsonDecodeError", err.Error())
+	}
+
+	return s, nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConfigFile(t *testing.T) string {
+	t.Helper()
+
+	original, err := os.ReadFile("testdata/config.json")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, original, 0o644))
+
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("CONFIG_HISTORY_DIR", "")
+	t.Setenv("CONFIG_HISTORY_LIMIT", "")
+	return path
+}
+
+func TestSave_RecordsHistory(t *testing.T) {
+	path := setupConfigFile(t)
+	original, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+
+	c, err := Load()
+	require.Nil(t, err)
+	c.Conf.Log.Level = "debug"
+	require.Nil(t, Save(c, "logs/level"))
+
+	history, err := ListHistory()
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "logs/level", history[0].Operation)
+	assert.Equal(t, DiffSummary{Changed: 1, Paths: []string{"~ log.level"}}, history[0].Diff)
+	assert.DirExists(t, filepath.Join(filepath.Dir(path), "history"))
+
+	s, err := GetSnapshot(history[0].ID)
+	require.Nil(t, err)
+
+	saved, readErr := os.ReadFile(path)
+	require.NoError(t, readErr)
+	assert.JSONEq(t, string(saved), string(s.Config))
+
+	conf, err := s.Conf()
+	require.Nil(t, err)
+	assert.Equal(t, "debug", conf.Log.Level)
+
+	assert.Equal(t, baselineOperation, history[1].Operation)
+	assert.Equal(t, DiffSummary{}, history[1].Diff)
+
+	baseline, err := GetSnapshot(history[1].ID)
+	require.Nil(t, err)
+	assert.JSONEq(t, string(original), string(baseline.Config))
+}
+
+func TestSave_BaselineOnlyOnce(t *testing.T) {
+	setupConfigFile(t)
+
+	for _, level := range []string{"debug", "info"} {
+		c, err := Load()
+		require.Nil(t, err)
+		c.Conf.Log.Level = level
+		require.Nil(t, Save(c, "logs/level "+level))
+	}
+
+	history, err := ListHistory()
+	require.Nil(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []string{"logs/level info", "logs/level debug", baselineOperation},
+		[]string{history[0].Operation, history[1].Operation, history[2].Operation})
+}
+
+func TestSave_HistoryRetention(t *testing.T) {
+	setupConfigFile(t)
+	t.Setenv("CONFIG_HISTORY_LIMIT", "2")
+
+	for _, level := range []string{"trace", "debug", "info"} {
+		c, err := Load()
+		require.Nil(t, err)
+		c.Conf.Log.Level = level
+		require.Nil(t, Save(c, "logs/level "+level))
+	}
+
+	history, err := ListHistory()
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "logs/level info", history[0].Operation)
+	assert.Equal(t, "logs/level debug", history[1].Operation)
+}
+
+func TestSave_HistoryDisabled(t *testing.T) {
+	setupConfigFile(t)
+	t.Setenv("CONFIG_HISTORY_LIMIT", "0")
+
+	c, err := Load()
+	require.Nil(t, err)
+	require.Nil(t, Save(c, "noop"))
+
+	history, err := ListHistory()
+	require.Nil(t, err)
+	assert.Empty(t, history)
+}
+
+func TestGetSnapshot_NotFound(t *testing.T) {
+	setupConfigFile(t)
+
+	for _, id := range []string{"20240101T000000.000000000Z", "../config", ""} {
+		t.Run(id, func(t *testing.T) {
+			s, err := GetSnapshot(id)
+			assert.Nil(t, s)
+			assert.Equal(t, errSnapshotNotFound(id), err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+// Inbound models the fields the share links are made of, the other ones are kept as they are.
+// Users are the users of the protocol inbounds and the credentials of the mixed, socks and http ones.
+type Inbound struct {
+	Listen                 string         `json:"listen,omitempty"`
+	ListenPort             int            `json:"listen_port,omitempty"`
+	AutoRoute              bool           `json:"auto_route,omitempty"`
+	AutoRedirect           bool           `json:"auto_redirect,omitempty"`
+	EndpointIndependentNAT *bool          `json:"endpoint_independent_nat,omitempty"`
+	Address                []string       `json:"address,omitempty"`
+	InterfaceName          string         `json:"interface_name,omitempty"`
+	MTU                    int            `json:"mtu,omitempty"`
+	Stack                  string         `json:"stack,omitempty"`
+	StrictRoute            *bool          `json:"strict_route,omitempty"`
+	Tag                    string         `json:"tag"`
+	Type                   string         `json:"type"`
+	Method                 string         `json:"method,omitempty"`
+	Password               string         `json:"password,omitempty"`
+	Users                  []InboundUser  `json:"users,omitempty"`
+	TLS                    *InboundTLS    `json:"tls,omitempty"`
+	Transport              *Transport     `json:"transport,omitempty"`
+	Obfs                   *Hysteria2Obfs `json:"obfs,omitempty"`
+
+	raw rawObject
+}
+
+type InboundUser struct {
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	Password string `json:"password,omitempty"`
+	Flow     string `json:"flow,omitempty"`
+
+	raw rawObject
+}
+
+type InboundTLS struct {
+	Enabled    bool            `json:"enabled"`
+	ServerName string          `json:"server_name,omitempty"`
+	ALPN       []string        `json:"alpn,omitempty"`
+	Reality    *InboundReality `json:"reality,omitempty"`
+
+	raw rawObject
+}
+
+// InboundReality has the short IDs either as a string or a list, as sing-box takes them
+type InboundReality struct {
+	Enabled    bool   `json:"enabled"`
+	PrivateKey string `json:"private_key"`
+	ShortID    any    `json:"short_id,omitempty"`
+
+	raw rawObject
+}
+
+// StringList takes a value that is either a string or a list of strings as it is decoded from JSON
+func StringList(v any) []string {
+	switch l := v.(type) {
+	case string:
+		return []string{l}
+	case []string:
+		return l
+	case []any:
+		result := make([]string, 0, len(l))
+		for _, item := range l {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+
+		return result
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,24 @@
+package inbound
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func errNotFound(tag string) apperr.Err {
+	return apperr.NewNotFoundErr("Inbound_NotFound", fmt.Sprintf("inbound '%s' is not found", tag))
+}
+
+func Get(c *config.Conf, tag string) (*config.Inbound, apperr.Err) {
+	i := slices.IndexFunc(c.Inbounds, func(in *config.Inbound) bool {
+		return in.Tag == tag
+	})
+	if i == -1 {
+		return nil, errNotFound(tag)
+	}
+
+	return c.Inbounds[i], nil
+}
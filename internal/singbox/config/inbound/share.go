@@ -0,0 +1,165 @@
+package inbound
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+)
+
+var (
+	errNoHost       = apperr.NewFieldValidationErr("InboundShare_NoHost", "host", "host is not given and the inbound does not listen on a public address")
+	errNoListenPort = apperr.NewValidationErr("InboundShare_NoListenPort", "inbound has no listen port")
+	errNoUsers      = apperr.NewValidationErr("InboundShare_NoUsers", "inbound has no users")
+	errPrivateKey   = apperr.NewValidationErr("InboundShare_InvalidPrivateKey", "Reality private key of the inbound is invalid, expected 32 bytes in base64url")
+)
+
+func errShareUnsupported(t string) apperr.Err {
+	return apperr.NewValidationErr("InboundShare_Unsupported", fmt.Sprintf("inbound type '%s' has no share link, expected 'vless', 'shadowsocks', 'trojan' or 'hysteria2'", t))
+}
+
+func errUserNotFound(name string) apperr.Err {
+	return apperr.NewFieldValidationErr("InboundShare_UserNotFound", "user", fmt.Sprintf("user '%s' is not found", name))
+}
+
+// Outbound makes the outbound connecting to the inbound as the user, the first one when user is empty.
+// host is the address the clients reach the server at, the listen address is used when it is not given.
+func Outbound(in *config.Inbound, host, user string) (*config.Outbound, apperr.Err) {
+	switch in.Type {
+	case config.OutboundVLESS, config.OutboundTrojan, config.OutboundHysteria2, config.OutboundShadowsocks:
+	default:
+		return nil, errShareUnsupported(in.Type)
+	}
+
+	server, err := shareServer(in, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var options config.OutboundOptions
+	switch in.Type {
+	case config.OutboundVLESS, config.OutboundTrojan, config.OutboundHysteria2:
+		u, err := findUser(in, user)
+		if err != nil {
+			return nil, err
+		}
+
+		tls, err := clientTLS(in.TLS)
+		if err != nil {
+			return nil, err
+		}
+
+		switch in.Type {
+		case config.OutboundVLESS:
+			options = &config.VLESSOptions{ServerOptions: server, UUID: u.UUID, Flow: u.Flow, TLS: tls, Transport: in.Transport}
+		case config.OutboundTrojan:
+			options = &config.TrojanOptions{ServerOptions: server, Password: u.Password, TLS: tls, Transport: in.Transport}
+		default:
+			options = &config.Hysteria2Options{ServerOptions: server, Password: u.Password, Obfs: in.Obfs, TLS: tls}
+		}
+	default:
+		password := in.Password
+
+		// The users of Shadowsocks 2022 go with the server key and their own one
+		if len(in.Users) > 0 {
+			u, err := findUser(in, user)
+			if err != nil {
+				return nil, err
+			}
+
+			password += ":" + u.Password
+		}
+
+		options = &config.ShadowsocksOptions{ServerOptions: server, Method: in.Method, Password: password}
+	}
+
+	return config.NewOutbound(in.Tag, options), nil
+}
+
+// Link makes the share link of the inbound for the user
+func Link(in *config.Inbound, host, user string) (string, apperr.Err) {
+	o, err := Outbound(in, host, user)
+	if err != nil {
+		return "", err
+	}
+
+	return outbound.Link(o)
+}
+
+func shareServer(in *config.Inbound, host string) (config.ServerOptions, apperr.Err) {
+	if in.ListenPort == 0 {
+		return config.ServerOptions{}, errNoListenPort
+	}
+
+	if host == "" {
+		addr, err := netip.ParseAddr(in.Listen)
+		if err != nil || addr.IsUnspecified() || addr.IsLoopback() {
+			return config.ServerOptions{}, errNoHost
+		}
+
+		host = addr.String()
+	}
+
+	return config.ServerOptions{Server: host, ServerPort: in.ListenPort}, nil
+}
+
+func findUser(in *config.Inbound, name string) (*config.InboundUser, apperr.Err) {
+	if len(in.Users) == 0 {
+		return nil, errNoUsers
+	}
+
+	if name == "" {
+		return &in.Users[0], nil
+	}
+
+	for i := range in.Users {
+		if in.Users[i].Name == name {
+			return &in.Users[i], nil
+		}
+	}
+
+	return nil, errUserNotFound(name)
+}
+
+// clientTLS takes the first short ID of Reality, the public key is derived from the private one
+func clientTLS(t *config.InboundTLS) (*config.TLS, apperr.Err) {
+	if t == nil || !t.Enabled {
+		return nil, nil
+	}
+
+	tls := &config.TLS{Enabled: true, ServerName: t.ServerName, ALPN: t.ALPN}
+	if r := t.Reality; r != nil && r.Enabled {
+		publicKey, err := realityPublicKey(r.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tls.Reality = &config.Reality{Enabled: true, PublicKey: publicKey}
+		if ids := config.StringList(r.ShortID); len(ids) > 0 {
+			tls.Reality.ShortID = ids[0]
+		}
+
+		// sing-box refuses Reality without uTLS
+		tls.UTLS = &config.UTLS{Enabled: true, Fingerprint: "chrome"}
+	}
+
+	return tls, nil
+}
+
+func realityPublicKey(privateKey string) (string, apperr.Err) {
+	b, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", errPrivateKey
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return "", errPrivateKey
+	}
+
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
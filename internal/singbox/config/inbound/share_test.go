@@ -0,0 +1,133 @@
+package inbound
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func loadTestConf(t *testing.T) *config.Conf {
+	t.Helper()
+
+	data, err := os.ReadFile("../testdata/config.json")
+	require.NoError(t, err)
+
+	var c config.Conf
+	require.NoError(t, json.Unmarshal(data, &c))
+	return &c
+}
+
+func parseInbound(t *testing.T, s string) *config.Inbound {
+	t.Helper()
+
+	in := new(config.Inbound)
+	require.NoError(t, json.Unmarshal([]byte(s), in))
+	return in
+}
+
+func TestLink_Reality(t *testing.T) {
+	in, err := Get(loadTestConf(t), "vless-in")
+	require.Nil(t, err)
+
+	link, err := Link(in, "vpn.example.com", "")
+	require.Nil(t, err)
+	assert.Equal(t, "vless://bf000d23-0752-40b4-affe-68f7707a9661@vpn.example.com:8443?encryption=none&flow=xtls-rprx-vision&fp=chrome&pbk=jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0&security=reality&sid=0123456789abcdef&sni=www.microsoft.com#vless-in", link)
+
+	named, err := Link(in, "vpn.example.com", "phone")
+	require.Nil(t, err)
+	assert.Equal(t, link, named)
+}
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		inbound  string
+		host     string
+		user     string
+		expected string
+	}{
+		{
+			"Trojan_WebSocket",
+			`{"type":"trojan","tag":"trojan-in","listen":"203.0.113.5","listen_port":443,"users":[{"name":"a","password":"one"},{"name":"b","password":"two"}],
+				"tls":{"enabled":true,"server_name":"example.com"},"transport":{"type":"ws","path":"/ws","headers":{"Host":["cdn.example.com"]}}}`,
+			"",
+			"b",
+			"trojan://two@203.0.113.5:443?host=cdn.example.com&path=%2Fws&security=tls&sni=example.com&type=ws#trojan-in",
+		},
+		{
+			"Hysteria2",
+			`{"type":"hysteria2","tag":"hy2","listen":"::","listen_port":8443,"users":[{"password":"secret"}],
+				"obfs":{"type":"salamander","password":"obfs"},"tls":{"enabled":true,"server_name":"example.com","alpn":["h3"]}}`,
+			"198.51.100.1",
+			"",
+			"hysteria2://secret@198.51.100.1:8443?alpn=h3&obfs=salamander&obfs-password=obfs&sni=example.com#hy2",
+		},
+		{
+			"Shadowsocks",
+			`{"type":"shadowsocks","tag":"ss","listen":"::","listen_port":8388,"method":"aes-256-gcm","password":"secret"}`,
+			"198.51.100.1",
+			"",
+			"ss://YWVzLTI1Ni1nY206c2VjcmV0@198.51.100.1:8388#ss",
+		},
+		{
+			"Shadowsocks_MultiUser",
+			`{"type":"shadowsocks","tag":"ss","listen":"::","listen_port":8388,"method":"2022-blake3-aes-128-gcm","password":"c2VydmVy",
+				"users":[{"name":"phone","password":"dXNlcg=="}]}`,
+			"198.51.100.1",
+			"phone",
+			"ss://2022-blake3-aes-128-gcm:c2VydmVy%3AdXNlcg==@198.51.100.1:8388#ss",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := Link(parseInbound(t, tt.inbound), tt.host, tt.user)
+			require.Nil(t, err)
+			assert.Equal(t, tt.expected, link)
+		})
+	}
+}
+
+func TestLink_Errors(t *testing.T) {
+	c := loadTestConf(t)
+	vlessIn, err := Get(c, "vless-in")
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		inbound  *config.Inbound
+		host     string
+		user     string
+		expected apperr.Err
+	}{
+		{"NoHost", vlessIn, "", "", errNoHost},
+		{"UserNotFound", vlessIn, "vpn.example.com", "laptop", errUserNotFound("laptop")},
+		{"Unsupported", c.Inbounds[0], "vpn.example.com", "", errShareUnsupported(c.Inbounds[0].Type)},
+		{"NoUsers", parseInbound(t, `{"type":"vless","tag":"v","listen_port":443}`), "vpn.example.com", "", errNoUsers},
+		{"NoListenPort", parseInbound(t, `{"type":"vless","tag":"v","users":[{"uuid":"x"}]}`), "vpn.example.com", "", errNoListenPort},
+		{
+			"InvalidPrivateKey",
+			parseInbound(t, `{"type":"vless","tag":"v","listen_port":443,"users":[{"uuid":"x"}],"tls":{"enabled":true,"reality":{"enabled":true,"private_key":"short"}}}`),
+			"vpn.example.com",
+			"",
+			errPrivateKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Link(tt.inbound, tt.host, tt.user)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestGet_NotFound(t *testing.T) {
+	_, err := Get(loadTestConf(t), "unknown")
+	assert.Equal(t, errNotFound("unknown"), err)
+}
@@ -0,0 +1,231 @@
+package ip
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+type Entry struct {
+	RuleIndex int    `json:"ruleIndex"`
+	Target    string `json:"target"`
+	IP        string `json:"ip"`
+}
+
+type parsedEntry struct {
+	Entry
+	prefix netip.Prefix
+}
+
+// Shadowed is an entry that never matches on its own, an entry covering it is checked before
+type Shadowed struct {
+	Entry
+	By Entry `json:"by"`
+}
+
+// Overlap is a range matched by the rules with different targets, the first entry wins
+type Overlap struct {
+	First  Entry `json:"first"`
+	Second Entry `json:"second"`
+}
+
+type Analysis struct {
+	Shadowed []Shadowed `json:"shadowed"`
+	Overlaps []Overlap  `json:"overlaps"`
+	Invalid  []Entry    `json:"invalid"`
+}
+
+// target is the route mode of the rule or its action for the rules without an outbound
+func target(rr *config.RouteRule) string {
+	if mode, ok := config.RouteModeOfRouteRule(rr); ok {
+		return string(mode)
+	}
+
+	return rr.Action
+}
+
+// Analyze checks the IP lists of all route rules in the order sing-box evaluates them
+func Analyze(c *config.Conf) *Analysis {
+	a := &Analysis{Shadowed: []Shadowed{}, Overlaps: []Overlap{}, Invalid: []Entry{}}
+
+	var entries []parsedEntry
+	for i := range c.Route.Rules {
+		rr := &c.Route.Rules[i]
+		for _, ip := range rr.IP_CIDR {
+			e := Entry{RuleIndex: i, Target: target(rr), IP: strings.TrimSpace(ip)}
+			p, ok := parsePrefix(e.IP)
+			if !ok {
+				a.Invalid = append(a.Invalid, e)
+				continue
+			}
+
+			entries = append(entries, parsedEntry{Entry: e, prefix: p})
+		}
+	}
+
+	for i, e := range entries {
+		for j, by := range entries {
+			if j != i && shadows(by, j, e, i) {
+				a.Shadowed = append(a.Shadowed, Shadowed{Entry: e.Entry, By: by.Entry})
+				break
+			}
+		}
+
+		for _, first := range entries[:i] {
+			if first.RuleIndex < e.RuleIndex && first.Target != e.Target && first.prefix.Overlaps(e.prefix) {
+				a.Overlaps = append(a.Overlaps, Overlap{First: first.Entry, Second: e.Entry})
+			}
+		}
+	}
+
+	return a
+}
+
+// shadows reports whether the entry by (at position j) makes the entry e (at position i) redundant:
+// it covers e and is in an earlier rule, or in the same rule and is either broader or an earlier duplicate
+func shadows(by parsedEntry, j int, e parsedEntry, i int) bool {
+	if !covers(by.prefix, e.prefix) {
+		return false
+	}
+
+	if by.RuleIndex != e.RuleIndex {
+		return by.RuleIndex < e.RuleIndex
+	}
+
+	return by.prefix.Bits() < e.prefix.Bits() || j < i
+}
+
+func covers(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+type Aggregation struct {
+	RuleIndex int      `json:"ruleIndex"`
+	Before    []string `json:"before"`
+	After     []string `json:"after"`
+}
+
+// Aggregate rewrites the IP list of every route rule into the minimal set of prefixes covering the same addresses.
+// The values that are not valid IPs are kept at the end of the list.
+func Aggregate(c *config.Conf) []Aggregation {
+	var result []Aggregation
+	for i := range c.Route.Rules {
+		rr := &c.Route.Rules[i]
+		if len(rr.IP_CIDR) == 0 {
+			continue
+		}
+
+		var prefixes []netip.Prefix
+		var invalid []string
+		for _, ip := range rr.IP_CIDR {
+			if p, ok := parsePrefix(strings.TrimSpace(ip)); ok {
+				prefixes = append(prefixes, p)
+			} else {
+				invalid = append(invalid, ip)
+			}
+		}
+
+		after := make([]string, 0, len(rr.IP_CIDR))
+		for _, p := range aggregate(prefixes) {
+			after = append(after, format(p))
+		}
+
+		after = append(after, invalid...)
+		if slices.Equal(after, rr.IP_CIDR) {
+			continue
+		}
+
+		result = append(result, Aggregation{RuleIndex: i, Before: rr.IP_CIDR, After: after})
+		rr.IP_CIDR = after
+	}
+
+	return result
+}
+
+// format writes a single address without the prefix length
+func format(p netip.Prefix) string {
+	if p.IsSingleIP() {
+		return p.Addr().String()
+	}
+
+	return p.String()
+}
+
+type addrRange struct {
+	from, to netip.Addr
+}
+
+// aggregate merges the prefixes into ranges and splits the ranges back into the largest aligned prefixes.
+// IPv4 goes before IPv6, the prefixes are sorted by address.
+func aggregate(prefixes []netip.Prefix) []netip.Prefix {
+	ranges := make([]addrRange, 0, len(prefixes))
+	for _, p := range prefixes {
+		ranges = append(ranges, addrRange{from: p.Addr(), to: lastAddr(p)})
+	}
+
+	slices.SortFunc(ranges, func(a, b addrRange) int {
+		return a.from.Compare(b.from)
+	})
+
+	var merged []addrRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			next := last.to.Next()
+			if last.from.Is4() == r.from.Is4() && (!next.IsValid() || r.from.Compare(next) <= 0) {
+				if r.to.Compare(last.to) > 0 {
+					last.to = r.to
+				}
+
+				continue
+			}
+		}
+
+		merged = append(merged, r)
+	}
+
+	var result []netip.Prefix
+	for _, r := range merged {
+		result = append(result, splitRange(r)...)
+	}
+
+	return result
+}
+
+func splitRange(r addrRange) []netip.Prefix {
+	var result []netip.Prefix
+	from := r.from
+	for {
+		// The shortest prefix starting exactly at from that does not go beyond the end of the range
+		bits := from.BitLen()
+		for b := 0; b < from.BitLen(); b++ {
+			p := netip.PrefixFrom(from, b)
+			if p.Masked().Addr() == from && lastAddr(p).Compare(r.to) <= 0 {
+				bits = b
+				break
+			}
+		}
+
+		p := netip.PrefixFrom(from, bits)
+		result = append(result, p)
+
+		last := lastAddr(p)
+		if last == r.to {
+			return result
+		}
+
+		from = last.Next()
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
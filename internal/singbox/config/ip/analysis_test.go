@@ -0,0 +1,96 @@
+package ip
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func TestAnalyze(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Inbound: []string{"tun-in"}, Action: "sniff"},
+		{Action: "reject", IP_CIDR: []string{"10.10.0.0/16"}},
+		{Outbound: "proxy", IP_CIDR: []string{"1.2.3.4", "1.2.3.0/24", "8.8.8.8", " 8.8.8.8/32 ", "10.10.1.0/24", "2001:db8::/32"}},
+		{Outbound: "direct", IP_CIDR: []string{"1.2.0.0/16", "2001:DB8:1::/48", "bad"}},
+		{Outbound: "dns-out", IP_CIDR: []string{"9.9.9.9"}},
+	}
+
+	expected := &Analysis{
+		Shadowed: []Shadowed{
+			{Entry{2, "proxy", "1.2.3.4"}, Entry{2, "proxy", "1.2.3.0/24"}},
+			{Entry{2, "proxy", "8.8.8.8/32"}, Entry{2, "proxy", "8.8.8.8"}},
+			{Entry{2, "proxy", "10.10.1.0/24"}, Entry{1, "block", "10.10.0.0/16"}},
+			{Entry{3, "direct", "2001:DB8:1::/48"}, Entry{2, "proxy", "2001:db8::/32"}},
+		},
+		Overlaps: []Overlap{
+			{Entry{1, "block", "10.10.0.0/16"}, Entry{2, "proxy", "10.10.1.0/24"}},
+			{Entry{2, "proxy", "1.2.3.4"}, Entry{3, "direct", "1.2.0.0/16"}},
+			{Entry{2, "proxy", "1.2.3.0/24"}, Entry{3, "direct", "1.2.0.0/16"}},
+			{Entry{2, "proxy", "2001:db8::/32"}, Entry{3, "direct", "2001:DB8:1::/48"}},
+		},
+		Invalid: []Entry{{3, "direct", "bad"}},
+	}
+
+	assert.Equal(t, expected, Analyze(c))
+}
+
+func TestAnalyze_Empty(t *testing.T) {
+	assert.Equal(t, &Analysis{Shadowed: []Shadowed{}, Overlaps: []Overlap{}, Invalid: []Entry{}}, Analyze(&config.Conf{}))
+}
+
+func TestAggregate(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Inbound: []string{"tun-in"}, Action: "sniff"},
+		{Outbound: "proxy", IP_CIDR: []string{"10.0.0.1", "10.0.0.0/24", "10.0.1.0/24", "bad", "2001:db8::/33", "2001:db8:8000::/33", "1.1.1.1"}},
+		{Outbound: "direct", IP_CIDR: []string{"1.1.1.1", "192.168.0.0/16"}},
+	}
+
+	expected := []Aggregation{{
+		RuleIndex: 1,
+		Before:    []string{"10.0.0.1", "10.0.0.0/24", "10.0.1.0/24", "bad", "2001:db8::/33", "2001:db8:8000::/33", "1.1.1.1"},
+		After:     []string{"1.1.1.1", "10.0.0.0/23", "2001:db8::/32", "bad"},
+	}}
+
+	assert.Equal(t, expected, Aggregate(c))
+	assert.Equal(t, config.Listable{"1.1.1.1", "10.0.0.0/23", "2001:db8::/32", "bad"}, c.Route.Rules[1].IP_CIDR)
+	assert.Equal(t, config.Listable{"1.1.1.1", "192.168.0.0/16"}, c.Route.Rules[2].IP_CIDR)
+
+	assert.Empty(t, Aggregate(c))
+}
+
+func TestAggregatePrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{"Single", []string{"10.0.0.1/32"}, []string{"10.0.0.1/32"}},
+		{"Adjacent", []string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{"NotAligned", []string{"10.0.0.1/32", "10.0.0.2/31"}, []string{"10.0.0.1/32", "10.0.0.2/31"}},
+		{"Range", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/32"}, []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/32"}},
+		{"Contained", []string{"10.0.0.0/8", "10.1.0.0/16"}, []string{"10.0.0.0/8"}},
+		{"Everything", []string{"0.0.0.0/1", "128.0.0.0/1", "255.255.255.255/32"}, []string{"0.0.0.0/0"}},
+		{"Families", []string{"::/0", "0.0.0.0/0"}, []string{"0.0.0.0/0", "::/0"}},
+		{"IPv6", []string{"2001:db8::1/128", "2001:db8::/127"}, []string{"2001:db8::/127"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prefixes []netip.Prefix
+			for _, p := range tt.input {
+				prefixes = append(prefixes, netip.MustParsePrefix(p))
+			}
+
+			var result []string
+			for _, p := range aggregate(prefixes) {
+				result = append(result, p.String())
+			}
+
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
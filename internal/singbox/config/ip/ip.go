@@ -2,7 +2,7 @@ package ip
 
 import (
 	"fmt"
-	"regexp"
+	"net/netip"
 	"slices"
 	"strings"
 
@@ -11,11 +11,15 @@ import (
 )
 
 var (
-	errEmptyIP = apperr.NewValidationErr("IPRule_EmptyIP", "IP is empty")
+	errEmptyIP = apperr.NewFieldValidationErr("IPRule_EmptyIP", "ip", "IP is empty")
 )
 
 func errInvalidIP(ip string) apperr.Err {
-	return apperr.NewValidationErr("IPRule_InvalidIP", fmt.Sprintf("IP '%s' is invalid", ip))
+	return apperr.NewFieldValidationErr("IPRule_InvalidIP", "ip", fmt.Sprintf("IP '%s' is invalid", ip))
+}
+
+func errInvalidRange(r string) apperr.Err {
+	return apperr.NewFieldValidationErr("IPRule_InvalidRange", "ip", fmt.Sprintf("IP range '%s' is invalid, expected 'first-last' addresses of the same family", r))
 }
 
 type Rule struct {
@@ -23,6 +27,8 @@ type Rule struct {
 	ip   string
 }
 
+// NewRule accepts IPv4 and IPv6 addresses and prefixes, the IP is stored in its canonical form:
+// the host bits of a prefix are cleared and IPv6 is written in lowercase with zeros compressed
 func NewRule(m config.RouteMode, ip string) (*Rule, apperr.Err) {
 	ip = strings.TrimSpace(ip)
 	rule := &Rule{mode: m, ip: ip}
@@ -30,94 +36,217 @@ func NewRule(m config.RouteMode, ip string) (*Rule, apperr.Err) {
 		return nil, err
 	}
 
+	rule.ip, _ = canonicalize(ip)
 	return rule, nil
 }
 
-var ipRegex = regexp.MustCompile(`^([01]?\d\d?|2[0-4]\d|25[0-5])(?:\.(?:[01]?\d\d?|2[0-4]\d|25[0-5])){3}(?:/[0-2]\d|/3[0-2])?$`)
+// NewRules accepts what NewRule does and also ranges of addresses, e.g. 203.0.113.10-203.0.113.80,
+// a range is expanded into the minimal set of prefixes covering it
+func NewRules(m config.RouteMode, input string) ([]*Rule, apperr.Err) {
+	input = strings.TrimSpace(input)
+	first, last, isRange := strings.Cut(input, "-")
+	if !isRange {
+		rule, err := NewRule(m, input)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Rule{rule}, nil
+	}
+
+	from, err := netip.ParseAddr(strings.TrimSpace(first))
+	if err != nil || from.Zone() != "" {
+		return nil, errInvalidRange(input)
+	}
+
+	to, err := netip.ParseAddr(strings.TrimSpace(last))
+	if err != nil || to.Zone() != "" || from.BitLen() != to.BitLen() || from.Compare(to) > 0 {
+		return nil, errInvalidRange(input)
+	}
+
+	var rules []*Rule
+	for _, p := range splitRange(addrRange{from: from, to: to}) {
+		rule, err := NewRule(m, format(p))
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func (r *Rule) Mode() config.RouteMode {
+	return r.mode
+}
+
+func (r *Rule) IP() string {
+	return r.ip
+}
 
 func (r *Rule) validate() apperr.Err {
 	if err := r.mode.Validate(); err != nil {
-		return apperr.NewValidationErr("IPRule_InvalidRouteMode", err.Error())
+		return apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", err.Error())
 	}
 
 	if r.ip == "" {
 		return errEmptyIP
 	}
 
-	if !ipRegex.MatchString(r.ip) {
+	if _, ok := canonicalize(r.ip); !ok {
 		return errInvalidIP(r.ip)
 	}
 
 	return nil
 }
 
-func AddRule(r *Rule) apperr.Err {
-	c, err := config.Load()
-	if err != nil {
-		return err
-	}
+func Add(c *config.Conf, r *Rule) (added bool) {
+	rules := getRouteRules(r.mode, c, true)
+	ruleIdx := slices.IndexFunc(*rules, func(ip string) bool {
+		return equal(ip, r.ip)
+	})
 
-	added := add(r, c.Conf)
-	if added {
-		if err := config.Save(c); err != nil {
-			return err
-		}
+	if ruleIdx == -1 {
+		*rules = append(*rules, r.ip)
+		return true
 	}
 
-	return nil
+	return false
 }
 
-func add(r *Rule, c *config.Conf) (added bool) {
-	rules := getRouteRules(r.mode, c)
+func Remove(c *config.Conf, r *Rule) (removed bool) {
+	rules := getRouteRules(r.mode, c, false)
+	if rules == nil {
+		return false
+	}
+
 	ruleIdx := slices.IndexFunc(*rules, func(ip string) bool {
-		return strings.TrimSpace(ip) == r.ip
+		return equal(ip, r.ip)
 	})
 
 	if ruleIdx == -1 {
-		*rules = append(*rules, r.ip)
-		return true
+		return false
 	}
 
-	return false
+	*rules = slices.Delete(*rules, ruleIdx, ruleIdx+1)
+	return true
 }
 
-func RemoveRule(r *Rule) apperr.Err {
-	c, err := config.Load()
-	if err != nil {
-		return err
+// Move relocates the IP from the rule groups of the other route modes holding it to the group of its mode.
+// found is false when no mode has the IP, then nothing is changed.
+func Move(c *config.Conf, r *Rule) (from []config.RouteMode, found bool, changed bool) {
+	matches := func(ip string) bool {
+		return equal(ip, r.ip)
 	}
 
-	removed := removeRule(r, c.Conf)
-	if removed {
-		if err := config.Save(c); err != nil {
-			return err
+	for i := range c.Route.Rules {
+		rr := &c.Route.Rules[i]
+		mode, ok := config.RouteModeOfRouteRule(rr)
+		if !ok || !slices.ContainsFunc(rr.IP_CIDR, matches) {
+			continue
+		}
+
+		found = true
+		if mode != r.mode {
+			rr.IP_CIDR = slices.DeleteFunc(rr.IP_CIDR, matches)
+			if !slices.Contains(from, mode) {
+				from = append(from, mode)
+			}
 		}
 	}
 
-	return nil
+	if !found {
+		return nil, false, false
+	}
+
+	return from, true, Add(c, r) || len(from) > 0
 }
 
-func removeRule(r *Rule, c *config.Conf) (removed bool) {
-	rules := getRouteRules(r.mode, c)
-	ruleIdx := slices.IndexFunc(*rules, func(d string) bool {
-		return strings.TrimSpace(d) == r.ip
-	})
+func List(c *config.Conf) []*Rule {
+	var rules []*Rule
+	seen := make(map[Rule]bool)
 
-	if ruleIdx == -1 {
-		return false
+	for i := range c.Route.Rules {
+		mode, ok := config.RouteModeOfRouteRule(&c.Route.Rules[i])
+		if !ok {
+			continue
+		}
+
+		for _, ip := range c.Route.Rules[i].IP_CIDR {
+			rule := Rule{mode: mode, ip: strings.TrimSpace(ip)}
+			if canonical, ok := canonicalize(rule.ip); ok {
+				rule.ip = canonical
+			}
+
+			if seen[rule] {
+				continue
+			}
+
+			seen[rule] = true
+			rules = append(rules, &rule)
+		}
 	}
 
-	*rules = slices.Delete(*rules, ruleIdx, ruleIdx+1)
-	return true
+	return rules
+}
+
+func parsePrefix(ip string) (netip.Prefix, bool) {
+	if strings.Contains(ip, "/") {
+		p, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+
+		return p.Masked(), true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// canonicalize keeps a single address without the prefix length, as it was specified
+func canonicalize(ip string) (string, bool) {
+	p, ok := parsePrefix(ip)
+	if !ok {
+		return "", false
+	}
+
+	if !strings.Contains(ip, "/") {
+		return p.Addr().String(), true
+	}
+
+	return p.String(), true
 }
 
-func getRouteRules(m config.RouteMode, c *config.Conf) *[]string {
+// equal compares the IPs by the ranges they cover, so 10.0.0.1 and 10.0.0.1/32 are the same rule.
+// The values that are not valid IPs, e.g. added to the configuration file by hand, are compared as strings.
+func equal(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	pa, okA := parsePrefix(a)
+	pb, okB := parsePrefix(b)
+	if okA && okB {
+		return pa == pb
+	}
+
+	return a == b
+}
+
+func getRouteRules(m config.RouteMode, c *config.Conf, create bool) *config.Listable {
 	mode := string(m)
 	ruleSetIdx := slices.IndexFunc(c.Route.Rules, func(rr config.RouteRule) bool {
 		return rr.Outbound == mode || (m == config.RouteBlock && rr.Action == "reject")
 	})
 
 	if ruleSetIdx == -1 {
+		if !create {
+			return nil
+		}
+
 		newRule := config.RouteRule{Rule: config.Rule{}}
 		if m == config.RouteBlock {
 			newRule.Action = "reject"
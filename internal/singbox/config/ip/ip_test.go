@@ -24,11 +24,51 @@ func TestRule_Validate(t *testing.T) {
 			rule:     Rule{mode: config.RouteDirect, ip: "10.0.0.0/24"},
 			expected: nil,
 		},
+		{
+			name:     "IP_ValidIPv6_Proxy",
+			rule:     Rule{mode: config.RouteProxy, ip: "2001:DB8::1"},
+			expected: nil,
+		},
+		{
+			name:     "IP_ValidIPv6CIDR_Direct",
+			rule:     Rule{mode: config.RouteDirect, ip: "2001:db8::/32"},
+			expected: nil,
+		},
+		{
+			name:     "IP_ValidCIDR_HostBits",
+			rule:     Rule{mode: config.RouteDirect, ip: "10.0.0.5/8"},
+			expected: nil,
+		},
 		{
 			name:     "IP_Empty",
 			rule:     Rule{mode: config.RouteProxy, ip: ""},
 			expected: errEmptyIP,
 		},
+		{
+			name:     "IP_LeadingZeroPrefixLength",
+			rule:     Rule{mode: config.RouteProxy, ip: "10.0.0.0/09"},
+			expected: errInvalidIP("10.0.0.0/09"),
+		},
+		{
+			name:     "IP_PrefixLengthOutOfRange",
+			rule:     Rule{mode: config.RouteProxy, ip: "10.0.0.0/33"},
+			expected: errInvalidIP("10.0.0.0/33"),
+		},
+		{
+			name:     "IP_IPv6PrefixLengthOutOfRange",
+			rule:     Rule{mode: config.RouteProxy, ip: "2001:db8::/129"},
+			expected: errInvalidIP("2001:db8::/129"),
+		},
+		{
+			name:     "IP_LeadingZeroOctet",
+			rule:     Rule{mode: config.RouteProxy, ip: "010.0.0.1"},
+			expected: errInvalidIP("010.0.0.1"),
+		},
+		{
+			name:     "IP_IPv6Zone",
+			rule:     Rule{mode: config.RouteProxy, ip: "fe80::1%eth0"},
+			expected: errInvalidIP("fe80::1%eth0"),
+		},
 		{
 			name:     "IP_Invalid",
 			rule:     Rule{mode: config.RouteProxy, ip: "999.999.999.999"},
@@ -36,8 +76,8 @@ func TestRule_Validate(t *testing.T) {
 		},
 		{
 			name:     "IP_InvalidRouteMode",
-			rule:     Rule{mode: "Unknown", ip: "192.168.0.1"},
-			expected: apperr.NewValidationErr("IPRule_InvalidRouteMode", "invalid route mode 'Unknown'"),
+			rule:     Rule{mode: "proxy nl", ip: "192.168.0.1"},
+			expected: apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "invalid route mode 'proxy nl'"),
 		},
 	}
 
@@ -84,10 +124,10 @@ func TestNewRule(t *testing.T) {
 		},
 		{
 			name:        "IP_InvalidRouteMode",
-			mode:        "Unknown",
+			mode:        "proxy nl",
 			ip:          "192.168.0.1",
 			expected:    nil,
-			expectedErr: apperr.NewValidationErr("IPRule_InvalidRouteMode", "invalid route mode 'Unknown'"),
+			expectedErr: apperr.NewFieldValidationErr("IPRule_InvalidRouteMode", "routeMode", "invalid route mode 'proxy nl'"),
 		},
 		{
 			name:     "TrimSpaces",
@@ -95,6 +135,24 @@ func TestNewRule(t *testing.T) {
 			ip:       "   192.168.0.100/32  ",
 			expected: &Rule{mode: config.RouteProxy, ip: "192.168.0.100/32"},
 		},
+		{
+			name:     "CIDR_HostBitsCleared",
+			mode:     config.RouteDirect,
+			ip:       "10.0.0.5/8",
+			expected: &Rule{mode: config.RouteDirect, ip: "10.0.0.0/8"},
+		},
+		{
+			name:     "IPv6_LowerCase_Compressed",
+			mode:     config.RouteProxy,
+			ip:       "2001:0DB8:0000::0001",
+			expected: &Rule{mode: config.RouteProxy, ip: "2001:db8::1"},
+		},
+		{
+			name:     "IPv6CIDR_LowerCase_HostBitsCleared",
+			mode:     config.RouteProxy,
+			ip:       "2001:DB8:AB::/32",
+			expected: &Rule{mode: config.RouteProxy, ip: "2001:db8::/32"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -105,3 +163,144 @@ func TestNewRule(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectedErr apperr.Err
+	}{
+		{"Single", " 10.0.0.5/8 ", []string{"10.0.0.0/8"}, nil},
+		{"Range", "203.0.113.10-203.0.113.80", []string{"203.0.113.10/31", "203.0.113.12/30", "203.0.113.16/28", "203.0.113.32/27", "203.0.113.64/28", "203.0.113.80"}, nil},
+		{"Range_Spaces", " 10.0.0.0 - 10.0.1.255 ", []string{"10.0.0.0/23"}, nil},
+		{"Range_SingleAddress", "10.0.0.1-10.0.0.1", []string{"10.0.0.1"}, nil},
+		{"Range_IPv6", "2001:db8::-2001:db8::ffff", []string{"2001:db8::/112"}, nil},
+		{"Range_Reversed", "10.0.0.2-10.0.0.1", nil, errInvalidRange("10.0.0.2-10.0.0.1")},
+		{"Range_MixedFamilies", "10.0.0.1-2001:db8::1", nil, errInvalidRange("10.0.0.1-2001:db8::1")},
+		{"Range_Prefix", "10.0.0.0/24-10.0.1.0/24", nil, errInvalidRange("10.0.0.0/24-10.0.1.0/24")},
+		{"Range_Open", "10.0.0.1-", nil, errInvalidRange("10.0.0.1-")},
+		{"Invalid", "10.0.0", nil, errInvalidIP("10.0.0")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := NewRules(config.RouteProxy, tt.input)
+			assert.Equal(t, tt.expectedErr, err)
+
+			var ips []string
+			for _, r := range rules {
+				assert.Equal(t, config.RouteProxy, r.Mode())
+				ips = append(ips, r.IP())
+			}
+
+			assert.Equal(t, tt.expected, ips)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Inbound: []string{"tun-in"}, Action: "sniff"},
+		{Outbound: "proxy", IP_CIDR: []string{"142.250.0.0/15", " 8.8.8.8 "}},
+		{Action: "reject", IP_CIDR: []string{"10.10.0.0/16"}},
+		{Outbound: "direct", IP_CIDR: []string{"192.168.0.0/16"}},
+		{Outbound: "proxy", IP_CIDR: []string{"8.8.8.8", "1.1.1.1"}},
+		{Outbound: "dns-out", IP_CIDR: []string{"9.9.9.9"}},
+		{Outbound: "direct", IP_CIDR: []string{"192.168.1.1/16", "2001:DB8::/32", "not-an-ip"}},
+	}
+
+	expected := []*Rule{
+		{mode: config.RouteProxy, ip: "142.250.0.0/15"},
+		{mode: config.RouteProxy, ip: "8.8.8.8"},
+		{mode: config.RouteBlock, ip: "10.10.0.0/16"},
+		{mode: config.RouteDirect, ip: "192.168.0.0/16"},
+		{mode: config.RouteProxy, ip: "1.1.1.1"},
+		{mode: "dns-out", ip: "9.9.9.9"},
+		{mode: config.RouteDirect, ip: "2001:db8::/32"},
+		{mode: config.RouteDirect, ip: "not-an-ip"},
+	}
+
+	assert.Equal(t, expected, List(c))
+}
+
+func TestAddRemove(t *testing.T) {
+	c := &config.Conf{}
+	rule, _ := NewRule(config.RouteBlock, "10.10.0.0/16")
+
+	assert.False(t, Remove(c, rule))
+	assert.Empty(t, c.Route.Rules)
+
+	assert.True(t, Add(c, rule))
+	assert.False(t, Add(c, rule))
+	assert.Equal(t, []config.RouteRule{{Action: "reject", IP_CIDR: []string{"10.10.0.0/16"}}}, c.Route.Rules)
+
+	assert.True(t, Remove(c, rule))
+	assert.False(t, Remove(c, rule))
+	assert.Empty(t, c.Route.Rules[0].IP_CIDR)
+}
+
+func TestAddRemove_EquivalentSpellings(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{{Outbound: "proxy", IP_CIDR: []string{"10.0.0.1/32", "2001:DB8::/32", "192.168.0.0/16"}}}
+
+	tests := []struct {
+		name string
+		ip   string
+	}{
+		{"SingleAddressAsPrefix", "10.0.0.1"},
+		{"IPv6_UpperCase", "2001:db8:0::/32"},
+		{"HostBits", "192.168.10.20/16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := NewRule(config.RouteProxy, tt.ip)
+			assert.Nil(t, err)
+			assert.False(t, Add(c, rule))
+			assert.Len(t, c.Route.Rules[0].IP_CIDR, 3)
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run("Remove_"+tt.name, func(t *testing.T) {
+			rule, _ := NewRule(config.RouteProxy, tt.ip)
+			assert.True(t, Remove(c, rule))
+		})
+	}
+
+	assert.Empty(t, c.Route.Rules[0].IP_CIDR)
+}
+
+func TestMove(t *testing.T) {
+	c := &config.Conf{}
+	c.Route.Rules = []config.RouteRule{
+		{Outbound: "direct", IP_CIDR: []string{"10.0.0.1/32", "192.168.0.0/16"}},
+		{Outbound: "proxy", IP_CIDR: []string{"8.8.8.8"}},
+		{Action: "reject", IP_CIDR: []string{"10.0.0.1"}},
+		{Outbound: "proxy-nl", IP_CIDR: []string{"10.0.0.1"}},
+		{Inbound: []string{"tun-in"}, Action: "sniff"},
+	}
+
+	rule, _ := NewRule(config.RouteProxy, "10.0.0.1")
+	from, found, changed := Move(c, rule)
+	assert.Equal(t, []config.RouteMode{config.RouteDirect, config.RouteBlock, "proxy-nl"}, from)
+	assert.True(t, found)
+	assert.True(t, changed)
+	assert.Equal(t, config.Listable{"192.168.0.0/16"}, c.Route.Rules[0].IP_CIDR)
+	assert.Equal(t, config.Listable{"8.8.8.8", "10.0.0.1"}, c.Route.Rules[1].IP_CIDR)
+	assert.Empty(t, c.Route.Rules[2].IP_CIDR)
+	assert.Empty(t, c.Route.Rules[3].IP_CIDR)
+
+	from, found, changed = Move(c, rule)
+	assert.Empty(t, from)
+	assert.True(t, found)
+	assert.False(t, changed)
+
+	missing, _ := NewRule(config.RouteProxy, "1.1.1.1")
+	from, found, changed = Move(c, missing)
+	assert.Empty(t, from)
+	assert.False(t, found)
+	assert.False(t, changed)
+}
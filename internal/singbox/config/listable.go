@@ -0,0 +1,29 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Listable is a list sing-box also takes as a single string. A single item the configuration
+// has as a string is written back as a string by the owning object, see marshalObject.
+type Listable []string
+
+func (l *Listable) UnmarshalJSON(b []byte) error {
+	if isJSONString(b) {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+
+		*l = Listable{s}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(l))
+}
+
+func isJSONString(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && raw[0] == '"'
+}
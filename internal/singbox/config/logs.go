@@ -8,7 +8,7 @@ import (
 )
 
 func errInvalidLogLevel(l string) apperr.Err {
-	return apperr.NewValidationErr("LogLevel_Invalid", fmt.Sprintf("Invalid log level '%s'", l))
+	return apperr.NewFieldValidationErr("LogLevel_Invalid", "level", fmt.Sprintf("Invalid log level '%s'", l))
 }
 
 type LogLevel string
@@ -37,56 +37,45 @@ func (l LogLevel) isValid() bool {
 	}
 }
 
-func EnableLog(l LogLevel) apperr.Err {
-	if l != "" && !l.isValid() {
+func (l LogLevel) Validate() apperr.Err {
+	if !l.isValid() {
 		return errInvalidLogLevel(string(l))
 	}
 
-	c, err := Load()
-	if err != nil {
-		return err
-	}
-
-	c.Conf.Log.Disabled = false
-	if l != "" {
-		c.Conf.Log.Level = l.String()
-	}
-
-	if err := Save(c); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func DisableLog() apperr.Err {
-	c, err := Load()
-	if err != nil {
-		return err
+func EnableLog(c *Conf, l LogLevel) (changed bool) {
+	if c.Log == nil {
+		c.Log = &logging{}
 	}
 
-	c.Conf.Log.Disabled = true
-	if err := Save(c); err != nil {
-		return err
+	changed = c.Log.Disabled
+	c.Log.Disabled = false
+	if l != "" && c.Log.Level != l.String() {
+		c.Log.Level = l.String()
+		changed = true
 	}
 
-	return nil
+	return changed
 }
 
-func SetLogLevel(l LogLevel) apperr.Err {
-	if !l.isValid() {
-		return errInvalidLogLevel(string(l))
+func DisableLog(c *Conf) (changed bool) {
+	if c.Log == nil {
+		c.Log = &logging{}
 	}
 
-	c, err := Load()
-	if err != nil {
-		return err
-	}
+	changed = !c.Log.Disabled
+	c.Log.Disabled = true
+	return changed
+}
 
-	c.Conf.Log.Level = l.String()
-	if err := Save(c); err != nil {
-		return err
+func SetLogLevel(c *Conf, l LogLevel) (changed bool) {
+	if c.Log == nil {
+		c.Log = &logging{}
 	}
 
-	return nil
+	changed = c.Log.Level != l.String()
+	c.Log.Level = l.String()
+	return changed
 }
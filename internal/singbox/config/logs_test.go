@@ -38,3 +38,41 @@ func TestLogLevel_String(t *testing.T) {
 		})
 	}
 }
+
+func TestEnableLog(t *testing.T) {
+	tests := []struct {
+		name            string
+		log             *logging
+		level           LogLevel
+		expected        *logging
+		expectedChanged bool
+	}{
+		{"Disabled", &logging{Disabled: true, Level: "info"}, "", &logging{Disabled: false, Level: "info"}, true},
+		{"Disabled_WithLevel", &logging{Disabled: true, Level: "info"}, " WARN ", &logging{Disabled: false, Level: "warn"}, true},
+		{"Enabled_SameLevel", &logging{Disabled: false, Level: "info"}, "info", &logging{Disabled: false, Level: "info"}, false},
+		{"Enabled_NewLevel", &logging{Disabled: false, Level: "info"}, "debug", &logging{Disabled: false, Level: "debug"}, true},
+		{"Missing", nil, "info", &logging{Disabled: false, Level: "info"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conf{Log: tt.log}
+			assert.Equal(t, tt.expectedChanged, EnableLog(c, tt.level))
+			assert.Equal(t, tt.expected, c.Log)
+		})
+	}
+}
+
+func TestDisableLog(t *testing.T) {
+	c := &Conf{Log: &logging{Level: "info"}}
+	assert.True(t, DisableLog(c))
+	assert.True(t, c.Log.Disabled)
+	assert.False(t, DisableLog(c))
+}
+
+func TestSetLogLevel(t *testing.T) {
+	c := &Conf{Log: &logging{Level: "info"}}
+	assert.True(t, SetLogLevel(c, "\tTRACE "))
+	assert.Equal(t, "trace", c.Log.Level)
+	assert.False(t, SetLogLevel(c, "trace"))
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"encoding/json"
+)
+
+const (
+	OutboundDirect      = "direct"
+	OutboundBlock       = "block"
+	OutboundDNS         = "dns"
+	OutboundSelector    = "selector"
+	OutboundURLTest     = "urltest"
+	OutboundVLESS       = "vless"
+	OutboundShadowsocks = "shadowsocks"
+	OutboundTrojan      = "trojan"
+	OutboundHysteria2   = "hysteria2"
+	OutboundTUIC        = "tuic"
+	OutboundWireGuard   = "wireguard"
+	OutboundSOCKS       = "socks"
+	OutboundHTTP        = "http"
+)
+
+// Outbound is the part every outbound has, the protocol fields are in Options, whose type follows Type.
+// The types without options, e.g. direct or the ones the model does not know, keep all their keys as they are.
+type Outbound struct {
+	Type    string          `json:"type"`
+	Tag     string          `json:"tag"`
+	Detour  string          `json:"detour,omitempty"`
+	Options OutboundOptions `json:"-"`
+
+	raw rawObject
+}
+
+type OutboundOptions interface {
+	outboundType() string
+}
+
+func NewOutbound(tag string, options OutboundOptions) *Outbound {
+	return &Outbound{Type: options.outboundType(), Tag: tag, Options: options}
+}
+
+func newOutboundOptions(t string) OutboundOptions {
+	switch t {
+	case OutboundSelector:
+		return new(SelectorOptions)
+	case OutboundURLTest:
+		return new(URLTestOptions)
+	case OutboundVLESS:
+		return new(VLESSOptions)
+	case OutboundShadowsocks:
+		return new(ShadowsocksOptions)
+	case OutboundTrojan:
+		return new(TrojanOptions)
+	case OutboundHysteria2:
+		return new(Hysteria2Options)
+	case OutboundTUIC:
+		return new(TUICOptions)
+	case OutboundWireGuard:
+		return new(WireGuardOptions)
+	case OutboundSOCKS:
+		return new(SOCKSOptions)
+	case OutboundHTTP:
+		return new(HTTPOptions)
+	default:
+		return nil
+	}
+}
+
+// OptionsMatch reports whether the options are of the outbound type, the types the model knows must have them
+func (o *Outbound) OptionsMatch() bool {
+	if o.Options == nil {
+		return newOutboundOptions(o.Type) == nil
+	}
+
+	return o.Options.outboundType() == o.Type
+}
+
+func (o *Outbound) UnmarshalJSON(b []byte) error {
+	type plain Outbound
+	if err := unmarshalObject(b, &o.raw, (*plain)(o)); err != nil {
+		return err
+	}
+
+	o.Options = newOutboundOptions(o.Type)
+	if o.Options == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, o.Options)
+}
+
+func (o Outbound) MarshalJSON() ([]byte, error) {
+	type plain Outbound
+	return marshalObject(&o.raw, (*plain)(&o), o.Options)
+}
+
+type ServerOptions struct {
+	Server     string `json:"server,omitempty"`
+	ServerPort int    `json:"server_port,omitempty"`
+}
+
+type SelectorOptions struct {
+	Outbounds []string `json:"outbounds"`
+	Default   string   `json:"default,omitempty"`
+}
+
+type URLTestOptions struct {
+	Outbounds []string `json:"outbounds"`
+	URL       string   `json:"url,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+	Tolerance int      `json:"tolerance,omitempty"`
+}
+
+type VLESSOptions struct {
+	ServerOptions
+	UUID           string     `json:"uuid"`
+	Flow           string     `json:"flow,omitempty"`
+	PacketEncoding string     `json:"packet_encoding,omitempty"`
+	TLS            *TLS       `json:"tls,omitempty"`
+	Transport      *Transport `json:"transport,omitempty"`
+}
+
+type ShadowsocksOptions struct {
+	ServerOptions
+	Method     string `json:"method"`
+	Password   string `json:"password"`
+	Plugin     string `json:"plugin,omitempty"`
+	PluginOpts string `json:"plugin_opts,omitempty"`
+}
+
+type TrojanOptions struct {
+	ServerOptions
+	Password  string     `json:"password"`
+	TLS       *TLS       `json:"tls,omitempty"`
+	Transport *Transport `json:"transport,omitempty"`
+}
+
+type Hysteria2Options struct {
+	ServerOptions
+	Password string         `json:"password,omitempty"`
+	UpMbps   int            `json:"up_mbps,omitempty"`
+	DownMbps int            `json:"down_mbps,omitempty"`
+	Obfs     *Hysteria2Obfs `json:"obfs,omitempty"`
+	TLS      *TLS           `json:"tls,omitempty"`
+}
+
+type Hysteria2Obfs struct {
+	Type     string `json:"type"`
+	Password string `json:"password"`
+
+	raw rawObject
+}
+
+const (
+	TransportHTTP        = "http"
+	TransportWebSocket   = "ws"
+	TransportGRPC        = "grpc"
+	TransportHTTPUpgrade = "httpupgrade"
+)
+
+// Transport is the V2Ray transport, the fields are used by the types as sing-box documents them,
+// Host is a list of hosts for http and a single one for httpupgrade
+type Transport struct {
+	Type                string         `json:"type"`
+	Host                any            `json:"host,omitempty"`
+	Path                string         `json:"path,omitempty"`
+	Headers             map[string]any `json:"headers,omitempty"`
+	ServiceName         string         `json:"service_name,omitempty"`
+	MaxEarlyData        int            `json:"max_early_data,omitempty"`
+	EarlyDataHeaderName string         `json:"early_data_header_name,omitempty"`
+
+	raw rawObject
+}
+
+type TUICOptions struct {
+	ServerOptions
+	UUID              string `json:"uuid"`
+	Password          string `json:"password,omitempty"`
+	CongestionControl string `json:"congestion_control,omitempty"`
+	UDPRelayMode      string `json:"udp_relay_mode,omitempty"`
+	TLS               *TLS   `json:"tls,omitempty"`
+}
+
+// WireGuardOptions has either the server and the peer keys or the peers
+type WireGuardOptions struct {
+	ServerOptions
+	LocalAddress  []string        `json:"local_address"`
+	PrivateKey    string          `json:"private_key"`
+	PeerPublicKey string          `json:"peer_public_key,omitempty"`
+	PreSharedKey  string          `json:"pre_shared_key,omitempty"`
+	Peers         []WireGuardPeer `json:"peers,omitempty"`
+	Reserved      []int           `json:"reserved,omitempty"`
+	MTU           int             `json:"mtu,omitempty"`
+}
+
+type WireGuardPeer struct {
+	ServerOptions
+	PublicKey    string   `json:"public_key"`
+	PreSharedKey string   `json:"pre_shared_key,omitempty"`
+	AllowedIPs   []string `json:"allowed_ips,omitempty"`
+	Reserved     []int    `json:"reserved,omitempty"`
+
+	raw rawObject
+}
+
+type SOCKSOptions struct {
+	ServerOptions
+	Version  string `json:"version,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type HTTPOptions struct {
+	ServerOptions
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Path     string `json:"path,omitempty"`
+	TLS      *TLS   `json:"tls,omitempty"`
+}
+
+func (*SelectorOptions) outboundType() string    { return OutboundSelector }
+func (*URLTestOptions) outboundType() string     { return OutboundURLTest }
+func (*VLESSOptions) outboundType() string       { return OutboundVLESS }
+func (*ShadowsocksOptions) outboundType() string { return OutboundShadowsocks }
+func (*TrojanOptions) outboundType() string      { return OutboundTrojan }
+func (*Hysteria2Options) outboundType() string   { return OutboundHysteria2 }
+func (*TUICOptions) outboundType() string        { return OutboundTUIC }
+func (*WireGuardOptions) outboundType() string   { return OutboundWireGuard }
+func (*SOCKSOptions) outboundType() string       { return OutboundSOCKS }
+func (*HTTPOptions) outboundType() string        { return OutboundHTTP }
+
+// Server returns the server of the outbound types connecting to one
+func (o *Outbound) Server() (*ServerOptions, bool) {
+	switch opts := o.Options.(type) {
+	case *VLESSOptions:
+		return &opts.ServerOptions, true
+	case *ShadowsocksOptions:
+		return &opts.ServerOptions, true
+	case *TrojanOptions:
+		return &opts.ServerOptions, true
+	case *Hysteria2Options:
+		return &opts.ServerOptions, true
+	case *TUICOptions:
+		return &opts.ServerOptions, true
+	case *WireGuardOptions:
+		// The servers are in the peers when there are any
+		return &opts.ServerOptions, len(opts.Peers) == 0
+	case *SOCKSOptions:
+		return &opts.ServerOptions, true
+	case *HTTPOptions:
+		return &opts.ServerOptions, true
+	default:
+		return nil, false
+	}
+}
+
+// TLS returns the TLS options of the outbound types supporting TLS, nil when they are not set
+func (o *Outbound) TLS() *TLS {
+	switch opts := o.Options.(type) {
+	case *VLESSOptions:
+		return opts.TLS
+	case *TrojanOptions:
+		return opts.TLS
+	case *Hysteria2Options:
+		return opts.TLS
+	case *TUICOptions:
+		return opts.TLS
+	case *HTTPOptions:
+		return opts.TLS
+	default:
+		return nil
+	}
+}
+
+// Members returns the outbounds of a group
+func (o *Outbound) Members() []string {
+	switch opts := o.Options.(type) {
+	case *SelectorOptions:
+		return opts.Outbounds
+	case *URLTestOptions:
+		return opts.Outbounds
+	default:
+		return nil
+	}
+}
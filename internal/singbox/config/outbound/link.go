@@ -0,0 +1,324 @@
+package outbound
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+var errEmptyLink = apperr.NewFieldValidationErr("OutboundLink_Empty", "link", "link is empty")
+
+func errInvalidLink(reason string) apperr.Err {
+	return apperr.NewFieldValidationErr("OutboundLink_Invalid", "link", fmt.Sprintf("link is invalid, %s", reason))
+}
+
+func errUnsupportedScheme(scheme string) apperr.Err {
+	return apperr.NewFieldValidationErr("OutboundLink_UnsupportedScheme", "link", fmt.Sprintf("scheme '%s' is not supported, expected 'vless', 'ss', 'trojan' or 'hysteria2'", scheme))
+}
+
+func errUnsupportedParam(name, value string) apperr.Err {
+	return apperr.NewFieldValidationErr("OutboundLink_UnsupportedParam", "link", fmt.Sprintf("%s '%s' is not supported", name, value))
+}
+
+// ParseLink makes an outbound from a share link, the tag is made from the link name
+// or from the protocol and the server when the link has no name
+func ParseLink(link string) (*config.Outbound, apperr.Err) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return nil, errEmptyLink
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, errInvalidLink(err.Error())
+	}
+
+	var options config.OutboundOptions
+	var appErr apperr.Err
+	switch strings.ToLower(u.Scheme) {
+	case "vless":
+		options, appErr = parseVLESS(u)
+	case "ss":
+		options, appErr = parseShadowsocks(u)
+	case "trojan":
+		options, appErr = parseTrojan(u)
+	case "hysteria2", "hy2":
+		options, appErr = parseHysteria2(u)
+	default:
+		return nil, errUnsupportedScheme(u.Scheme)
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	o := config.NewOutbound("", options)
+	o.Tag = MakeTag(u.Fragment, o)
+	return o, nil
+}
+
+func linkServer(u *url.URL) (config.ServerOptions, apperr.Err) {
+	if u.Hostname() == "" {
+		return config.ServerOptions{}, errInvalidLink("server is missing")
+	}
+
+	if u.Port() == "" {
+		return config.ServerOptions{}, errInvalidLink("port is missing")
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return config.ServerOptions{}, errInvalidLink(fmt.Sprintf("port '%s' is not a number", u.Port()))
+	}
+
+	return config.ServerOptions{Server: u.Hostname(), ServerPort: port}, nil
+}
+
+func parseVLESS(u *url.URL) (*config.VLESSOptions, apperr.Err) {
+	server, err := linkServer(u)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	if e := q.Get("encryption"); e != "" && e != "none" {
+		return nil, errUnsupportedParam("encryption", e)
+	}
+
+	o := &config.VLESSOptions{ServerOptions: server, UUID: u.User.Username(), Flow: q.Get("flow"), PacketEncoding: q.Get("packetEncoding")}
+	if o.TLS, err = linkTLS(q, false); err != nil {
+		return nil, err
+	}
+
+	if o.Transport, err = linkTransport(q); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func parseTrojan(u *url.URL) (*config.TrojanOptions, apperr.Err) {
+	server, err := linkServer(u)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	o := &config.TrojanOptions{ServerOptions: server, Password: u.User.Username()}
+
+	// Trojan goes over TLS unless the link turns it off explicitly
+	if o.TLS, err = linkTLS(q, q.Get("security") != "none"); err != nil {
+		return nil, err
+	}
+
+	if o.Transport, err = linkTransport(q); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func parseHysteria2(u *url.URL) (*config.Hysteria2Options, apperr.Err) {
+	server, err := linkServer(u)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	o := &config.Hysteria2Options{ServerOptions: server, Password: u.User.Username()}
+	if p, ok := u.User.Password(); ok {
+		o.Password += ":" + p
+	}
+
+	if obfs := q.Get("obfs"); obfs != "" {
+		o.Obfs = &config.Hysteria2Obfs{Type: obfs, Password: q.Get("obfs-password")}
+	}
+
+	o.TLS, err = linkTLS(q, true)
+	return o, err
+}
+
+// parseShadowsocks takes both SIP002 links and the legacy ones having the whole method:password@server:port in base64
+func parseShadowsocks(u *url.URL) (*config.ShadowsocksOptions, apperr.Err) {
+	if u.User == nil && u.Host != "" && u.Port() == "" {
+		decoded, ok := decodeBase64(u.Host + u.Path)
+		if !ok {
+			return nil, errInvalidLink("user info is missing")
+		}
+
+		legacy, err := url.Parse("ss://" + decoded)
+		if err != nil || legacy.User == nil {
+			return nil, errInvalidLink("legacy link has no method and password")
+		}
+
+		legacy.Fragment = u.Fragment
+		u = legacy
+	}
+
+	server, err := linkServer(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.User == nil {
+		return nil, errInvalidLink("method and password are missing")
+	}
+
+	method := u.User.Username()
+	password, ok := u.User.Password()
+	if !ok {
+		decoded, decodedOk := decodeBase64(method)
+		method, password, ok = strings.Cut(decoded, ":")
+		if !decodedOk || !ok {
+			return nil, errInvalidLink("user info is not method:password")
+		}
+	}
+
+	o := &config.ShadowsocksOptions{ServerOptions: server, Method: method, Password: password}
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		o.Plugin, o.PluginOpts, _ = strings.Cut(plugin, ";")
+	}
+
+	return o, nil
+}
+
+func decodeBase64(s string) (string, bool) {
+	s = strings.TrimRight(s, "=")
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.RawStdEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return string(b), true
+		}
+	}
+
+	return "", false
+}
+
+func linkTLS(q url.Values, enabled bool) (*config.TLS, apperr.Err) {
+	security := q.Get("security")
+	switch security {
+	case "tls", "reality":
+	case "", "none":
+		if !enabled {
+			return nil, nil
+		}
+	default:
+		return nil, errUnsupportedParam("security", security)
+	}
+
+	t := &config.TLS{Enabled: true, ServerName: q.Get("sni"), Insecure: isTrue(q.Get("allowInsecure")) || isTrue(q.Get("insecure"))}
+	if alpn := q.Get("alpn"); alpn != "" {
+		t.ALPN = strings.Split(alpn, ",")
+	}
+
+	if fp := q.Get("fp"); fp != "" {
+		t.UTLS = &config.UTLS{Enabled: true, Fingerprint: fp}
+	}
+
+	if security == "reality" {
+		t.Reality = &config.Reality{Enabled: true, PublicKey: q.Get("pbk"), ShortID: q.Get("sid")}
+
+		// sing-box refuses Reality without uTLS
+		if t.UTLS == nil {
+			t.UTLS = &config.UTLS{Enabled: true, Fingerprint: "chrome"}
+		}
+	}
+
+	return t, nil
+}
+
+func linkTransport(q url.Values) (*config.Transport, apperr.Err) {
+	path, host := q.Get("path"), q.Get("host")
+
+	switch network := q.Get("type"); network {
+	case "", "tcp":
+		return nil, nil
+	case "ws":
+		t := &config.Transport{Type: config.TransportWebSocket, Path: path}
+		if host != "" {
+			t.Headers = map[string]any{"Host": host}
+		}
+
+		// Xray keeps the early data size in the path, sing-box has the options for it
+		if p, query, ok := strings.Cut(path, "?"); ok {
+			if values, err := url.ParseQuery(query); err == nil && values.Has("ed") {
+				if ed, err := strconv.Atoi(values.Get("ed")); err == nil {
+					t.Path, t.MaxEarlyData, t.EarlyDataHeaderName = p, ed, "Sec-WebSocket-Protocol"
+				}
+			}
+		}
+
+		return t, nil
+	case "grpc":
+		return &config.Transport{Type: config.TransportGRPC, ServiceName: q.Get("serviceName")}, nil
+	case "http", "h2":
+		t := &config.Transport{Type: config.TransportHTTP, Path: path}
+		if host != "" {
+			t.Host = strings.Split(host, ",")
+		}
+
+		return t, nil
+	case "httpupgrade":
+		t := &config.Transport{Type: config.TransportHTTPUpgrade, Path: path}
+		if host != "" {
+			t.Host = host
+		}
+
+		return t, nil
+	default:
+		return nil, errUnsupportedParam("transport", network)
+	}
+}
+
+func isTrue(s string) bool {
+	return s == "1" || strings.EqualFold(s, "true")
+}
+
+// MakeTag makes a tag of the name keeping the ASCII letters and digits only,
+// the tag is made from the protocol and the server when nothing is left
+func MakeTag(name string, o *config.Outbound) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	if b.Len() > 0 {
+		return b.String()
+	}
+
+	if server, ok := o.Server(); ok {
+		return o.Type + "-" + server.Server
+	}
+
+	return o.Type
+}
+
+// UniqueTag adds a number to the tag when the configuration already has an outbound with it
+func UniqueTag(c *config.Conf, tag string) string {
+	unique := tag
+	for n := 2; index(c, unique) != -1; n++ {
+		unique = fmt.Sprintf("%s-%d", tag, n)
+	}
+
+	return unique
+}
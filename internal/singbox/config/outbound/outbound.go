@@ -0,0 +1,142 @@
+package outbound
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func errTagExists(tag string) apperr.Err {
+	return apperr.NewConflictErr("Outbound_TagExists", fmt.Sprintf("outbound '%s' already exists", tag))
+}
+
+func errNotFound(tag string) apperr.Err {
+	return apperr.NewNotFoundErr("Outbound_NotFound", fmt.Sprintf("outbound '%s' is not found", tag))
+}
+
+func errInUse(tag string, refs []string) apperr.Err {
+	return apperr.NewConflictErr("Outbound_InUse", fmt.Sprintf("outbound '%s' is referenced by %s", tag, strings.Join(refs, ", ")))
+}
+
+func errTagMismatch(tag, bodyTag string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_TagMismatch", "tag", fmt.Sprintf("tag '%s' does not match the outbound '%s', renaming is not supported", bodyTag, tag))
+}
+
+func index(c *config.Conf, tag string) int {
+	return slices.IndexFunc(c.Outbounds, func(o *config.Outbound) bool {
+		return o.Tag == tag
+	})
+}
+
+func Get(c *config.Conf, tag string) (*config.Outbound, apperr.Err) {
+	i := index(c, tag)
+	if i == -1 {
+		return nil, errNotFound(tag)
+	}
+
+	return c.Outbounds[i], nil
+}
+
+func Add(c *config.Conf, o *config.Outbound) apperr.Err {
+	if err := Validate(o); err != nil {
+		return err
+	}
+
+	if index(c, o.Tag) != -1 {
+		return errTagExists(o.Tag)
+	}
+
+	c.Outbounds = append(c.Outbounds, o)
+	return nil
+}
+
+// Update replaces the outbound keeping its position, the tag of the replacement may be omitted
+func Update(c *config.Conf, tag string, o *config.Outbound) apperr.Err {
+	if o.Tag == "" {
+		o.Tag = tag
+	} else if o.Tag != tag {
+		return errTagMismatch(tag, o.Tag)
+	}
+
+	if err := Validate(o); err != nil {
+		return err
+	}
+
+	i := index(c, tag)
+	if i == -1 {
+		return errNotFound(tag)
+	}
+
+	c.Outbounds[i] = o
+	return nil
+}
+
+// Remove refuses to remove the outbound that is still referenced, sing-box would not start without it
+func Remove(c *config.Conf, tag string) apperr.Err {
+	i := index(c, tag)
+	if i == -1 {
+		return errNotFound(tag)
+	}
+
+	if refs := References(c, tag); len(refs) > 0 {
+		return errInUse(tag, refs)
+	}
+
+	c.Outbounds = slices.Delete(c.Outbounds, i, i+1)
+	return nil
+}
+
+// References lists the places of the configuration using the outbound
+func References(c *config.Conf, tag string) []string {
+	var refs []string
+	for i := range c.Route.Rules {
+		refs = append(refs, ruleReferences(&c.Route.Rules[i], fmt.Sprintf("route.rules[%d]", i), tag)...)
+	}
+
+	if c.Route.Final == tag {
+		refs = append(refs, "route.final")
+	}
+
+	for i, rs := range c.Route.RuleSet {
+		if rs.DownloadDetour == tag {
+			refs = append(refs, fmt.Sprintf("route.rule_set[%d].download_detour", i))
+		}
+	}
+
+	for _, o := range c.Outbounds {
+		if o.Tag == tag {
+			continue
+		}
+
+		if slices.Contains(o.Members(), tag) {
+			refs = append(refs, fmt.Sprintf("outbound '%s'", o.Tag))
+		} else if o.Detour == tag {
+			refs = append(refs, fmt.Sprintf("outbound '%s' detour", o.Tag))
+		}
+	}
+
+	for i, s := range c.DNS.Servers {
+		if s.Detour == tag {
+			refs = append(refs, fmt.Sprintf("dns.servers[%d].detour", i))
+		}
+	}
+
+	return refs
+}
+
+// ruleReferences looks into the rules of the logical rules too
+func ruleReferences(rr *config.RouteRule, path, tag string) []string {
+	var refs []string
+	if rr.Outbound == tag {
+		refs = append(refs, path)
+	}
+
+	for i := range rr.Rules {
+		refs = append(refs, ruleReferences(&rr.Rules[i], fmt.Sprintf("%s.rules[%d]", path, i), tag)...)
+	}
+
+	return refs
+}
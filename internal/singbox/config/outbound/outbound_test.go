@@ -0,0 +1,236 @@
+package outbound
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func loadTestConf(t *testing.T) *config.Conf {
+	t.Helper()
+
+	data, err := os.ReadFile("../testdata/config.json")
+	require.NoError(t, err)
+
+	var c config.Conf
+	require.NoError(t, json.Unmarshal(data, &c))
+	return &c
+}
+
+func vless() *config.Outbound {
+	return config.NewOutbound("proxy-nl", &config.VLESSOptions{
+		ServerOptions: config.ServerOptions{Server: "198.51.100.1", ServerPort: 443},
+		UUID:          "bf000d23-0752-40b4-affe-68f7707a9661",
+		Flow:          "xtls-rprx-vision",
+		TLS: &config.TLS{
+			Enabled:    true,
+			ServerName: "www.microsoft.com",
+			UTLS:       &config.UTLS{Enabled: true, Fingerprint: "chrome"},
+			Reality:    &config.Reality{Enabled: true, PublicKey: "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0", ShortID: "0123"},
+		},
+	})
+}
+
+func vlessOpts(o *config.Outbound) *config.VLESSOptions {
+	return o.Options.(*config.VLESSOptions)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(o *config.Outbound)
+		expected apperr.Err
+	}{
+		{"Valid", func(o *config.Outbound) {}, nil},
+		{"Direct", func(o *config.Outbound) { *o = config.Outbound{Type: "direct", Tag: "direct"} }, nil},
+		{"UnknownType", func(o *config.Outbound) { *o = config.Outbound{Type: "anytls", Tag: "any"} }, nil},
+		{"EmptyTag", func(o *config.Outbound) { o.Tag = "" }, errEmptyTag},
+		{"TagWithSpaces", func(o *config.Outbound) { o.Tag = "proxy nl" }, errInvalidTag("proxy nl")},
+		{"EmptyType", func(o *config.Outbound) { o.Type = "" }, errEmptyType},
+		{"TypeMismatch", func(o *config.Outbound) { o.Type = "trojan" }, errOptionsMatch},
+		{"NoOptions", func(o *config.Outbound) { o.Options = nil }, errOptionsMatch},
+		{"EmptyServer", func(o *config.Outbound) { vlessOpts(o).Server = " " }, errEmptyServer},
+		{"NoPort", func(o *config.Outbound) { vlessOpts(o).ServerPort = 0 }, errInvalidPort(0)},
+		{"PortOutOfRange", func(o *config.Outbound) { vlessOpts(o).ServerPort = 65536 }, errInvalidPort(65536)},
+		{"InvalidUUID", func(o *config.Outbound) { vlessOpts(o).UUID = "bf000d23-0752-40b4-affe" }, errInvalidUUID("bf000d23-0752-40b4-affe")},
+		{"InvalidFlow", func(o *config.Outbound) { vlessOpts(o).Flow = "xtls-rprx-direct" }, errNotOneOf("Outbound_InvalidFlow", "flow", "flow", "xtls-rprx-direct", flows)},
+		{"InvalidPacketEncoding", func(o *config.Outbound) { vlessOpts(o).PacketEncoding = "none" }, errNotOneOf("Outbound_InvalidPacketEncoding", "packet_encoding", "packet encoding", "none", packetEncodings)},
+		{"RealityWithoutTLS", func(o *config.Outbound) { vlessOpts(o).TLS.Enabled = false }, errRealityTLS},
+		{"PublicKey_NotBase64", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.PublicKey = "not a key" }, errInvalidPublicKey("not a key")},
+		{"PublicKey_Short", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.PublicKey = "jNXHt1yRo0vDuchQ" }, errInvalidPublicKey("jNXHt1yRo0vDuchQ")},
+		{"ShortID_Empty", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.ShortID = "" }, nil},
+		{"ShortID_OddLength", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.ShortID = "123" }, errInvalidShortID("123")},
+		{"ShortID_NotHex", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.ShortID = "zz" }, errInvalidShortID("zz")},
+		{"ShortID_TooLong", func(o *config.Outbound) { vlessOpts(o).TLS.Reality.ShortID = "0123456789abcdef01" }, errInvalidShortID("0123456789abcdef01")},
+		{"RealityDisabled", func(o *config.Outbound) { vlessOpts(o).TLS.Reality = &config.Reality{} }, nil},
+		{"UnknownFingerprint", func(o *config.Outbound) { vlessOpts(o).TLS.UTLS.Fingerprint = "opera" }, errNotOneOf("Outbound_UnknownFingerprint", "tls.utls.fingerprint", "uTLS fingerprint", "opera", fingerprints)},
+		{"DefaultFingerprint", func(o *config.Outbound) { vlessOpts(o).TLS.UTLS.Fingerprint = "" }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := vless()
+			tt.modify(o)
+			assert.Equal(t, tt.expected, Validate(o))
+		})
+	}
+}
+
+func TestValidate_Protocols(t *testing.T) {
+	server := config.ServerOptions{Server: "198.51.100.1", ServerPort: 443}
+	tls := &config.TLS{Enabled: true, ServerName: "example.com"}
+	key16 := "MDEyMzQ1Njc4OWFiY2RlZg=="
+	key32 := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	tests := []struct {
+		name     string
+		options  config.OutboundOptions
+		expected apperr.Err
+	}{
+		{"Selector", &config.SelectorOptions{Outbounds: []string{"proxy", "direct"}, Default: "proxy"}, nil},
+		{"Selector_Empty", &config.SelectorOptions{}, errEmptyMembers},
+		{"Selector_UnknownDefault", &config.SelectorOptions{Outbounds: []string{"proxy"}, Default: "direct"}, errUnknownDefault("direct")},
+		{"URLTest_Empty", &config.URLTestOptions{}, errEmptyMembers},
+		{"Shadowsocks", &config.ShadowsocksOptions{ServerOptions: server, Method: "aes-256-gcm", Password: "secret"}, nil},
+		{"Shadowsocks_None", &config.ShadowsocksOptions{ServerOptions: server, Method: "none"}, nil},
+		{"Shadowsocks_EmptyPassword", &config.ShadowsocksOptions{ServerOptions: server, Method: "aes-256-gcm"}, errEmptyPassword},
+		{"Shadowsocks_UnknownMethod", &config.ShadowsocksOptions{ServerOptions: server, Method: "aes-512-gcm", Password: "secret"}, errNotOneOf("Outbound_InvalidMethod", "method", "method", "aes-512-gcm", ssMethods)},
+		{"Shadowsocks2022_128", &config.ShadowsocksOptions{ServerOptions: server, Method: "2022-blake3-aes-128-gcm", Password: key16}, nil},
+		{"Shadowsocks2022_256_MultiUser", &config.ShadowsocksOptions{ServerOptions: server, Method: "2022-blake3-aes-256-gcm", Password: key32 + ":" + key32}, nil},
+		{"Shadowsocks2022_WrongKeySize", &config.ShadowsocksOptions{ServerOptions: server, Method: "2022-blake3-chacha20-poly1305", Password: key16}, errInvalid2022Key("2022-blake3-chacha20-poly1305", 32)},
+		{"Shadowsocks2022_NotBase64", &config.ShadowsocksOptions{ServerOptions: server, Method: "2022-blake3-aes-128-gcm", Password: "secret"}, errInvalid2022Key("2022-blake3-aes-128-gcm", 16)},
+		{"Shadowsocks_NoServer", &config.ShadowsocksOptions{Method: "none"}, errEmptyServer},
+		{"Trojan", &config.TrojanOptions{ServerOptions: server, Password: "secret", TLS: tls}, nil},
+		{"Trojan_EmptyPassword", &config.TrojanOptions{ServerOptions: server, TLS: tls}, errEmptyPassword},
+		{"Trojan_WebSocket", &config.TrojanOptions{ServerOptions: server, Password: "secret", TLS: tls, Transport: &config.Transport{Type: "ws", Path: "/ws"}}, nil},
+		{"Trojan_UnknownTransport", &config.TrojanOptions{ServerOptions: server, Password: "secret", TLS: tls, Transport: &config.Transport{Type: "xhttp"}}, errNotOneOf("Outbound_InvalidTransport", "transport.type", "transport", "xhttp", transportTypes)},
+		{"Hysteria2", &config.Hysteria2Options{ServerOptions: server, Password: "secret", UpMbps: 100, Obfs: &config.Hysteria2Obfs{Type: "salamander", Password: "obfs"}, TLS: tls}, nil},
+		{"Hysteria2_NoTLS", &config.Hysteria2Options{ServerOptions: server, Password: "secret"}, errTLSRequired},
+		{"Hysteria2_NegativeBandwidth", &config.Hysteria2Options{ServerOptions: server, DownMbps: -1, TLS: tls}, errNegativeBandwidth("down_mbps")},
+		{"Hysteria2_UnknownObfs", &config.Hysteria2Options{ServerOptions: server, Obfs: &config.Hysteria2Obfs{Type: "xor"}, TLS: tls}, errNotOneOf("Outbound_InvalidObfs", "obfs.type", "obfuscation", "xor", obfsTypes)},
+		{"Hysteria2_EmptyObfsPassword", &config.Hysteria2Options{ServerOptions: server, Obfs: &config.Hysteria2Obfs{Type: "salamander"}, TLS: tls}, errEmptyObfsPass},
+		{"TUIC", &config.TUICOptions{ServerOptions: server, UUID: "bf000d23-0752-40b4-affe-68f7707a9661", CongestionControl: "bbr", UDPRelayMode: "quic", TLS: tls}, nil},
+		{"TUIC_InvalidUUID", &config.TUICOptions{ServerOptions: server, UUID: "user", TLS: tls}, errInvalidUUID("user")},
+		{"TUIC_UnknownCongestionControl", &config.TUICOptions{ServerOptions: server, UUID: "bf000d23-0752-40b4-affe-68f7707a9661", CongestionControl: "reno", TLS: tls}, errNotOneOf("Outbound_InvalidCongestionControl", "congestion_control", "congestion control", "reno", congestionControl)},
+		{"TUIC_NoTLS", &config.TUICOptions{ServerOptions: server, UUID: "bf000d23-0752-40b4-affe-68f7707a9661", TLS: &config.TLS{}}, errTLSRequired},
+		{"WireGuard", &config.WireGuardOptions{ServerOptions: server, LocalAddress: []string{"172.16.0.2/32", "fd00::2/128"}, PrivateKey: key32, PeerPublicKey: key32}, nil},
+		{"WireGuard_NoAddress", &config.WireGuardOptions{ServerOptions: server, PrivateKey: key32, PeerPublicKey: key32}, errEmptyAddress},
+		{"WireGuard_InvalidAddress", &config.WireGuardOptions{ServerOptions: server, LocalAddress: []string{"172.16.0.2"}, PrivateKey: key32, PeerPublicKey: key32}, errInvalidLocalAddress("172.16.0.2")},
+		{"WireGuard_InvalidPrivateKey", &config.WireGuardOptions{ServerOptions: server, LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key16, PeerPublicKey: key32}, errInvalidWireGuardKey("private_key")},
+		{"WireGuard_InvalidPreSharedKey", &config.WireGuardOptions{ServerOptions: server, LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, PeerPublicKey: key32, PreSharedKey: "psk"}, errInvalidWireGuardKey("pre_shared_key")},
+		{"WireGuard_Peers", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, Peers: []config.WireGuardPeer{{ServerOptions: server, PublicKey: key32, AllowedIPs: []string{"0.0.0.0/0", "::/0"}}}}, nil},
+		{"WireGuard_NoPeerServer", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, Peers: []config.WireGuardPeer{{ServerOptions: server, PublicKey: key32}, {PublicKey: key32}}}, apperr.NewFieldValidationErr("Outbound_EmptyServer", "peers[1].server", "server is empty")},
+		{"WireGuard_InvalidPeerPort", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, Peers: []config.WireGuardPeer{{ServerOptions: config.ServerOptions{Server: "198.51.100.1"}, PublicKey: key32}}}, apperr.NewFieldValidationErr("Outbound_InvalidPort", "peers[0].server_port", "port 0 is out of the range 1-65535")},
+		{"WireGuard_InvalidPeerKey", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, Peers: []config.WireGuardPeer{{ServerOptions: server, PublicKey: key16}}}, errInvalidWireGuardKey("peers[0].public_key")},
+		{"WireGuard_InvalidAllowedIP", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, Peers: []config.WireGuardPeer{{ServerOptions: server, PublicKey: key32, AllowedIPs: []string{"10.0.0.1"}}}}, errInvalidAllowedIP("peers[0].allowed_ips", "10.0.0.1")},
+		{"WireGuard_NoServer", &config.WireGuardOptions{LocalAddress: []string{"172.16.0.2/32"}, PrivateKey: key32, PeerPublicKey: key32}, errEmptyServer},
+		{"SOCKS", &config.SOCKSOptions{ServerOptions: server, Version: "5", Username: "user", Password: "secret"}, nil},
+		{"SOCKS_UnknownVersion", &config.SOCKSOptions{ServerOptions: server, Version: "6"}, errNotOneOf("Outbound_InvalidVersion", "version", "SOCKS version", "6", socksVersions)},
+		{"HTTP", &config.HTTPOptions{ServerOptions: server, TLS: tls}, nil},
+		{"HTTP_InvalidPort", &config.HTTPOptions{ServerOptions: config.ServerOptions{Server: "198.51.100.1", ServerPort: -1}}, errInvalidPort(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Validate(config.NewOutbound("out", tt.options)))
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	c := loadTestConf(t)
+	count := len(c.Outbounds)
+
+	assert.Nil(t, Add(c, vless()))
+	assert.Len(t, c.Outbounds, count+1)
+	assert.Equal(t, "proxy-nl", c.Outbounds[count].Tag)
+
+	assert.Equal(t, errTagExists("proxy-nl"), Add(c, vless()))
+
+	invalid := vless()
+	invalid.Tag = "proxy-us"
+	vlessOpts(invalid).UUID = ""
+	assert.Equal(t, errInvalidUUID(""), Add(c, invalid))
+	assert.Len(t, c.Outbounds, count+1)
+}
+
+func TestUpdate(t *testing.T) {
+	c := loadTestConf(t)
+
+	o := vless()
+	o.Tag = ""
+	vlessOpts(o).Server = "198.51.100.2"
+	assert.Nil(t, Update(c, "proxy", o))
+
+	updated, err := Get(c, "proxy")
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.2", vlessOpts(updated).Server)
+	assert.Equal(t, "proxy", c.Outbounds[0].Tag)
+
+	assert.Equal(t, errTagMismatch("proxy", "proxy-nl"), Update(c, "proxy", vless()))
+	assert.Equal(t, errNotFound("proxy-nl"), Update(c, "proxy-nl", vless()))
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		route    string
+		expected []string
+	}{
+		{
+			"RuleSetDownloadDetour",
+			`{"rule_set":[{"tag":"geoip-ru","type":"remote","format":"binary","url":"https://example.com/geoip-ru.srs"},
+				{"tag":"geosite-ru","type":"remote","format":"binary","url":"https://example.com/geosite-ru.srs","download_detour":"direct"}]}`,
+			[]string{"route.rule_set[1].download_detour"},
+		},
+		{
+			"LogicalRule",
+			`{"rules":[{"type":"logical","mode":"and","rules":[{"protocol":"quic"},{"type":"logical","mode":"or","rules":[{"port":443}],"outbound":"direct"}],"outbound":"direct"}]}`,
+			[]string{"route.rules[0]", "route.rules[0].rules[1]"},
+		},
+		{
+			"NestedOnly",
+			`{"rules":[{"action":"sniff"},{"type":"logical","mode":"or","rules":[{"port":853,"outbound":"direct"}],"action":"reject"}]}`,
+			[]string{"route.rules[1].rules[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := new(config.Conf)
+			require.NoError(t, json.Unmarshal([]byte(`{"route":`+tt.route+`}`), c))
+			assert.Equal(t, tt.expected, References(c, "direct"))
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c := loadTestConf(t)
+	c.DNS.Servers[0].Detour = "proxy"
+
+	tests := []struct {
+		name     string
+		tag      string
+		expected apperr.Err
+	}{
+		{"Rules_Final_Selector_DNSDetour", "proxy", errInUse("proxy", []string{"route.rules[4]", "route.final", "outbound 'select'", "dns.servers[0].detour"})},
+		{"Selector", "proxy-ws", errInUse("proxy-ws", []string{"outbound 'select'"})},
+		{"Rules_Selector_DNSDetour", "direct", errInUse("direct", []string{"route.rules[2]", "route.rules[5]", "route.rules[6]", "route.rule_set[0].download_detour", "outbound 'select'", "dns.servers[1].detour"})},
+		{"NotFound", "proxy-nl", errNotFound("proxy-nl")},
+		{"Unreferenced", "block", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Remove(c, tt.tag))
+		})
+	}
+
+	_, err := Get(c, "block")
+	assert.Equal(t, errNotFound("block"), err)
+}
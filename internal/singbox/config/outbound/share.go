@@ -0,0 +1,135 @@
+package outbound
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func errShareUnsupported(t string) apperr.Err {
+	return apperr.NewValidationErr("Outbound_ShareUnsupported", fmt.Sprintf("outbound type '%s' has no share link, expected 'vless', 'shadowsocks', 'trojan' or 'hysteria2'", t))
+}
+
+// Link makes the share link of the outbound, the reverse of ParseLink
+func Link(o *config.Outbound) (string, apperr.Err) {
+	q := url.Values{}
+	var scheme string
+	var user *url.Userinfo
+
+	switch opts := o.Options.(type) {
+	case *config.VLESSOptions:
+		scheme, user = "vless", url.User(opts.UUID)
+		q.Set("encryption", "none")
+		setParam(q, "flow", opts.Flow)
+		setParam(q, "packetEncoding", opts.PacketEncoding)
+		shareTLS(q, opts.TLS, "allowInsecure")
+		shareTransport(q, opts.Transport)
+	case *config.ShadowsocksOptions:
+		scheme, user = "ss", shadowsocksUser(opts)
+		if opts.Plugin != "" {
+			q.Set("plugin", strings.TrimSuffix(opts.Plugin+";"+opts.PluginOpts, ";"))
+		}
+	case *config.TrojanOptions:
+		scheme, user = "trojan", url.User(opts.Password)
+		if opts.TLS == nil || !opts.TLS.Enabled {
+			q.Set("security", "none")
+		}
+
+		shareTLS(q, opts.TLS, "allowInsecure")
+		shareTransport(q, opts.Transport)
+	case *config.Hysteria2Options:
+		scheme, user = "hysteria2", url.User(opts.Password)
+		if opts.Obfs != nil && opts.Obfs.Type != "" {
+			q.Set("obfs", opts.Obfs.Type)
+			q.Set("obfs-password", opts.Obfs.Password)
+		}
+
+		// Hysteria2 always goes over TLS, its links have no security
+		shareTLS(q, opts.TLS, "insecure")
+		q.Del("security")
+	default:
+		return "", errShareUnsupported(o.Type)
+	}
+
+	server, _ := o.Server()
+	u := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     net.JoinHostPort(server.Server, strconv.Itoa(server.ServerPort)),
+		RawQuery: q.Encode(),
+		Fragment: o.Tag,
+	}
+
+	return u.String(), nil
+}
+
+func setParam(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+// shadowsocksUser encodes the user info in base64 as SIP002 says, except the 2022 methods keeping it as is
+func shadowsocksUser(o *config.ShadowsocksOptions) *url.Userinfo {
+	if _, ok := ss2022Methods[o.Method]; ok {
+		return url.UserPassword(o.Method, o.Password)
+	}
+
+	return url.User(base64.RawURLEncoding.EncodeToString([]byte(o.Method + ":" + o.Password)))
+}
+
+func shareTLS(q url.Values, t *config.TLS, insecure string) {
+	if t == nil || !t.Enabled {
+		return
+	}
+
+	q.Set("security", "tls")
+	setParam(q, "sni", t.ServerName)
+	setParam(q, "alpn", strings.Join(t.ALPN, ","))
+	if t.Insecure {
+		q.Set(insecure, "1")
+	}
+
+	if t.UTLS != nil && t.UTLS.Enabled {
+		setParam(q, "fp", t.UTLS.Fingerprint)
+	}
+
+	if r := t.Reality; r != nil && r.Enabled {
+		q.Set("security", "reality")
+		q.Set("pbk", r.PublicKey)
+		setParam(q, "sid", r.ShortID)
+	}
+}
+
+func shareTransport(q url.Values, t *config.Transport) {
+	if t == nil {
+		return
+	}
+
+	q.Set("type", t.Type)
+	switch t.Type {
+	case config.TransportWebSocket:
+		path := t.Path
+		if t.MaxEarlyData > 0 {
+			path += "?ed=" + strconv.Itoa(t.MaxEarlyData)
+		}
+
+		setParam(q, "path", path)
+
+		// sing-box takes the header values as a string or a list, the link has a single host
+		if hosts := config.StringList(t.Headers["Host"]); len(hosts) > 0 {
+			setParam(q, "host", hosts[0])
+		}
+	case config.TransportGRPC:
+		setParam(q, "serviceName", t.ServiceName)
+	case config.TransportHTTP, config.TransportHTTPUpgrade:
+		setParam(q, "path", t.Path)
+		setParam(q, "host", strings.Join(config.StringList(t.Host), ","))
+	}
+}
@@ -0,0 +1,367 @@
+package outbound
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+var (
+	errEmptyTag      = apperr.NewFieldValidationErr("Outbound_EmptyTag", "tag", "tag is empty")
+	errEmptyType     = apperr.NewFieldValidationErr("Outbound_EmptyType", "type", "type is empty")
+	errEmptyServer   = apperr.NewFieldValidationErr("Outbound_EmptyServer", "server", "server is empty")
+	errEmptyPassword = apperr.NewFieldValidationErr("Outbound_EmptyPassword", "password", "password is empty")
+	errEmptyMembers  = apperr.NewFieldValidationErr("Outbound_EmptyOutbounds", "outbounds", "group has no outbounds")
+	errEmptyAddress  = apperr.NewFieldValidationErr("Outbound_EmptyLocalAddress", "local_address", "local address is empty")
+	errTLSRequired   = apperr.NewFieldValidationErr("Outbound_TLSRequired", "tls.enabled", "the protocol requires TLS to be enabled")
+	errRealityTLS    = apperr.NewFieldValidationErr("Outbound_RealityWithoutTLS", "tls.enabled", "Reality requires TLS to be enabled")
+	errEmptyObfsPass = apperr.NewFieldValidationErr("Outbound_EmptyObfsPassword", "obfs.password", "obfuscation password is empty")
+	errOptionsMatch  = apperr.NewFieldValidationErr("Outbound_OptionsMismatch", "type", "outbound options do not match its type")
+)
+
+func errNegativeBandwidth(field string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidBandwidth", field, fmt.Sprintf("%s is negative", field))
+}
+
+func errInvalidTag(tag string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidTag", "tag", fmt.Sprintf("tag '%s' has spaces", tag))
+}
+
+func errInvalidPort(port int) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidPort", "server_port", fmt.Sprintf("port %d is out of the range 1-65535", port))
+}
+
+func errInvalidUUID(uuid string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidUUID", "uuid", fmt.Sprintf("UUID '%s' is invalid", uuid))
+}
+
+func errNotOneOf(code, field, name, value string, allowed []string) apperr.Err {
+	return apperr.NewFieldValidationErr(code, field, fmt.Sprintf("%s '%s' is invalid, expected '%s'", name, value, strings.Join(allowed, "', '")))
+}
+
+func errInvalidPublicKey(key string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidPublicKey", "tls.reality.public_key", fmt.Sprintf("Reality public key '%s' is invalid, expected 32 bytes in base64url", key))
+}
+
+func errInvalidShortID(id string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidShortID", "tls.reality.short_id", fmt.Sprintf("Reality short ID '%s' is invalid, expected up to 16 hex digits of even length", id))
+}
+
+func errInvalid2022Key(method string, size int) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidPassword", "password", fmt.Sprintf("password of '%s' must be %d bytes in base64, several keys are separated by ':'", method, size))
+}
+
+func errInvalidWireGuardKey(field string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidKey", field, fmt.Sprintf("%s is invalid, expected 32 bytes in base64", field))
+}
+
+func errInvalidLocalAddress(a string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidLocalAddress", "local_address", fmt.Sprintf("local address '%s' is invalid, expected a prefix", a))
+}
+
+func errInvalidAllowedIP(field, ip string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_InvalidAllowedIP", field, fmt.Sprintf("allowed IP '%s' is invalid, expected a prefix", ip))
+}
+
+func errUnknownDefault(d string) apperr.Err {
+	return apperr.NewFieldValidationErr("Outbound_UnknownDefault", "default", fmt.Sprintf("default '%s' is not one of the group outbounds", d))
+}
+
+var (
+	flows             = []string{"xtls-rprx-vision"}
+	packetEncodings   = []string{"packetaddr", "xudp"}
+	fingerprints      = []string{"chrome", "firefox", "edge", "safari", "360", "qq", "ios", "android", "random", "randomized"}
+	congestionControl = []string{"cubic", "new_reno", "bbr"}
+	udpRelayModes     = []string{"native", "quic"}
+	socksVersions     = []string{"4", "4a", "5"}
+	obfsTypes         = []string{"salamander"}
+	transportTypes    = []string{"http", "ws", "quic", "grpc", "httpupgrade"}
+
+	// The key sizes of the Shadowsocks 2022 methods, their passwords are base64 keys
+	ss2022Methods = map[string]int{
+		"2022-blake3-aes-128-gcm":       16,
+		"2022-blake3-aes-256-gcm":       32,
+		"2022-blake3-chacha20-poly1305": 32,
+	}
+	ssMethods = []string{
+		"2022-blake3-aes-128-gcm", "2022-blake3-aes-256-gcm", "2022-blake3-chacha20-poly1305",
+		"none", "aes-128-gcm", "aes-192-gcm", "aes-256-gcm", "chacha20-ietf-poly1305", "xchacha20-ietf-poly1305",
+		"aes-128-ctr", "aes-192-ctr", "aes-256-ctr", "aes-128-cfb", "aes-192-cfb", "aes-256-cfb",
+		"rc4-md5", "chacha20-ietf", "xchacha20",
+	}
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate checks the fields the model knows, the other ones are left to the sing-box check
+func Validate(o *config.Outbound) apperr.Err {
+	if o.Tag == "" {
+		return errEmptyTag
+	}
+
+	if strings.ContainsAny(o.Tag, " \t\n\r") {
+		return errInvalidTag(o.Tag)
+	}
+
+	if o.Type == "" {
+		return errEmptyType
+	}
+
+	if !o.OptionsMatch() {
+		return errOptionsMatch
+	}
+
+	if s, ok := o.Server(); ok {
+		if strings.TrimSpace(s.Server) == "" {
+			return errEmptyServer
+		}
+
+		if s.ServerPort < 1 || s.ServerPort > 65535 {
+			return errInvalidPort(s.ServerPort)
+		}
+	}
+
+	if err := validateOptions(o.Options); err != nil {
+		return err
+	}
+
+	if t := o.TLS(); t != nil {
+		return validateTLS(t)
+	}
+
+	return nil
+}
+
+func validateOptions(options config.OutboundOptions) apperr.Err {
+	switch opts := options.(type) {
+	case *config.SelectorOptions:
+		if len(opts.Outbounds) == 0 {
+			return errEmptyMembers
+		}
+
+		if opts.Default != "" && !slices.Contains(opts.Outbounds, opts.Default) {
+			return errUnknownDefault(opts.Default)
+		}
+	case *config.URLTestOptions:
+		if len(opts.Outbounds) == 0 {
+			return errEmptyMembers
+		}
+	case *config.VLESSOptions:
+		return validateVLESS(opts)
+	case *config.ShadowsocksOptions:
+		return validateShadowsocks(opts)
+	case *config.TrojanOptions:
+		if opts.Password == "" {
+			return errEmptyPassword
+		}
+
+		return validateTransport(opts.Transport)
+	case *config.Hysteria2Options:
+		return validateHysteria2(opts)
+	case *config.TUICOptions:
+		return validateTUIC(opts)
+	case *config.WireGuardOptions:
+		return validateWireGuard(opts)
+	case *config.SOCKSOptions:
+		if opts.Version != "" && !slices.Contains(socksVersions, opts.Version) {
+			return errNotOneOf("Outbound_InvalidVersion", "version", "SOCKS version", opts.Version, socksVersions)
+		}
+	}
+
+	return nil
+}
+
+func validateVLESS(o *config.VLESSOptions) apperr.Err {
+	if !uuidRegex.MatchString(o.UUID) {
+		return errInvalidUUID(o.UUID)
+	}
+
+	if o.Flow != "" && !slices.Contains(flows, o.Flow) {
+		return errNotOneOf("Outbound_InvalidFlow", "flow", "flow", o.Flow, flows)
+	}
+
+	if o.PacketEncoding != "" && !slices.Contains(packetEncodings, o.PacketEncoding) {
+		return errNotOneOf("Outbound_InvalidPacketEncoding", "packet_encoding", "packet encoding", o.PacketEncoding, packetEncodings)
+	}
+
+	return validateTransport(o.Transport)
+}
+
+func validateTransport(t *config.Transport) apperr.Err {
+	if t != nil && !slices.Contains(transportTypes, t.Type) {
+		return errNotOneOf("Outbound_InvalidTransport", "transport.type", "transport", t.Type, transportTypes)
+	}
+
+	return nil
+}
+
+func validateShadowsocks(o *config.ShadowsocksOptions) apperr.Err {
+	if !slices.Contains(ssMethods, o.Method) {
+		return errNotOneOf("Outbound_InvalidMethod", "method", "method", o.Method, ssMethods)
+	}
+
+	if size, ok := ss2022Methods[o.Method]; ok {
+		for _, key := range strings.Split(o.Password, ":") {
+			if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != size {
+				return errInvalid2022Key(o.Method, size)
+			}
+		}
+
+		return nil
+	}
+
+	if o.Method != "none" && o.Password == "" {
+		return errEmptyPassword
+	}
+
+	return nil
+}
+
+func validateHysteria2(o *config.Hysteria2Options) apperr.Err {
+	if o.UpMbps < 0 {
+		return errNegativeBandwidth("up_mbps")
+	}
+
+	if o.DownMbps < 0 {
+		return errNegativeBandwidth("down_mbps")
+	}
+
+	if o.Obfs != nil && o.Obfs.Type != "" {
+		if !slices.Contains(obfsTypes, o.Obfs.Type) {
+			return errNotOneOf("Outbound_InvalidObfs", "obfs.type", "obfuscation", o.Obfs.Type, obfsTypes)
+		}
+
+		if o.Obfs.Password == "" {
+			return errEmptyObfsPass
+		}
+	}
+
+	return requireTLS(o.TLS)
+}
+
+func validateTUIC(o *config.TUICOptions) apperr.Err {
+	if !uuidRegex.MatchString(o.UUID) {
+		return errInvalidUUID(o.UUID)
+	}
+
+	if o.CongestionControl != "" && !slices.Contains(congestionControl, o.CongestionControl) {
+		return errNotOneOf("Outbound_InvalidCongestionControl", "congestion_control", "congestion control", o.CongestionControl, congestionControl)
+	}
+
+	if o.UDPRelayMode != "" && !slices.Contains(udpRelayModes, o.UDPRelayMode) {
+		return errNotOneOf("Outbound_InvalidUDPRelayMode", "udp_relay_mode", "UDP relay mode", o.UDPRelayMode, udpRelayModes)
+	}
+
+	return requireTLS(o.TLS)
+}
+
+func validateWireGuard(o *config.WireGuardOptions) apperr.Err {
+	if len(o.LocalAddress) == 0 {
+		return errEmptyAddress
+	}
+
+	for _, a := range o.LocalAddress {
+		if _, err := netip.ParsePrefix(a); err != nil {
+			return errInvalidLocalAddress(a)
+		}
+	}
+
+	if err := validateWireGuardKey("private_key", o.PrivateKey, false); err != nil {
+		return err
+	}
+
+	if len(o.Peers) == 0 {
+		if err := validateWireGuardKey("peer_public_key", o.PeerPublicKey, false); err != nil {
+			return err
+		}
+
+		return validateWireGuardKey("pre_shared_key", o.PreSharedKey, true)
+	}
+
+	for i, p := range o.Peers {
+		if err := validateWireGuardPeer(fmt.Sprintf("peers[%d].", i), &p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateWireGuardPeer prefixes the fields of the errors with the position of the peer
+func validateWireGuardPeer(prefix string, p *config.WireGuardPeer) apperr.Err {
+	if strings.TrimSpace(p.Server) == "" {
+		return apperr.NewFieldValidationErr(errEmptyServer.Code(), prefix+"server", errEmptyServer.Msg())
+	}
+
+	if p.ServerPort < 1 || p.ServerPort > 65535 {
+		err := errInvalidPort(p.ServerPort)
+		return apperr.NewFieldValidationErr(err.Code(), prefix+"server_port", err.Msg())
+	}
+
+	if err := validateWireGuardKey(prefix+"public_key", p.PublicKey, false); err != nil {
+		return err
+	}
+
+	if err := validateWireGuardKey(prefix+"pre_shared_key", p.PreSharedKey, true); err != nil {
+		return err
+	}
+
+	for _, ip := range p.AllowedIPs {
+		if _, err := netip.ParsePrefix(ip); err != nil {
+			return errInvalidAllowedIP(prefix+"allowed_ips", ip)
+		}
+	}
+
+	return nil
+}
+
+func validateWireGuardKey(field, value string, optional bool) apperr.Err {
+	if optional && value == "" {
+		return nil
+	}
+
+	if k, err := base64.StdEncoding.DecodeString(value); err != nil || len(k) != 32 {
+		return errInvalidWireGuardKey(field)
+	}
+
+	return nil
+}
+
+// requireTLS is for the protocols working over QUIC, they cannot go without TLS
+func requireTLS(t *config.TLS) apperr.Err {
+	if t == nil || !t.Enabled {
+		return errTLSRequired
+	}
+
+	return nil
+}
+
+func validateTLS(t *config.TLS) apperr.Err {
+	if r := t.Reality; r != nil && r.Enabled {
+		if !t.Enabled {
+			return errRealityTLS
+		}
+
+		if key, err := base64.RawURLEncoding.DecodeString(r.PublicKey); err != nil || len(key) != 32 {
+			return errInvalidPublicKey(r.PublicKey)
+		}
+
+		if _, err := hex.DecodeString(r.ShortID); err != nil || len(r.ShortID) > 16 {
+			return errInvalidShortID(r.ShortID)
+		}
+	}
+
+	// sing-box uses chrome when the fingerprint is not specified
+	if u := t.UTLS; u != nil && u.Enabled && u.Fingerprint != "" && !slices.Contains(fingerprints, u.Fingerprint) {
+		return errNotOneOf("Outbound_UnknownFingerprint", "tls.utls.fingerprint", "uTLS fingerprint", u.Fingerprint, fingerprints)
+	}
+
+	return nil
+}
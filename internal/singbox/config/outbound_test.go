@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbound_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected OutboundOptions
+	}{
+		{"Direct", `{"type":"direct","tag":"direct","domain_strategy":"prefer_ipv4"}`, nil},
+		{"Unknown", `{"type":"anytls","tag":"any","server":"198.51.100.1","server_port":443,"password":"secret"}`, nil},
+		{
+			"Selector",
+			`{"type":"selector","tag":"proxy","outbounds":["proxy-nl","direct"],"default":"proxy-nl","interrupt_exist_connections":true}`,
+			&SelectorOptions{Outbounds: []string{"proxy-nl", "direct"}, Default: "proxy-nl"},
+		},
+		{
+			"URLTest",
+			`{"type":"urltest","tag":"auto","outbounds":["proxy-nl"],"interval":"3m"}`,
+			&URLTestOptions{Outbounds: []string{"proxy-nl"}, Interval: "3m"},
+		},
+		{
+			"VLESS",
+			`{"type":"vless","tag":"proxy-nl","server":"198.51.100.1","server_port":443,"uuid":"bf000d23-0752-40b4-affe-68f7707a9661","flow":"xtls-rprx-vision","tls":{"enabled":true,"server_name":"example.com"},"transport":{"type":"grpc","service_name":"tun"}}`,
+			&VLESSOptions{
+				ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 443},
+				UUID:          "bf000d23-0752-40b4-affe-68f7707a9661",
+				Flow:          "xtls-rprx-vision",
+				TLS:           &TLS{Enabled: true, ServerName: "example.com"},
+				Transport:     &Transport{Type: TransportGRPC, ServiceName: "tun"},
+			},
+		},
+		{
+			"Shadowsocks",
+			`{"type":"shadowsocks","tag":"ss","server":"198.51.100.1","server_port":8388,"method":"aes-256-gcm","password":"secret","multiplex":{"enabled":true}}`,
+			&ShadowsocksOptions{ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 8388}, Method: "aes-256-gcm", Password: "secret"},
+		},
+		{
+			"Trojan",
+			`{"type":"trojan","tag":"trojan","detour":"direct","server":"198.51.100.1","server_port":443,"password":"secret","tls":{"enabled":true}}`,
+			&TrojanOptions{ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 443}, Password: "secret", TLS: &TLS{Enabled: true}},
+		},
+		{
+			"Hysteria2",
+			`{"type":"hysteria2","tag":"hy2","server":"198.51.100.1","server_port":443,"password":"secret","up_mbps":100,"obfs":{"type":"salamander","password":"obfs"},"tls":{"enabled":true}}`,
+			&Hysteria2Options{
+				ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 443},
+				Password:      "secret",
+				UpMbps:        100,
+				Obfs:          &Hysteria2Obfs{Type: "salamander", Password: "obfs"},
+				TLS:           &TLS{Enabled: true},
+			},
+		},
+		{
+			"TUIC",
+			`{"type":"tuic","tag":"tuic","server":"198.51.100.1","server_port":443,"uuid":"bf000d23-0752-40b4-affe-68f7707a9661","congestion_control":"bbr","tls":{"enabled":true}}`,
+			&TUICOptions{
+				ServerOptions:     ServerOptions{Server: "198.51.100.1", ServerPort: 443},
+				UUID:              "bf000d23-0752-40b4-affe-68f7707a9661",
+				CongestionControl: "bbr",
+				TLS:               &TLS{Enabled: true},
+			},
+		},
+		{
+			"WireGuard",
+			`{"type":"wireguard","tag":"wg","server":"198.51.100.1","server_port":51820,"local_address":["172.16.0.2/32"],"private_key":"a","peer_public_key":"b","reserved":[0,0,0]}`,
+			&WireGuardOptions{
+				ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 51820},
+				LocalAddress:  []string{"172.16.0.2/32"},
+				PrivateKey:    "a",
+				PeerPublicKey: "b",
+				Reserved:      []int{0, 0, 0},
+			},
+		},
+		{
+			"WireGuard_Peers",
+			`{"type":"wireguard","tag":"wg","local_address":["172.16.0.2/32"],"private_key":"a","peers":[{"server":"198.51.100.1","server_port":51820,"public_key":"b","allowed_ips":["0.0.0.0/0"]}]}`,
+			&WireGuardOptions{
+				LocalAddress: []string{"172.16.0.2/32"},
+				PrivateKey:   "a",
+				Peers: []WireGuardPeer{{
+					ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 51820},
+					PublicKey:     "b",
+					AllowedIPs:    []string{"0.0.0.0/0"},
+				}},
+			},
+		},
+		{
+			"SOCKS",
+			`{"type":"socks","tag":"socks","server":"127.0.0.1","server_port":1080,"version":"5","udp_over_tcp":false}`,
+			&SOCKSOptions{ServerOptions: ServerOptions{Server: "127.0.0.1", ServerPort: 1080}, Version: "5"},
+		},
+		{
+			"HTTP",
+			`{"type":"http","tag":"http","server":"127.0.0.1","server_port":8080,"username":"user","password":"secret"}`,
+			&HTTPOptions{ServerOptions: ServerOptions{Server: "127.0.0.1", ServerPort: 8080}, Username: "user", Password: "secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := new(Outbound)
+			require.NoError(t, json.Unmarshal([]byte(tt.json), o))
+
+			if tt.expected == nil {
+				assert.Nil(t, o.Options)
+			} else {
+				assert.IsType(t, tt.expected, o.Options)
+				assert.Equal(t, jsonOf(t, tt.expected), jsonOf(t, o.Options))
+			}
+			assert.True(t, o.OptionsMatch())
+
+			b, err := json.Marshal(o)
+			require.NoError(t, err)
+			assert.Equal(t, tt.json, string(b))
+		})
+	}
+}
+
+func TestOutbound_ChangeKeepsUnknownFields(t *testing.T) {
+	o := new(Outbound)
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"hysteria2","tag":"hy2","server":"198.51.100.1","server_port":443,"obfs":{"type":"salamander","password":"obfs","extra":1},"brutal_debug":true}`), o))
+
+	opts := o.Options.(*Hysteria2Options)
+	opts.ServerPort = 8443
+	opts.Obfs.Password = "changed"
+	opts.DownMbps = 50
+
+	b, err := json.Marshal(o)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"hysteria2","tag":"hy2","server":"198.51.100.1","server_port":8443,"obfs":{"type":"salamander","password":"changed","extra":1},"brutal_debug":true,"down_mbps":50}`, string(b))
+}
+
+func TestNewOutbound(t *testing.T) {
+	o := NewOutbound("proxy-nl", &TrojanOptions{ServerOptions: ServerOptions{Server: "198.51.100.1", ServerPort: 443}, Password: "secret"})
+
+	b, err := json.Marshal(o)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"trojan","tag":"proxy-nl","server":"198.51.100.1","server_port":443,"password":"secret"}`, string(b))
+}
+
+func TestOutbound_OptionsMatch(t *testing.T) {
+	assert.False(t, (&Outbound{Type: OutboundVLESS, Tag: "proxy"}).OptionsMatch())
+	assert.False(t, (&Outbound{Type: OutboundVLESS, Tag: "proxy", Options: &TrojanOptions{}}).OptionsMatch())
+	assert.True(t, (&Outbound{Type: OutboundDirect, Tag: "direct"}).OptionsMatch())
+}
+
+func jsonOf(t *testing.T, v any) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// rawObject keeps every key of a decoded JSON object in its original order, so the keys that
+// are not modelled by the owning struct survive a Load/Save round-trip untouched.
+type rawObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *rawObject) decode(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected a JSON object, got '%v'", t)
+	}
+
+	o.keys = nil
+	o.values = make(map[string]json.RawMessage)
+
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		key := t.(string)
+		var value json.RawMessage
+		if err := d.Decode(&value); err != nil {
+			return err
+		}
+
+		if _, ok := o.values[key]; !ok {
+			o.keys = append(o.keys, key)
+		}
+
+		o.values[key] = value
+	}
+
+	return nil
+}
+
+type objectField struct {
+	name      string
+	omitEmpty bool
+	value     reflect.Value
+}
+
+func collectFields(v reflect.Value, fields []objectField) []objectField {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = collectFields(v.Field(i), fields)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, objectField{name: name, omitEmpty: strings.Contains(opts, "omitempty"), value: v.Field(i)})
+	}
+
+	return fields
+}
+
+func unmarshalObject(data []byte, raw *rawObject, target any) error {
+	if err := json.Unmarshal(data, target); err != nil {
+		return err
+	}
+
+	return raw.decode(data)
+}
+
+// marshalObject writes the fields of all sources as a single object, a nil source is skipped
+func marshalObject(raw *rawObject, sources ...any) ([]byte, error) {
+	var fields []objectField
+	for _, source := range sources {
+		if v := reflect.ValueOf(source); v.IsValid() && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+			fields = collectFields(reflect.Indirect(v), fields)
+		}
+	}
+
+	known := make(map[string]*objectField, len(fields))
+	for i := range fields {
+		known[fields[i].name] = &fields[i]
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	first := true
+	write := func(key string, value []byte) error {
+		if !first {
+			buf.WriteByte(',')
+		}
+
+		first = false
+		k, err := marshalValue(key)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+		return nil
+	}
+
+	// A decoded object never gains empty keys it did not have, and keeps the empty ones it had
+	emit := func(f *objectField, original json.RawMessage) error {
+		decoded := raw.values != nil
+		if (f.omitEmpty || (decoded && original == nil)) && isEmptyValue(f.value) {
+			if original == nil || !isEmptyJSON(original) {
+				return nil
+			}
+
+			return write(f.name, original)
+		}
+
+		value, err := marshalField(f.value, original)
+		if err != nil {
+			return err
+		}
+
+		return write(f.name, value)
+	}
+
+	for _, key := range raw.keys {
+		f, ok := known[key]
+		if !ok {
+			if err := write(key, raw.values[key]); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		delete(known, key)
+		if err := emit(f, raw.values[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range fields {
+		if _, ok := known[fields[i].name]; !ok {
+			continue
+		}
+
+		if err := emit(&fields[i], nil); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalField keeps the single item of a list in the form the configuration has it
+func marshalField(v reflect.Value, original json.RawMessage) ([]byte, error) {
+	if l, ok := v.Interface().(Listable); ok && len(l) == 1 && isJSONString(original) {
+		return marshalValue(l[0])
+	}
+
+	return marshalValue(v.Interface())
+}
+
+func marshalValue(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func isEmptyJSON(raw json.RawMessage) bool {
+	switch string(bytes.TrimSpace(raw)) {
+	case "null", "false", "0", `""`, "[]", "{}":
+		return true
+	default:
+		return false
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	case reflect.Struct:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
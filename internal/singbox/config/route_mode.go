@@ -3,9 +3,12 @@ package config
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 )
 
+// RouteMode is the outbound tag the rules route to. proxy, direct and block are kept as the aliases
+// of the fixed modes the API started with, block rejects the connections instead of using an outbound.
 type RouteMode string
 
 const (
@@ -15,14 +18,27 @@ const (
 )
 
 func (m RouteMode) Validate() error {
-	switch m {
-	case RouteProxy, RouteDirect, RouteBlock:
-		return nil
-	default:
+	if m == "" || strings.ContainsAny(string(m), " \t\n\r") {
 		return fmt.Errorf("invalid route mode '%s'", m)
 	}
+
+	return nil
+}
+
+// ValidateIn checks that the configuration has the outbound the mode routes to
+func (m RouteMode) ValidateIn(c *Conf) error {
+	if m == RouteBlock {
+		return nil
+	}
+
+	if !slices.ContainsFunc(c.Outbounds, func(o *Outbound) bool { return o.Tag == string(m) }) {
+		return fmt.Errorf("outbound '%s' does not exist", m)
+	}
+
+	return nil
 }
 
+// RouteModeFromString accepts the aliases in any case, outbound tags are case-sensitive
 func RouteModeFromString(m string) (RouteMode, error) {
 	trimmed := strings.TrimSpace(m)
 	if trimmed == "" {
@@ -36,7 +52,25 @@ func RouteModeFromString(m string) (RouteMode, error) {
 		return RouteBlock, nil
 	case "direct":
 		return RouteDirect, nil
-	default:
-		return "", fmt.Errorf("route mode '%s' is unknown", m)
 	}
+
+	mode := RouteMode(trimmed)
+	if err := mode.Validate(); err != nil {
+		return "", fmt.Errorf("route mode '%s' is not a valid outbound tag", m)
+	}
+
+	return mode, nil
+}
+
+func RouteModeOfRouteRule(rr *RouteRule) (RouteMode, bool) {
+	if rr.Action == "reject" {
+		return RouteBlock, true
+	}
+
+	mode := RouteMode(rr.Outbound)
+	if mode.Validate() != nil {
+		return "", false
+	}
+
+	return mode, true
 }
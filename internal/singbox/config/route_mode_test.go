@@ -16,7 +16,9 @@ func TestRouteModeValidate(t *testing.T) {
 		{"Proxy", RouteProxy, nil},
 		{"Direct", RouteDirect, nil},
 		{"Block", RouteBlock, nil},
-		{"Unknown", "Unknown", errors.New("invalid route mode 'Unknown'")},
+		{"OutboundTag", "proxy-nl", nil},
+		{"Empty", "", errors.New("invalid route mode ''")},
+		{"Spaces", "proxy nl", errors.New("invalid route mode 'proxy nl'")},
 	}
 
 	for _, tt := range tests {
@@ -39,7 +41,9 @@ func TestRouteModeFromString(t *testing.T) {
 		{"Direct", "direct", RouteDirect, nil},
 		{"EmptyInput", "", "", errors.New("route mode is empty")},
 		{"SpaceOnlyInput", " \n\r\t", "", errors.New("route mode is empty")},
-		{"UnknownMode", "unknown", "", errors.New("route mode 'unknown' is unknown")},
+		{"OutboundTag", "proxy-nl", "proxy-nl", nil},
+		{"OutboundTag_TrimSpaces_KeepCase", " Proxy-NL\n", "Proxy-NL", nil},
+		{"OutboundTagWithSpaces", "proxy nl", "", errors.New("route mode 'proxy nl' is not a valid outbound tag")},
 	}
 
 	for _, tt := range tests {
@@ -50,3 +54,49 @@ func TestRouteModeFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteModeOfRouteRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       RouteRule
+		expected   RouteMode
+		expectedOk bool
+	}{
+		{"Proxy", RouteRule{Outbound: "proxy"}, RouteProxy, true},
+		{"Direct", RouteRule{Outbound: "direct"}, RouteDirect, true},
+		{"Block_Outbound", RouteRule{Outbound: "block"}, RouteBlock, true},
+		{"Block_Reject", RouteRule{Action: "reject"}, RouteBlock, true},
+		{"OutboundTag", RouteRule{Outbound: "proxy-nl"}, "proxy-nl", true},
+		{"Empty", RouteRule{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, ok := RouteModeOfRouteRule(&tt.rule)
+			assert.Equal(t, tt.expected, mode)
+			assert.Equal(t, tt.expectedOk, ok)
+		})
+	}
+}
+
+func TestRouteModeValidateIn(t *testing.T) {
+	c := &Conf{Outbounds: []*Outbound{{Tag: "proxy"}, {Tag: "proxy-nl"}}}
+
+	tests := []struct {
+		name     string
+		mode     RouteMode
+		expected error
+	}{
+		{"Alias", RouteProxy, nil},
+		{"OutboundTag", "proxy-nl", nil},
+		{"Block_NoOutbound", RouteBlock, nil},
+		{"Direct_NoOutbound", RouteDirect, errors.New("outbound 'direct' does not exist")},
+		{"Unknown", "warp", errors.New("outbound 'warp' does not exist")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.mode.ValidateIn(c))
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package simulate
+
+import (
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+const (
+	ActionRoute     = "route"
+	ActionReject    = "reject"
+	ActionHijackDNS = "hijack-dns"
+)
+
+// Keys of a rule that tell what to do with a match rather than what to match
+var (
+	routeActionKeys = []string{
+		"outbound", "action", "method", "no_drop", "override_address", "override_port", "network_strategy",
+		"fallback_delay", "udp_disable_domain_unmapping", "udp_connect", "udp_timeout", "tls_fragment",
+		"tls_record_fragment", "sniffer", "timeout", "strategy", "server",
+	}
+	dnsActionKeys = []string{
+		"server", "action", "disable_cache", "rewrite_ttl", "client_subnet", "strategy", "rcode", "answer", "ns", "extra",
+	}
+
+	routeConditionKeys = []string{"domain", "domain_suffix", "domain_keyword", "domain_regex", "ip_cidr", "inbound", "protocol"}
+	dnsConditionKeys   = []string{"domain", "domain_suffix", "domain_keyword", "domain_regex"}
+)
+
+// Actions that do not end the routing, sing-box goes on to the next rule after them
+var nonFinalActions = []string{"sniff", "resolve", "route-options"}
+
+type Query struct {
+	Host     string
+	IP       netip.Addr
+	Inbound  string
+	Protocol string
+}
+
+type RuleMatch struct {
+	Index int    `json:"index"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// SkippedRule is a rule with conditions the simulator cannot evaluate, the result may differ from
+// sing-box if it would have matched
+type SkippedRule struct {
+	Index  int      `json:"index"`
+	Fields []string `json:"fields"`
+}
+
+type RouteResult struct {
+	Action   string        `json:"action"`
+	Outbound string        `json:"outbound,omitempty"`
+	Rule     *RuleMatch    `json:"rule,omitempty"`
+	Final    bool          `json:"final"`
+	Skipped  []SkippedRule `json:"skipped,omitempty"`
+}
+
+type DNSResult struct {
+	Server  string        `json:"server"`
+	Rule    *RuleMatch    `json:"rule,omitempty"`
+	Final   bool          `json:"final"`
+	Skipped []SkippedRule `json:"skipped,omitempty"`
+}
+
+type Result struct {
+	Route RouteResult `json:"route"`
+	DNS   *DNSResult  `json:"dns,omitempty"`
+}
+
+func Run(c *config.Conf, q *Query) *Result {
+	result := &Result{Route: route(c, q)}
+	if q.Host != "" {
+		dns := resolve(c, q)
+		result.DNS = &dns
+	}
+
+	return result
+}
+
+func route(c *config.Conf, q *Query) RouteResult {
+	var result RouteResult
+	for i := range c.Route.Rules {
+		rr := &c.Route.Rules[i]
+		if unsupported := unsupportedKeys(rr.Keys(), routeConditionKeys, routeActionKeys); len(unsupported) > 0 {
+			result.Skipped = append(result.Skipped, SkippedRule{Index: i, Fields: unsupported})
+			continue
+		}
+
+		m := matchRouteRule(rr, q)
+		if m == nil {
+			continue
+		}
+
+		action := rr.Action
+		if action == "" {
+			action = ActionRoute
+		}
+
+		if slices.Contains(nonFinalActions, action) {
+			continue
+		}
+
+		m.Index = i
+		result.Action, result.Rule = action, m
+		if action == ActionRoute {
+			result.Outbound = rr.Outbound
+		}
+
+		return result
+	}
+
+	result.Action, result.Final = ActionRoute, true
+	result.Outbound = c.Route.Final
+	if result.Outbound == "" && len(c.Outbounds) > 0 {
+		// sing-box falls back to the first outbound
+		result.Outbound = c.Outbounds[0].Tag
+	}
+
+	return result
+}
+
+func resolve(c *config.Conf, q *Query) DNSResult {
+	var result DNSResult
+	for i := range c.DNS.Rules {
+		r := &c.DNS.Rules[i]
+		if unsupported := unsupportedKeys(r.Keys(), dnsConditionKeys, dnsActionKeys); len(unsupported) > 0 {
+			result.Skipped = append(result.Skipped, SkippedRule{Index: i, Fields: unsupported})
+			continue
+		}
+
+		if m := matchDomain(&r.Rule, q.Host); m != nil {
+			m.Index = i
+			result.Server, result.Rule = r.Server, m
+			return result
+		}
+	}
+
+	result.Final = true
+	result.Server = c.DNS.Final
+	if result.Server == "" && len(c.DNS.Servers) > 0 {
+		result.Server = c.DNS.Servers[0].Tag
+	}
+
+	return result
+}
+
+func unsupportedKeys(keys, conditions, actions []string) []string {
+	var unsupported []string
+	for _, k := range keys {
+		if !slices.Contains(conditions, k) && !slices.Contains(actions, k) {
+			unsupported = append(unsupported, k)
+		}
+	}
+
+	return unsupported
+}
+
+// matchRouteRule follows the sing-box logic for the default rules: the destination conditions
+// (domains and IP CIDRs) are OR-ed, the other conditions are AND-ed with them.
+func matchRouteRule(rr *config.RouteRule, q *Query) *RuleMatch {
+	if len(rr.Inbound) > 0 && !slices.Contains(rr.Inbound, q.Inbound) {
+		return nil
+	}
+
+	protocol := slices.IndexFunc(rr.Protocol, func(p string) bool {
+		return strings.EqualFold(p, q.Protocol)
+	})
+	if len(rr.Protocol) > 0 && protocol == -1 {
+		return nil
+	}
+
+	hasDestination := len(rr.Domain)+len(rr.DomainSuffix)+len(rr.DomainKeyword)+len(rr.DomainRegex)+len(rr.IP_CIDR) > 0
+	if !hasDestination {
+		switch {
+		case len(rr.Inbound) > 0:
+			return &RuleMatch{Field: "inbound", Value: q.Inbound}
+		case len(rr.Protocol) > 0:
+			return &RuleMatch{Field: "protocol", Value: rr.Protocol[protocol]}
+		default:
+			// A rule without conditions matches everything
+			return &RuleMatch{}
+		}
+	}
+
+	if m := matchDomain(&rr.Rule, q.Host); m != nil {
+		return m
+	}
+
+	return matchIP(rr.IP_CIDR, q.IP)
+}
+
+func matchDomain(r *config.Rule, host string) *RuleMatch {
+	if host == "" {
+		return nil
+	}
+
+	for _, d := range r.Domain {
+		if strings.EqualFold(d, host) {
+			return &RuleMatch{Field: "domain", Value: d}
+		}
+	}
+
+	for _, s := range r.DomainSuffix {
+		if matchSuffix(strings.ToLower(s), host) {
+			return &RuleMatch{Field: "domain_suffix", Value: s}
+		}
+	}
+
+	for _, k := range r.DomainKeyword {
+		if strings.Contains(host, strings.ToLower(k)) {
+			return &RuleMatch{Field: "domain_keyword", Value: k}
+		}
+	}
+
+	for _, expr := range r.DomainRegex {
+		// An invalid expression makes sing-box refuse the config, here it just never matches
+		if re, err := regexp.Compile(expr); err == nil && re.MatchString(host) {
+			return &RuleMatch{Field: "domain_regex", Value: expr}
+		}
+	}
+
+	return nil
+}
+
+// matchSuffix matches the domain itself and its subdomains, a suffix starting with a dot only the subdomains
+func matchSuffix(suffix, host string) bool {
+	if strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+
+	return host == suffix || strings.HasSuffix(host, "."+suffix)
+}
+
+func matchIP(cidrs []string, ip netip.Addr) *RuleMatch {
+	if !ip.IsValid() {
+		return nil
+	}
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				continue
+			}
+
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		if prefix.Contains(ip.Unmap()) {
+			return &RuleMatch{Field: "ip_cidr", Value: cidr}
+		}
+	}
+
+	return nil
+}
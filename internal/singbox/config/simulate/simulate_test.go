@@ -0,0 +1,149 @@
+package simulate
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+func loadTestConf(t *testing.T) *config.Conf {
+	t.Helper()
+
+	data, err := os.ReadFile("../testdata/config.json")
+	require.NoError(t, err)
+
+	var c config.Conf
+	require.NoError(t, json.Unmarshal(data, &c))
+	return &c
+}
+
+func TestRun_Route(t *testing.T) {
+	c := loadTestConf(t)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected RouteResult
+	}{
+		{
+			"Domain",
+			Query{Host: "chatgpt.com", Inbound: "tun-in"},
+			RouteResult{Action: ActionRoute, Outbound: "proxy", Rule: &RuleMatch{4, "domain", "chatgpt.com"}, Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}}},
+		},
+		{
+			"DomainSuffix",
+			Query{Host: "music.youtube.com"},
+			RouteResult{Action: ActionRoute, Outbound: "proxy", Rule: &RuleMatch{4, "domain_suffix", "youtube.com"}, Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}}},
+		},
+		{
+			"DomainKeyword",
+			Query{Host: "rr1.googlevideo.com"},
+			RouteResult{Action: ActionRoute, Outbound: "proxy", Rule: &RuleMatch{4, "domain_keyword", "googlevideo"}, Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}}},
+		},
+		{
+			"DomainSuffix_AfterSkipped",
+			Query{Host: "mail.yandex.ru"},
+			RouteResult{
+				Action: ActionRoute, Outbound: "direct", Rule: &RuleMatch{6, "domain_suffix", "ru"},
+				Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}, {5, []string{"port", "network"}}},
+			},
+		},
+		{
+			"IPCIDR",
+			Query{IP: netip.MustParseAddr("142.251.1.1")},
+			RouteResult{Action: ActionRoute, Outbound: "proxy", Rule: &RuleMatch{4, "ip_cidr", "142.250.0.0/15"}, Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}}},
+		},
+		{
+			"IPCIDR_IPv6",
+			Query{IP: netip.MustParseAddr("2001:db8::1")},
+			RouteResult{Action: ActionRoute, Outbound: "proxy", Rule: &RuleMatch{4, "ip_cidr", "2001:db8::/32"}, Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}}},
+		},
+		{
+			"Protocol_HijackDNS",
+			Query{Host: "chatgpt.com", Protocol: "DNS"},
+			RouteResult{Action: ActionHijackDNS, Rule: &RuleMatch{1, "protocol", "dns"}},
+		},
+		{
+			"Final",
+			Query{Host: "example.com"},
+			RouteResult{
+				Action: ActionRoute, Outbound: "proxy", Final: true,
+				Skipped: []SkippedRule{{2, []string{"ip_is_private"}}, {3, []string{"rule_set"}}, {5, []string{"port", "network"}}, {7, []string{"type", "mode", "rules"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Run(c, &tt.query).Route)
+		})
+	}
+}
+
+func TestRun_DNS(t *testing.T) {
+	c := loadTestConf(t)
+
+	tests := []struct {
+		name     string
+		host     string
+		expected *DNSResult
+	}{
+		{
+			"Rule",
+			"api.openai.com",
+			&DNSResult{Server: "dns-remote", Rule: &RuleMatch{2, "domain_suffix", "openai.com"}, Skipped: []SkippedRule{{0, []string{"outbound"}}, {1, []string{"rule_set"}}}},
+		},
+		{
+			"SuffixBeforeRegex",
+			"www.gov.ru",
+			&DNSResult{Server: "dns-direct", Rule: &RuleMatch{4, "domain_suffix", "ru"}, Skipped: []SkippedRule{{0, []string{"outbound"}}, {1, []string{"rule_set"}}, {3, []string{"query_type"}}}},
+		},
+		{
+			"Final",
+			"example.com",
+			&DNSResult{Server: "dns-remote", Final: true, Skipped: []SkippedRule{{0, []string{"outbound"}}, {1, []string{"rule_set"}}, {3, []string{"query_type"}}}},
+		},
+		{"NoHost", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Run(c, &Query{Host: tt.host}).DNS)
+		})
+	}
+}
+
+func TestRun_Fallbacks(t *testing.T) {
+	c := loadTestConf(t)
+	c.Route.Rules, c.Route.Final = nil, ""
+	c.DNS.Rules, c.DNS.Final = nil, ""
+
+	result := Run(c, &Query{Host: "example.com"})
+	assert.Equal(t, RouteResult{Action: ActionRoute, Outbound: "proxy", Final: true}, result.Route)
+	assert.Equal(t, &DNSResult{Server: "dns-remote", Final: true}, result.DNS)
+}
+
+func TestMatchSuffix(t *testing.T) {
+	tests := []struct {
+		suffix   string
+		host     string
+		expected bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", true},
+		{"example.com", "myexample.com", false},
+		{".example.com", "example.com", false},
+		{".example.com", "www.example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.suffix+"_"+tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchSuffix(tt.suffix, tt.host))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package singbox
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+const (
+	logTimeLayout = "-0700 2006-01-02 15:04:05"
+	maxLogLineLen = 1024 * 1024
+)
+
+var (
+	// +0300 2024-10-08 12:34:56 INFO [3726174163 12ms] outbound/vless[proxy]: outbound connection to example.com:443
+	// The timestamp is optional, sing-box omits it when log.timestamp is disabled
+	logLineRegex = regexp.MustCompile(`^(?:([+-]\d{4} \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) )?([A-Z]+) (?:\[(\d+) ([^\]]+)\] )?(.*)$`)
+	// outbound/vless[proxy]: message, router: message
+	logSourceRegex = regexp.MustCompile(`^([\w-]+(?:/[\w-]+)?)(?:\[([^\]]*)\])?: (.*)$`)
+
+	logDestinationRegex   = regexp.MustCompile(`connection to (\S+)`)
+	logUsingOutboundRegex = regexp.MustCompile(`using outbound/[\w-]+\[([^\]]*)\]`)
+)
+
+type LogRecord struct {
+	Time         *time.Time `json:"time,omitempty"`
+	Level        string     `json:"level"`
+	ConnectionID string     `json:"connectionId,omitempty"`
+	Elapsed      string     `json:"elapsed,omitempty"`
+	Component    string     `json:"component,omitempty"`
+	Inbound      string     `json:"inbound,omitempty"`
+	Outbound     string     `json:"outbound,omitempty"`
+	Host         string     `json:"host,omitempty"`
+	Port         int        `json:"port,omitempty"`
+	Message      string     `json:"message"`
+}
+
+// ParseLogLine returns false for the lines that are not sing-box records, e.g. stack traces of a panic
+func ParseLogLine(line string) (*LogRecord, bool) {
+	m := logLineRegex.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if m == nil || LogLevelIndex(m[2]) < 0 {
+		return nil, false
+	}
+
+	r := &LogRecord{Level: strings.ToLower(m[2]), ConnectionID: m[3], Elapsed: m[4], Message: m[5]}
+
+	if m[1] != "" {
+		if t, err := time.Parse(logTimeLayout, m[1]); err == nil {
+			r.Time = &t
+		}
+	}
+
+	if src := logSourceRegex.FindStringSubmatch(r.Message); src != nil {
+		r.Component, r.Message = src[1], src[3]
+
+		kind, _, _ := strings.Cut(r.Component, "/")
+		switch kind {
+		case "inbound":
+			r.Inbound = src[2]
+		case "outbound":
+			r.Outbound = src[2]
+		}
+	}
+
+	if r.Outbound == "" {
+		if using := logUsingOutboundRegex.FindStringSubmatch(r.Message); using != nil {
+			r.Outbound = using[1]
+		}
+	}
+
+	if dest := logDestinationRegex.FindStringSubmatch(r.Message); dest != nil {
+		if host, port, err := net.SplitHostPort(strings.TrimRight(dest[1], ":,")); err == nil {
+			r.Host = host
+			r.Port, _ = strconv.Atoi(port)
+		}
+	}
+
+	return r, true
+}
+
+// ReadLogRecords parses the log file and returns the last n records accepted by match in the file order
+// and the number of all the accepted records. Only n records are held while the file is read.
+func ReadLogRecords(match func(*LogRecord) bool, n int) ([]*LogRecord, int, apperr.Err) {
+	path, appErr := getLogPath()
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, ErrLogNotFound
+	} else if err != nil {
+		return nil, 0, apperr.NewFatalErr("Log_OpenError", err.Error())
+	}
+	defer file.Close()
+
+	// window is a ring buffer, next is the position of the oldest record once it is full
+	var window []*LogRecord
+	next, total := 0, 0
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineLen)
+	for scanner.Scan() {
+		r, ok := ParseLogLine(scanner.Text())
+		if !ok || !match(r) {
+			continue
+		}
+
+		total++
+		switch {
+		case n <= 0:
+		case len(window) < n:
+			window = append(window, r)
+		default:
+			window[next] = r
+			next = (next + 1) % n
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, apperr.NewFatalErr("Log_ReadError", err.Error())
+	}
+
+	return slices.Concat(window[next:], window[:next]), total, nil
+}
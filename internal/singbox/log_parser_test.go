@@ -0,0 +1,130 @@
+package singbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logTime(s string) *time.Time {
+	t, err := time.Parse(logTimeLayout, s)
+	if err != nil {
+		panic(err)
+	}
+
+	return &t
+}
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected *LogRecord
+	}{
+		{
+			name: "OutboundConnection",
+			line: "+0300 2024-10-08 12:34:56 INFO [3726174163 12ms] outbound/vless[proxy]: outbound connection to example.com:443",
+			expected: &LogRecord{
+				Time: logTime("+0300 2024-10-08 12:34:56"), Level: "info", ConnectionID: "3726174163", Elapsed: "12ms",
+				Component: "outbound/vless", Outbound: "proxy", Host: "example.com", Port: 443,
+				Message: "outbound connection to example.com:443",
+			},
+		},
+		{
+			name: "InboundConnection_IPv6",
+			line: "+0000 2024-10-08 09:34:56 INFO [42 0ms] inbound/tun[tun-in]: inbound connection to [2606:4700::1111]:853",
+			expected: &LogRecord{
+				Time: logTime("+0000 2024-10-08 09:34:56"), Level: "info", ConnectionID: "42", Elapsed: "0ms",
+				Component: "inbound/tun", Inbound: "tun-in", Host: "2606:4700::1111", Port: 853,
+				Message: "inbound connection to [2606:4700::1111]:853",
+			},
+		},
+		{
+			name: "ConnectionError_UsingOutbound",
+			line: "+0300 2024-10-08 12:35:00 ERROR [7 5.1s] connection: open connection to blocked.org:443 using outbound/vless[proxy]: dial tcp: i/o timeout",
+			expected: &LogRecord{
+				Time: logTime("+0300 2024-10-08 12:35:00"), Level: "error", ConnectionID: "7", Elapsed: "5.1s",
+				Component: "connection", Outbound: "proxy", Host: "blocked.org", Port: 443,
+				Message: "open connection to blocked.org:443 using outbound/vless[proxy]: dial tcp: i/o timeout",
+			},
+		},
+		{
+			name: "NoConnection",
+			line: "+0300 2024-10-08 12:00:00 WARN network: default interface changed",
+			expected: &LogRecord{
+				Time: logTime("+0300 2024-10-08 12:00:00"), Level: "warn", Component: "network", Message: "default interface changed",
+			},
+		},
+		{
+			name:     "NoTimestamp",
+			line:     "DEBUG [1 0ms] router: match[0] => route(direct)",
+			expected: &LogRecord{Level: "debug", ConnectionID: "1", Elapsed: "0ms", Component: "router", Message: "match[0] => route(direct)"},
+		},
+		{
+			name:     "NoComponent",
+			line:     "+0300 2024-10-08 12:00:00 INFO sing-box started (0.25s)",
+			expected: &LogRecord{Time: logTime("+0300 2024-10-08 12:00:00"), Level: "info", Message: "sing-box started (0.25s)"},
+		},
+		{"StackTrace", "goroutine 1 [running]:", nil},
+		{"UnknownLevel", "+0300 2024-10-08 12:00:00 NOTICE something", nil},
+		{"Empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := ParseLogLine(tt.line)
+			assert.Equal(t, tt.expected != nil, ok)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
+
+func TestReadLogRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	t.Setenv("LOG_PATH", path)
+
+	_, _, err := ReadLogRecords(func(*LogRecord) bool { return true }, 10)
+	assert.Equal(t, ErrLogNotFound, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(
+		"+0300 2024-10-08 12:00:00 INFO [1 0ms] outbound/direct[direct]: outbound connection to a.com:80\n"+
+			"panic: boom\n"+
+			"+0300 2024-10-08 12:00:01 ERROR [2 1ms] outbound/vless[proxy]: outbound connection to b.com:443\r\n"+
+			"+0300 2024-10-08 12:00:02 INFO [3 0ms] outbound/vless[proxy]: outbound connection to c.com:443\n",
+	), 0o644))
+
+	proxy := func(r *LogRecord) bool { return r.Outbound == "proxy" }
+	all := func(*LogRecord) bool { return true }
+
+	tests := []struct {
+		name          string
+		match         func(*LogRecord) bool
+		n             int
+		expectedHosts []string
+		expectedTotal int
+	}{
+		{"Filtered", proxy, 10, []string{"b.com", "c.com"}, 2},
+		{"LastOnly", all, 2, []string{"b.com", "c.com"}, 3},
+		{"Window", all, 1, []string{"c.com"}, 3},
+		{"CountOnly", all, 0, []string{}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, total, err := ReadLogRecords(tt.match, tt.n)
+			require.Nil(t, err)
+
+			hosts := []string{}
+			for _, r := range records {
+				hosts = append(hosts, r.Host)
+			}
+
+			assert.Equal(t, tt.expectedHosts, hosts)
+			assert.Equal(t, tt.expectedTotal, total)
+		})
+	}
+}
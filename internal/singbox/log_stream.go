@@ -0,0 +1,192 @@
+package singbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+var logPollInterval = 250 * time.Millisecond
+
+// logLevels are ordered by severity, as sing-box prints them
+var logLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "PANIC"}
+
+func LogLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lineLevel finds the level among the first fields of a line, they are preceded by the timestamp
+// when it is enabled: "+0300 2024-10-08 12:00:00 INFO [...] ..."
+func lineLevel(line string) int {
+	fields := strings.Fields(line)
+	for _, f := range fields[:min(len(fields), 4)] {
+		f, _, _ = strings.Cut(f, "[")
+		if i := LogLevelIndex(f); i >= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type LogFilter struct {
+	// MinLevel is one of the sing-box log levels, lines without a level do not match it
+	MinLevel string
+	Search   string
+	Regex    *regexp.Regexp
+}
+
+func (f *LogFilter) match(line string) bool {
+	if f.MinLevel != "" && lineLevel(line) < LogLevelIndex(f.MinLevel) {
+		return false
+	}
+
+	if f.Search != "" && !strings.Contains(strings.ToLower(line), strings.ToLower(f.Search)) {
+		return false
+	}
+
+	return f.Regex == nil || f.Regex.MatchString(line)
+}
+
+type LogStream struct {
+	path   string
+	filter LogFilter
+	tail   int
+}
+
+func NewLogStream(filter LogFilter, tail int) (*LogStream, apperr.Err) {
+	path, appErr := getLogPath()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return &LogStream{path: path, filter: filter, tail: tail}, nil
+}
+
+// Follow sends the last tail lines and then every new line of the log until the context is done or send fails.
+// It survives the truncation of the file and its rotation (the file is renamed or removed and created again).
+func (s *LogStream) Follow(ctx context.Context, send func(line string) error) error {
+	f := &logFollower{path: s.path}
+	defer f.close()
+
+	emit := func(lines []string) error {
+		for _, l := range lines {
+			if s.filter.match(l) {
+				if err := send(l); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := emit(f.openAtEnd(s.tail)); err != nil {
+		return err
+	}
+
+	for {
+		if err := emit(f.readLines()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+type logFollower struct {
+	path    string
+	file    *os.File
+	offset  int64
+	partial []byte
+}
+
+// openAtEnd opens the file positioned at its end and returns the last n lines before it
+func (f *logFollower) openAtEnd(n int) []string {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil
+	}
+
+	f.file, f.offset = file, stat.Size()
+	return tailLines(file, stat.Size(), n)
+}
+
+func (f *logFollower) readLines() []string {
+	if f.file == nil {
+		// The file did not exist or has been rotated, a new one is read from the start
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil
+		}
+
+		f.file, f.offset, f.partial = file, 0, nil
+	}
+
+	stat, err := f.file.Stat()
+	if err != nil {
+		f.close()
+		return nil
+	}
+
+	if stat.Size() < f.offset {
+		f.offset, f.partial = 0, nil
+	}
+
+	lines := f.readAppended()
+
+	if current, err := os.Stat(f.path); err != nil || !os.SameFile(stat, current) {
+		// Whatever was written to the old file before the rotation has been read above
+		f.close()
+	}
+
+	return lines
+}
+
+func (f *logFollower) readAppended() []string {
+	data, err := io.ReadAll(io.NewSectionReader(f.file, f.offset, 1<<62))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	f.offset += int64(len(data))
+	data = append(f.partial, data...)
+
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		f.partial = data
+		return nil
+	}
+
+	f.partial = append([]byte(nil), data[end+1:]...)
+	return strings.Split(string(data[:end]), "\n")
+}
+
+func (f *logFollower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
@@ -0,0 +1,206 @@
+package singbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineLevel(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected int
+	}{
+		{"+0300 2024-10-08 12:00:00 INFO [3726174163 0ms] inbound/tun[tun-in]: inbound connection", 2},
+		{"+0000 2024-10-08 12:00:00 ERROR [1 5ms] connection: open connection to 1.1.1.1:443", 4},
+		{"WARN[0000] legacy dns server", 3},
+		{"DEBUG router: match[0] => proxy", 1},
+		{"goroutine 1 [running]:", -1},
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			assert.Equal(t, tt.expected, lineLevel(tt.line))
+		})
+	}
+}
+
+func TestLogFilter_Match(t *testing.T) {
+	line := "+0300 2024-10-08 12:00:00 WARN [1 0ms] outbound/vless[proxy]: connection to Example.com:443"
+
+	tests := []struct {
+		name     string
+		filter   LogFilter
+		expected bool
+	}{
+		{"Empty", LogFilter{}, true},
+		{"Level_Lower", LogFilter{MinLevel: "info"}, true},
+		{"Level_Same", LogFilter{MinLevel: "warn"}, true},
+		{"Level_Higher", LogFilter{MinLevel: "error"}, false},
+		{"Search_CaseInsensitive", LogFilter{Search: "example.COM"}, true},
+		{"Search_Missing", LogFilter{Search: "google"}, false},
+		{"Regex", LogFilter{Regex: regexp.MustCompile(`vless\[\w+\]`)}, true},
+		{"Regex_Missing", LogFilter{Regex: regexp.MustCompile(`^ERROR`)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.match(line))
+		})
+	}
+
+	assert.False(t, (&LogFilter{MinLevel: "trace"}).match("goroutine 1 [running]:"))
+}
+
+type collector struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *collector) send(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lines = append(c.lines, line)
+	return nil
+}
+
+func (c *collector) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.lines...)
+}
+
+func followLog(t *testing.T, filter LogFilter, tail int) (*collector, string, func()) {
+	t.Helper()
+
+	prev := logPollInterval
+	logPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { logPollInterval = prev })
+
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	t.Setenv("LOG_PATH", path)
+	require.NoError(t, os.WriteFile(path, []byte("INFO one\nINFO two\n"), 0o644))
+
+	stream, err := NewLogStream(filter, tail)
+	require.Nil(t, err)
+
+	c := &collector{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream.Follow(ctx, c.send)
+	}()
+
+	// Let the follower open the file, whatever is appended later is a new line
+	time.Sleep(20 * time.Millisecond)
+
+	return c, path, func() {
+		cancel()
+		<-done
+	}
+}
+
+func appendLog(t *testing.T, path, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(data)
+	require.NoError(t, err)
+}
+
+func waitLines(t *testing.T, c *collector, expected []string) {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(c.get()) >= len(expected) }, time.Second, 5*time.Millisecond, "got %v", c.get())
+	assert.Equal(t, expected, c.get())
+}
+
+func TestLogStream_Follow(t *testing.T) {
+	t.Run("Tail_NewLines_PartialLine", func(t *testing.T) {
+		c, path, stop := followLog(t, LogFilter{}, 1)
+		defer stop()
+
+		waitLines(t, c, []string{"INFO two"})
+
+		appendLog(t, path, "INFO three\nINFO fo")
+		waitLines(t, c, []string{"INFO two", "INFO three"})
+
+		appendLog(t, path, "ur\n")
+		waitLines(t, c, []string{"INFO two", "INFO three", "INFO four"})
+	})
+
+	t.Run("Truncation", func(t *testing.T) {
+		c, path, stop := followLog(t, LogFilter{}, 0)
+		defer stop()
+
+		require.NoError(t, os.Truncate(path, 0))
+		time.Sleep(20 * time.Millisecond)
+		appendLog(t, path, "INFO after\n")
+
+		waitLines(t, c, []string{"INFO after"})
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		c, path, stop := followLog(t, LogFilter{}, 0)
+		defer stop()
+
+		appendLog(t, path, "INFO last before rotation\n")
+		require.NoError(t, os.Rename(path, path+".1"))
+		appendLog(t, path, "INFO first after rotation\n")
+
+		waitLines(t, c, []string{"INFO last before rotation", "INFO first after rotation"})
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		c, path, stop := followLog(t, LogFilter{MinLevel: "warn", Search: "dns"}, 0)
+		defer stop()
+
+		appendLog(t, path, "INFO dns query\nERROR dns: exchange failed\nERROR router: no route\nWARN dns: slow\n")
+		waitLines(t, c, []string{"ERROR dns: exchange failed", "WARN dns: slow"})
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		c, path, stop := followLog(t, LogFilter{}, 5)
+		defer stop()
+
+		waitLines(t, c, []string{"INFO one", "INFO two"})
+		require.NoError(t, os.Remove(path))
+		time.Sleep(20 * time.Millisecond)
+		appendLog(t, path, "INFO recreated\n")
+
+		waitLines(t, c, []string{"INFO one", "INFO two", "INFO recreated"})
+	})
+}
+
+func TestLogStream_StopsOnSendError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	t.Setenv("LOG_PATH", path)
+	require.NoError(t, os.WriteFile(path, []byte("INFO one\n"), 0o644))
+
+	stream, err := NewLogStream(LogFilter{}, 1)
+	require.Nil(t, err)
+
+	sendErr := assert.AnError
+	assert.Equal(t, sendErr, stream.Follow(context.Background(), func(string) error { return sendErr }))
+}
+
+func TestNewLogStream_EmptyPath(t *testing.T) {
+	t.Setenv("LOG_PATH", "")
+
+	_, err := NewLogStream(LogFilter{}, 0)
+	assert.Equal(t, errLogEmptyPath, err)
+}
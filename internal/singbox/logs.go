@@ -1,12 +1,17 @@
 package singbox
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/traf72/singbox-api/internal/apperr"
 )
 
+// Only the tail of the log is scanned for the last lines, the file may be huge
+const maxLogTailBytes = 256 * 1024
+
 var errLogEmptyPath = apperr.NewFatalErr("Log_EmptyPath", "path to the log file is not specified")
 var ErrLogNotFound = apperr.NewFatalErr("Log_NotFound", "log file not found")
 
@@ -53,3 +58,44 @@ func getLogPath() (string, apperr.Err) {
 
 	return path, nil
 }
+
+func tailLog(lines int) []string {
+	path, appErr := getLogPath()
+	if appErr != nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+
+	return tailLines(file, stat.Size(), lines)
+}
+
+// tailLines returns up to n last lines of the first size bytes of the file
+func tailLines(file *os.File, size int64, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	offset := max(size-maxLogTailBytes, 0)
+	data := make([]byte, size-offset)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil
+	}
+
+	all := strings.Split(string(data), "\n")
+	return all[max(len(all)-n, 0):]
+}
@@ -0,0 +1,245 @@
+package singbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+const (
+	StateActive       = "active"
+	StateActivating   = "activating"
+	StateDeactivating = "deactivating"
+	StateInactive     = "inactive"
+	StateFailed       = "failed"
+	StateUnknown      = "unknown"
+)
+
+const defaultServiceName = "sing-box"
+
+type ServiceManager interface {
+	Start() apperr.Err
+	Stop() apperr.Err
+	Restart() apperr.Err
+	// ActiveState returns one of the State* constants, StateUnknown if the backend cannot tell
+	ActiveState() (string, apperr.Err)
+}
+
+func errInvalidServiceManager(name string) apperr.Err {
+	return apperr.NewFatalErr("Singbox_InvalidServiceManager", fmt.Sprintf("service manager '%s' is unknown, expected 'systemd', 'openrc', 'custom' or 'supervisor'", name))
+}
+
+func getServiceManager() (ServiceManager, apperr.Err) {
+	name := strings.ToLower(utils.GetEnv("SERVICE_MANAGER", "systemd"))
+	service := utils.GetEnv("SINGBOX_SERVICE", defaultServiceName)
+
+	sudo, err := utils.GetEnvBool("SERVICE_USE_SUDO", true)
+	if err != nil {
+		return nil, apperr.NewFatalErr("Singbox_EnvReadingFailed", err.Error())
+	}
+
+	switch name {
+	case "systemd":
+		if appErr := requireLinux(); appErr != nil {
+			return nil, appErr
+		}
+
+		return &systemdManager{unit: service, sudo: sudo}, nil
+	case "openrc":
+		if appErr := requireLinux(); appErr != nil {
+			return nil, appErr
+		}
+
+		return &openRCManager{service: service, sudo: sudo}, nil
+	case "custom":
+		return newCustomManager(service), nil
+	case "supervisor":
+		return getSupervisor(), nil
+	default:
+		return nil, errInvalidServiceManager(name)
+	}
+}
+
+func requireLinux() apperr.Err {
+	if runtime.GOOS != "linux" {
+		return apperr.NewFatalErr("Singbox_InvalidOS", fmt.Sprintf("invalid OS '%s'", runtime.GOOS))
+	}
+
+	return nil
+}
+
+func withSudo(sudo bool, args ...string) []string {
+	if sudo {
+		return append([]string{"sudo"}, args...)
+	}
+
+	return args
+}
+
+func runCommand(args []string) apperr.Err {
+	output, err := runner.RunCombined(args[0], args[1:]...)
+	if err != nil {
+		return apperr.NewFatalErr("Singbox_CommandFailed", fmt.Sprintf("failed to execute the command '%s' with error '%s', output: '%s'", strings.Join(args, " "), err, strings.TrimSpace(string(output))))
+	}
+
+	return nil
+}
+
+type systemdManager struct {
+	unit string
+	sudo bool
+}
+
+func (m *systemdManager) Start() apperr.Err {
+	return runCommand(withSudo(m.sudo, "systemctl", "start", m.unit))
+}
+
+func (m *systemdManager) Stop() apperr.Err {
+	return runCommand(withSudo(m.sudo, "systemctl", "stop", m.unit))
+}
+
+func (m *systemdManager) Restart() apperr.Err {
+	return runCommand(withSudo(m.sudo, "systemctl", "restart", m.unit))
+}
+
+func (m *systemdManager) ActiveState() (string, apperr.Err) {
+	// is-active exits with a non-zero code for any state but "active", the state is still printed
+	output, _ := runner.Run("systemctl", "is-active", m.unit)
+	state := strings.TrimSpace(string(output))
+	if state == "" {
+		return StateUnknown, nil
+	}
+
+	return state, nil
+}
+
+type openRCManager struct {
+	service string
+	sudo    bool
+}
+
+func (m *openRCManager) Start() apperr.Err {
+	return runCommand(withSudo(m.sudo, "rc-service", m.service, "start"))
+}
+
+func (m *openRCManager) Stop() apperr.Err {
+	return runCommand(withSudo(m.sudo, "rc-service", m.service, "stop"))
+}
+
+func (m *openRCManager) Restart() apperr.Err {
+	return runCommand(withSudo(m.sudo, "rc-service", m.service, "restart"))
+}
+
+func (m *openRCManager) ActiveState() (string, apperr.Err) {
+	// rc-service exits with a non-zero code for a stopped or crashed service, the status is still printed
+	output, _ := runner.Run("rc-service", m.service, "status")
+	_, status, ok := strings.Cut(string(output), "status:")
+	if !ok {
+		return StateUnknown, nil
+	}
+
+	switch strings.TrimSpace(status) {
+	case "started":
+		return StateActive, nil
+	case "starting":
+		return StateActivating, nil
+	case "stopping":
+		return StateDeactivating, nil
+	case "stopped":
+		return StateInactive, nil
+	case "crashed":
+		return StateFailed, nil
+	default:
+		return StateUnknown, nil
+	}
+}
+
+// customManager runs user-defined command templates, e.g. for s6 or runit.
+// {service} in a template is replaced with the service name.
+type customManager struct {
+	start, stop, restart, status []string
+}
+
+func newCustomManager(service string) *customManager {
+	vars := []string{"{service}", service}
+	return &customManager{
+		start:   commandFromEnv("SERVICE_START_CMD", "", vars...),
+		stop:    commandFromEnv("SERVICE_STOP_CMD", "", vars...),
+		restart: commandFromEnv("SERVICE_RESTART_CMD", "", vars...),
+		status:  commandFromEnv("SERVICE_STATUS_CMD", "", vars...),
+	}
+}
+
+func errCustomCommandMissing(key string) apperr.Err {
+	return apperr.NewFatalErr("Singbox_CustomCommandMissing", fmt.Sprintf("%s is not set", key))
+}
+
+func (m *customManager) Start() apperr.Err {
+	if len(m.start) == 0 {
+		return errCustomCommandMissing("SERVICE_START_CMD")
+	}
+
+	return runCommand(m.start)
+}
+
+func (m *customManager) Stop() apperr.Err {
+	if len(m.stop) == 0 {
+		return errCustomCommandMissing("SERVICE_STOP_CMD")
+	}
+
+	return runCommand(m.stop)
+}
+
+func (m *customManager) Restart() apperr.Err {
+	if len(m.restart) > 0 {
+		return runCommand(m.restart)
+	}
+
+	if err := m.Stop(); err != nil {
+		return err
+	}
+
+	return m.Start()
+}
+
+// ActiveState uses the status command output if it is one of the known states,
+// otherwise the exit code: zero means active, anything else inactive.
+func (m *customManager) ActiveState() (string, apperr.Err) {
+	if len(m.status) == 0 {
+		return StateUnknown, nil
+	}
+
+	output, err := runner.Run(m.status[0], m.status[1:]...)
+	switch state := strings.TrimSpace(string(output)); state {
+	case StateActive, StateActivating, StateDeactivating, StateInactive, StateFailed:
+		return state, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return StateInactive, nil
+	} else if err != nil {
+		return "", apperr.NewFatalErr("Singbox_CommandFailed", fmt.Sprintf("failed to execute the command '%s' with error '%s'", strings.Join(m.status, " "), err))
+	}
+
+	return StateActive, nil
+}
+
+func commandFromEnv(key, fallback string, vars ...string) []string {
+	args := strings.Fields(utils.GetEnv(key, fallback))
+	if len(args) == 0 {
+		args = strings.Fields(fallback)
+	}
+
+	r := strings.NewReplacer(vars...)
+	for i := range args {
+		args[i] = r.Replace(args[i])
+	}
+
+	return args
+}
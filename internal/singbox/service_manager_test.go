@@ -0,0 +1,302 @@
+package singbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupFakeBin puts a directory with fake executables in front of PATH and runs the commands for real.
+// Every fake appends its name and the arguments it has received, each in brackets, to the returned log.
+func setupFakeBin(t *testing.T, scripts map[string]string) func() []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	for name, body := range scripts {
+		record := `{ printf '%s' "` + name + `"; for arg in "$@"; do printf ' [%s]' "$arg"; done; echo; } >> ` + logPath
+		writeScript(t, dir, name, record+"\n"+body)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DISABLE_SINGBOX_INTERACTION", "false")
+
+	prevRunner := runner
+	runner = execRunner{}
+	t.Cleanup(func() { runner = prevRunner })
+
+	return func() []string {
+		data, err := os.ReadFile(logPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestGetServiceManager(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		expected     ServiceManager
+		expectedCode string
+	}{
+		{"Default", nil, &systemdManager{unit: "sing-box", sudo: true}, ""},
+		{"Systemd_NoSudo", map[string]string{"SERVICE_MANAGER": "Systemd", "SINGBOX_SERVICE": "sb", "SERVICE_USE_SUDO": "false"}, &systemdManager{unit: "sb", sudo: false}, ""},
+		{"OpenRC", map[string]string{"SERVICE_MANAGER": "openrc"}, &openRCManager{service: "sing-box", sudo: true}, ""},
+		{
+			"Custom",
+			map[string]string{"SERVICE_MANAGER": "custom", "SINGBOX_SERVICE": "sb", "SERVICE_START_CMD": "s6-svc -u /run/service/{service}"},
+			&customManager{start: []string{"s6-svc", "-u", "/run/service/sb"}, stop: []string{}, restart: []string{}, status: []string{}},
+			"",
+		},
+		{"Unknown", map[string]string{"SERVICE_MANAGER": "runit"}, nil, "Singbox_InvalidServiceManager"},
+		{"InvalidSudo", map[string]string{"SERVICE_USE_SUDO": "maybe"}, nil, "Singbox_EnvReadingFailed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SERVICE_MANAGER", "SINGBOX_SERVICE", "SERVICE_USE_SUDO", "SERVICE_START_CMD", "SERVICE_STOP_CMD", "SERVICE_RESTART_CMD", "SERVICE_STATUS_CMD"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			m, err := getServiceManager()
+			if tt.expectedCode != "" {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.expectedCode, err.Code())
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, tt.expected, m)
+		})
+	}
+}
+
+func TestSystemdManager(t *testing.T) {
+	t.Run("Sudo", func(t *testing.T) {
+		calls := setupFakeBin(t, map[string]string{
+			"sudo":      `exec "$@"`,
+			"systemctl": `[ "$1" = "is-active" ] && echo activating && exit 3; exit 0`,
+		})
+
+		m := &systemdManager{unit: "sb", sudo: true}
+		assert.Nil(t, m.Start())
+		assert.Nil(t, m.Stop())
+		assert.Nil(t, m.Restart())
+
+		state, err := m.ActiveState()
+		assert.Nil(t, err)
+		assert.Equal(t, StateActivating, state)
+
+		assert.Equal(t, []string{
+			"sudo [systemctl] [start] [sb]", "systemctl [start] [sb]",
+			"sudo [systemctl] [stop] [sb]", "systemctl [stop] [sb]",
+			"sudo [systemctl] [restart] [sb]", "systemctl [restart] [sb]",
+			"systemctl [is-active] [sb]",
+		}, calls())
+	})
+
+	t.Run("NoSudo", func(t *testing.T) {
+		calls := setupFakeBin(t, map[string]string{
+			"sudo":      `exec "$@"`,
+			"systemctl": `exit 0`,
+		})
+
+		m := &systemdManager{unit: "sing-box@main service", sudo: false}
+		assert.Nil(t, m.Restart())
+		assert.Equal(t, []string{"systemctl [restart] [sing-box@main service]"}, calls())
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		setupFakeBin(t, map[string]string{
+			"systemctl": `echo "Unit sb.service not found."; exit 5`,
+		})
+
+		err := (&systemdManager{unit: "sb"}).Start()
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_CommandFailed", err.Code())
+		assert.Contains(t, err.Msg(), "systemctl start sb")
+		assert.Contains(t, err.Msg(), "Unit sb.service not found.")
+	})
+
+	t.Run("State_Unknown", func(t *testing.T) {
+		setupFakeBin(t, map[string]string{"systemctl": `exit 4`})
+
+		state, err := (&systemdManager{unit: "sb"}).ActiveState()
+		assert.Nil(t, err)
+		assert.Equal(t, StateUnknown, state)
+	})
+}
+
+func TestOpenRCManager(t *testing.T) {
+	t.Run("Commands", func(t *testing.T) {
+		calls := setupFakeBin(t, map[string]string{
+			"sudo":       `exec "$@"`,
+			"rc-service": `exit 0`,
+		})
+
+		m := &openRCManager{service: "sing-box", sudo: true}
+		assert.Nil(t, m.Start())
+		assert.Nil(t, m.Stop())
+		assert.Nil(t, m.Restart())
+
+		assert.Equal(t, []string{
+			"sudo [rc-service] [sing-box] [start]", "rc-service [sing-box] [start]",
+			"sudo [rc-service] [sing-box] [stop]", "rc-service [sing-box] [stop]",
+			"sudo [rc-service] [sing-box] [restart]", "rc-service [sing-box] [restart]",
+		}, calls())
+	})
+
+	tests := []struct {
+		output   string
+		code     string
+		expected string
+	}{
+		{" * status: started", "0", StateActive},
+		{" * status: starting", "0", StateActivating},
+		{" * status: stopping", "0", StateDeactivating},
+		{" * status: stopped", "3", StateInactive},
+		{" * status: crashed", "32", StateFailed},
+		{" * rc-service: service `sing-box' does not exist", "1", StateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run("State_"+tt.expected, func(t *testing.T) {
+			calls := setupFakeBin(t, map[string]string{
+				"rc-service": `echo "` + tt.output + `"; exit ` + tt.code,
+			})
+
+			state, err := (&openRCManager{service: "sing-box"}).ActiveState()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, state)
+			assert.Equal(t, []string{"rc-service [sing-box] [status]"}, calls())
+		})
+	}
+}
+
+func TestCustomManager(t *testing.T) {
+	setCustomEnv := func(t *testing.T, env map[string]string) {
+		t.Setenv("SINGBOX_SERVICE", "sb")
+		for _, key := range []string{"SERVICE_START_CMD", "SERVICE_STOP_CMD", "SERVICE_RESTART_CMD", "SERVICE_STATUS_CMD"} {
+			t.Setenv(key, env[key])
+		}
+	}
+
+	t.Run("Commands", func(t *testing.T) {
+		calls := setupFakeBin(t, map[string]string{"s6-svc": `exit 0`})
+		setCustomEnv(t, map[string]string{
+			"SERVICE_START_CMD":   "s6-svc -u /run/service/{service}",
+			"SERVICE_STOP_CMD":    "  s6-svc   -d\t/run/service/{service}  ",
+			"SERVICE_RESTART_CMD": "s6-svc -r /run/service/{service}/'quoted'",
+		})
+
+		m := newCustomManager("sb")
+		assert.Nil(t, m.Start())
+		assert.Nil(t, m.Stop())
+		assert.Nil(t, m.Restart())
+
+		assert.Equal(t, []string{
+			"s6-svc [-u] [/run/service/sb]",
+			"s6-svc [-d] [/run/service/sb]",
+			"s6-svc [-r] [/run/service/sb/'quoted']",
+		}, calls())
+	})
+
+	t.Run("Restart_FallsBackToStopStart", func(t *testing.T) {
+		calls := setupFakeBin(t, map[string]string{"svc": `exit 0`})
+		setCustomEnv(t, map[string]string{
+			"SERVICE_START_CMD": "svc up {service}",
+			"SERVICE_STOP_CMD":  "svc down {service}",
+		})
+
+		assert.Nil(t, newCustomManager("sb").Restart())
+		assert.Equal(t, []string{"svc [down] [sb]", "svc [up] [sb]"}, calls())
+	})
+
+	t.Run("MissingCommand", func(t *testing.T) {
+		setCustomEnv(t, nil)
+
+		err := newCustomManager("sb").Start()
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_CustomCommandMissing", err.Code())
+		assert.Equal(t, "SERVICE_START_CMD is not set", err.Msg())
+	})
+
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{"PrintedState", `echo failed; exit 0`, StateFailed},
+		{"ExitZero", `echo "up (pid 42) 10 seconds"; exit 0`, StateActive},
+		{"ExitNonZero", `echo "down 3 seconds"; exit 1`, StateInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run("State_"+tt.name, func(t *testing.T) {
+			calls := setupFakeBin(t, map[string]string{"svstat": tt.script})
+			setCustomEnv(t, map[string]string{"SERVICE_STATUS_CMD": "svstat /run/service/{service}"})
+
+			state, err := newCustomManager("sb").ActiveState()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, state)
+			assert.Equal(t, []string{"svstat [/run/service/sb]"}, calls())
+		})
+	}
+
+	t.Run("State_NotStarted", func(t *testing.T) {
+		setupFakeBin(t, nil)
+		setCustomEnv(t, map[string]string{"SERVICE_STATUS_CMD": "svstat-missing /run/service/{service}"})
+
+		_, err := newCustomManager("sb").ActiveState()
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_CommandFailed", err.Code())
+	})
+
+	t.Run("State_NoCommand", func(t *testing.T) {
+		setCustomEnv(t, nil)
+
+		state, err := newCustomManager("sb").ActiveState()
+		assert.Nil(t, err)
+		assert.Equal(t, StateUnknown, state)
+	})
+}
+
+func TestWaitActive_CustomManager(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCmd    string
+		expectedCode string
+	}{
+		{"Active", "svstat-active", ""},
+		{"Failed", "svstat-failed", "Singbox_NotActive"},
+		{"Unknown", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupRunner(t, map[string]string{
+				"svstat-active": "active\n",
+				"svstat-failed": "failed\n",
+			}, nil)
+			t.Setenv("SERVICE_MANAGER", "custom")
+			t.Setenv("SERVICE_STATUS_CMD", tt.statusCmd)
+			t.Setenv("SINGBOX_HEALTH_TIMEOUT", "10ms")
+
+			err := WaitActive()
+			if tt.expectedCode == "" {
+				assert.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.expectedCode, err.Code())
+			}
+		})
+	}
+}
@@ -1,31 +1,63 @@
 package singbox
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
-	"runtime"
+	"strings"
+	"time"
 
 	"github.com/traf72/singbox-api/internal/apperr"
 	"github.com/traf72/singbox-api/internal/utils"
 )
 
+const (
+	defaultCheckCommand  = "sing-box check -c {config}"
+	defaultHealthTimeout = 3 * time.Second
+	healthPollInterval   = 250 * time.Millisecond
+)
+
 func Start() apperr.Err {
-	return execCommand("start")
+	return withServiceManager(ServiceManager.Start)
 }
 
 func Stop() apperr.Err {
-	return execCommand("stop")
+	return withServiceManager(ServiceManager.Stop)
 }
 
 func Restart() apperr.Err {
-	return execCommand("restart")
+	return withServiceManager(ServiceManager.Restart)
+}
+
+type reloader interface {
+	Reload() apperr.Err
+}
+
+func Reload() apperr.Err {
+	return withServiceManager(func(m ServiceManager) apperr.Err {
+		r, ok := m.(reloader)
+		if !ok {
+			return apperr.NewConflictErr("Singbox_ReloadNotSupported", "the service manager does not support reloading")
+		}
+
+		return r.Reload()
+	})
 }
 
-func execCommand(action string) apperr.Err {
+func interactionDisabled() (bool, apperr.Err) {
 	disabled, err := utils.GetEnvBool("DISABLE_SINGBOX_INTERACTION", false)
 	if err != nil {
-		apperr.NewFatalErr("Singbox_EnvReadingFailed", err.Error())
+		return false, apperr.NewFatalErr("Singbox_EnvReadingFailed", err.Error())
+	}
+
+	return disabled, nil
+}
+
+func withServiceManager(action func(ServiceManager) apperr.Err) apperr.Err {
+	disabled, appErr := interactionDisabled()
+	if appErr != nil {
+		return appErr
 	}
 
 	if disabled {
@@ -33,14 +65,80 @@ func execCommand(action string) apperr.Err {
 		return nil
 	}
 
-	if runtime.GOOS != "linux" {
-		return apperr.NewFatalErr("Singbox_InvalidOS", fmt.Sprintf("invalid OS '%s'", runtime.GOOS))
+	m, appErr := getServiceManager()
+	if appErr != nil {
+		return appErr
 	}
 
-	cmd := exec.Command("sudo", "systemctl", action, "sing-box")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		apperr.NewFatalErr("Singbox_CommandFailed", fmt.Sprintf("failed to execute the command '%s' with error '%s', output: '%s'", action, err, string(output)))
+	return action(m)
+}
+
+func Check(configPath string) apperr.Err {
+	disabled, appErr := interactionDisabled()
+	if appErr != nil {
+		return appErr
+	}
+
+	if disabled {
+		return nil
+	}
+
+	args := commandFromEnv("SINGBOX_CHECK_CMD", defaultCheckCommand, "{config}", configPath)
+	output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return apperr.NewValidationErr("Singbox_ConfigCheckFailed", fmt.Sprintf("sing-box rejected the configuration: %s", strings.TrimSpace(string(output))))
+	} else if err != nil {
+		return apperr.NewFatalErr("Singbox_ConfigCheckError", fmt.Sprintf("failed to run the config check '%s': %s", strings.Join(args, " "), err))
 	}
+
 	return nil
 }
+
+func WaitActive() apperr.Err {
+	disabled, appErr := interactionDisabled()
+	if appErr != nil {
+		return appErr
+	}
+
+	if disabled {
+		return nil
+	}
+
+	m, appErr := getServiceManager()
+	if appErr != nil {
+		return appErr
+	}
+
+	timeout, err := time.ParseDuration(utils.GetEnv("SINGBOX_HEALTH_TIMEOUT", defaultHealthTimeout.String()))
+	if err != nil {
+		return apperr.NewFatalErr("Singbox_EnvReadingFailed", err.Error())
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		state, appErr := m.ActiveState()
+		if appErr != nil {
+			return appErr
+		}
+
+		if state == StateUnknown {
+			log.Println("sing-box service state is unknown, skipping the health check")
+			return nil
+		}
+
+		if state == StateFailed || state == StateInactive {
+			return apperr.NewFatalErr("Singbox_NotActive", fmt.Sprintf("sing-box service is '%s'", state))
+		}
+
+		if time.Now().After(deadline) {
+			if state != StateActive {
+				return apperr.NewFatalErr("Singbox_NotActive", fmt.Sprintf("sing-box service is '%s' after %s", state, timeout))
+			}
+
+			return nil
+		}
+
+		time.Sleep(healthPollInterval)
+	}
+}
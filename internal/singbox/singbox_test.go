@@ -0,0 +1,53 @@
+package singbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+	return path
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DISABLE_SINGBOX_INTERACTION", "false")
+
+	t.Run("Valid", func(t *testing.T) {
+		script := writeScript(t, dir, "check-ok", `[ "$1" = "-c" ] && [ "$2" = "/etc/sing-box/config.json" ]`)
+		t.Setenv("SINGBOX_CHECK_CMD", script+" -c {config}")
+		assert.Nil(t, Check("/etc/sing-box/config.json"))
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		script := writeScript(t, dir, "check-fail", `echo "FATAL decode config: unknown field"; exit 1`)
+		t.Setenv("SINGBOX_CHECK_CMD", script+" {config}")
+
+		err := Check("config.json")
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_ConfigCheckFailed", err.Code())
+		assert.Equal(t, "sing-box rejected the configuration: FATAL decode config: unknown field", err.Msg())
+	})
+
+	t.Run("CommandNotFound", func(t *testing.T) {
+		t.Setenv("SINGBOX_CHECK_CMD", filepath.Join(dir, "missing")+" check -c {config}")
+
+		err := Check("config.json")
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_ConfigCheckError", err.Code())
+	})
+
+	t.Run("InteractionDisabled", func(t *testing.T) {
+		t.Setenv("DISABLE_SINGBOX_INTERACTION", "true")
+		t.Setenv("SINGBOX_CHECK_CMD", filepath.Join(dir, "missing")+" check -c {config}")
+		assert.Nil(t, Check("config.json"))
+	})
+}
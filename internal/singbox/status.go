@@ -0,0 +1,281 @@
+package singbox
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+const (
+	defaultVersionCommand = "sing-box version"
+	DefaultJournalLines   = 20
+	MaxJournalLines       = 500
+
+	// USER_HZ is 100 on every Linux platform sing-box runs on
+	clockTicksPerSecond = 100
+)
+
+var errStatusInteractionDisabled = apperr.NewConflictErr("Singbox_InteractionDisabled", "sing-box interaction is disabled, the status is unknown")
+
+func errInvalidJournalLines(n int) apperr.Err {
+	return apperr.NewFieldValidationErr("Singbox_InvalidJournalLines", "lines", fmt.Sprintf("lines '%d' is invalid, expected a value between 1 and %d", n, MaxJournalLines))
+}
+
+// commandRunner returns the output of the commands even when they exit with a non-zero code
+type commandRunner interface {
+	// Run returns the standard output
+	Run(name string, args ...string) ([]byte, error)
+	// RunCombined returns the standard output and error together
+	RunCombined(name string, args ...string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+func (execRunner) RunCombined(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+var (
+	runner  commandRunner = execRunner{}
+	procDir               = "/proc"
+)
+
+type ProcessStats struct {
+	RSSBytes   int64   `json:"rssBytes"`
+	CPUSeconds float64 `json:"cpuSeconds"`
+}
+
+type Status struct {
+	ActiveState   string        `json:"activeState"`
+	SubState      string        `json:"subState"`
+	MainPID       int           `json:"mainPid"`
+	StartedAt     *time.Time    `json:"startedAt,omitempty"`
+	UptimeSeconds int64         `json:"uptimeSeconds"`
+	RestartCount  int           `json:"restartCount"`
+	Process       *ProcessStats `json:"process,omitempty"`
+	Version       string        `json:"version,omitempty"`
+	Journal       []string      `json:"journal,omitempty"`
+}
+
+// statusReporter is implemented by the service managers that can tell more than the active state
+type statusReporter interface {
+	status(journalLines int) (*Status, apperr.Err)
+}
+
+var statusProperties = []string{"ActiveState", "SubState", "MainPID", "NRestarts", "ActiveEnterTimestampMonotonic"}
+
+func GetStatus(journalLines int) (*Status, apperr.Err) {
+	if journalLines < 1 || journalLines > MaxJournalLines {
+		return nil, errInvalidJournalLines(journalLines)
+	}
+
+	disabled, appErr := interactionDisabled()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if disabled {
+		return nil, errStatusInteractionDisabled
+	}
+
+	m, appErr := getServiceManager()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var s *Status
+	if r, ok := m.(statusReporter); ok {
+		s, appErr = r.status(journalLines)
+	} else {
+		var state string
+		state, appErr = m.ActiveState()
+		s = &Status{ActiveState: state}
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if version, err := readVersion(); err == nil {
+		s.Version = version
+	} else {
+		log.Printf("failed to get the sing-box version: %s", err)
+	}
+
+	return s, nil
+}
+
+func (m *systemdManager) status(journalLines int) (*Status, apperr.Err) {
+	output, err := runner.Run("systemctl", "show", m.unit, "--property="+strings.Join(statusProperties, ","))
+	if err != nil {
+		return nil, apperr.NewFatalErr("Singbox_StatusFailed", fmt.Sprintf("failed to query the %s unit: %s", m.unit, err))
+	}
+
+	props := parseProperties(output)
+	s := &Status{
+		ActiveState: props["ActiveState"],
+		SubState:    props["SubState"],
+	}
+	s.MainPID, _ = strconv.Atoi(props["MainPID"])
+	s.RestartCount, _ = strconv.Atoi(props["NRestarts"])
+
+	if s.ActiveState == StateActive || s.ActiveState == "reloading" {
+		if enteredAt, err := strconv.ParseInt(props["ActiveEnterTimestampMonotonic"], 10, 64); err == nil && enteredAt > 0 {
+			setUptime(s, time.Duration(enteredAt)*time.Microsecond)
+		}
+	}
+
+	if s.MainPID > 0 {
+		if stats, err := readProcessStats(s.MainPID); err == nil {
+			s.Process = stats
+		} else {
+			log.Printf("failed to read the sing-box process stats: %s", err)
+		}
+	}
+
+	if s.ActiveState == StateFailed || s.ActiveState == StateInactive {
+		s.Journal = m.journal(journalLines)
+	}
+
+	return s, nil
+}
+
+func parseProperties(output []byte) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[k] = v
+		}
+	}
+
+	return props
+}
+
+// setUptime derives the uptime from the monotonic clock, so it does not depend on the
+// locale-specific format systemd uses for the wall clock timestamps.
+func setUptime(s *Status, enteredAt time.Duration) {
+	data, err := os.ReadFile(filepath.Join(procDir, "uptime"))
+	if err != nil {
+		log.Printf("failed to read the system uptime: %s", err)
+		return
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return
+	}
+
+	sinceBoot, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return
+	}
+
+	uptime := time.Duration(sinceBoot*float64(time.Second)) - enteredAt
+	if uptime < 0 {
+		return
+	}
+
+	startedAt := time.Now().Add(-uptime).UTC().Truncate(time.Second)
+	s.StartedAt = &startedAt
+	s.UptimeSeconds = int64(uptime.Seconds())
+}
+
+func readProcessStats(pid int) (*ProcessStats, error) {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	stats := new(ProcessStats)
+
+	status, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer status.Close()
+
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "VmRSS:"); ok {
+			fields := strings.Fields(v)
+			if len(fields) > 0 {
+				kb, err := strconv.ParseInt(fields[0], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid VmRSS '%s'", v)
+				}
+
+				stats.RSSBytes = kb * 1024
+			}
+		}
+	}
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The process name may contain spaces and parentheses, the fields start after the last ')'
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid stat file of the process %d", pid)
+	}
+
+	// utime and stime are the 14th and 15th fields, the remainder starts at the 3rd one
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("invalid stat file of the process %d", pid)
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.CPUSeconds = float64(utime+stime) / clockTicksPerSecond
+	return stats, nil
+}
+
+func readVersion() (string, error) {
+	args := commandFromEnv("SINGBOX_VERSION_CMD", defaultVersionCommand)
+	output, err := runner.Run(args[0], args[1:]...)
+	if err != nil {
+		return "", err
+	}
+
+	// The first line looks like "sing-box version 1.10.1"
+	first, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty version output")
+	}
+
+	return fields[len(fields)-1], nil
+}
+
+func (m *systemdManager) journal(lines int) []string {
+	output, err := runner.Run("journalctl", "-u", m.unit, "-n", strconv.Itoa(lines), "--no-pager", "-o", "short-iso")
+	if err != nil {
+		log.Printf("failed to read the sing-box journal: %s", err)
+		return nil
+	}
+
+	trimmed := strings.TrimRight(string(output), "\n")
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, "\n")
+}
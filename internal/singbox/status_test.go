@@ -0,0 +1,174 @@
+package singbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner matches the commands by the prefix, the commands that are in neither map cannot be started
+type fakeRunner struct {
+	outputs map[string]string
+	// failures are the commands exiting with a non-zero code, the output is returned still
+	failures map[string]string
+	calls    []string
+}
+
+func (f *fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, cmd)
+
+	for prefix, output := range f.failures {
+		if strings.HasPrefix(cmd, prefix) {
+			return []byte(output), &exec.ExitError{}
+		}
+	}
+
+	for prefix, output := range f.outputs {
+		if strings.HasPrefix(cmd, prefix) {
+			return []byte(output), nil
+		}
+	}
+
+	return nil, errors.New("executable file not found")
+}
+
+func (f *fakeRunner) RunCombined(name string, args ...string) ([]byte, error) {
+	return f.Run(name, args...)
+}
+
+func setupRunner(t *testing.T, outputs, failures map[string]string) *fakeRunner {
+	t.Helper()
+	t.Setenv("DISABLE_SINGBOX_INTERACTION", "false")
+
+	fake := &fakeRunner{outputs: outputs, failures: failures}
+	prevRunner := runner
+	runner = fake
+	t.Cleanup(func() { runner = prevRunner })
+
+	return fake
+}
+
+func setupStatus(t *testing.T, outputs map[string]string) (*fakeRunner, string) {
+	t.Helper()
+	t.Setenv("SINGBOX_VERSION_CMD", "")
+
+	fake := setupRunner(t, outputs, nil)
+	dir := t.TempDir()
+
+	prevProcDir := procDir
+	procDir = dir
+	t.Cleanup(func() { procDir = prevProcDir })
+
+	return fake, dir
+}
+
+func writeProcFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestGetStatus_Active(t *testing.T) {
+	fake, dir := setupStatus(t, map[string]string{
+		"systemctl show sing-box": "ActiveState=active\nSubState=running\nMainPID=1234\nNRestarts=2\nActiveEnterTimestampMonotonic=100000000\n",
+		"sing-box version":        "sing-box version 1.10.1\n\nEnvironment: go1.23.1 linux/amd64\n",
+	})
+
+	writeProcFile(t, dir, "uptime", "3700.50 7000.00\n")
+	writeProcFile(t, dir, "1234/status", "Name:\tsing-box\nVmPeak:\t  50000 kB\nVmRSS:\t   20480 kB\n")
+	writeProcFile(t, dir, "1234/stat", "1234 (sing (box)) S 1 1234 1234 0 -1 4194560 1000 0 0 0 150 50 0 0 20 0 10 0 500 0 0\n")
+
+	s, err := GetStatus(DefaultJournalLines)
+	require.Nil(t, err)
+
+	assert.Equal(t, "active", s.ActiveState)
+	assert.Equal(t, "running", s.SubState)
+	assert.Equal(t, 1234, s.MainPID)
+	assert.Equal(t, 2, s.RestartCount)
+	assert.Equal(t, int64(3600), s.UptimeSeconds)
+	assert.NotNil(t, s.StartedAt)
+	assert.Equal(t, &ProcessStats{RSSBytes: 20480 * 1024, CPUSeconds: 2}, s.Process)
+	assert.Equal(t, "1.10.1", s.Version)
+	assert.Nil(t, s.Journal)
+	assert.Equal(t, "systemctl show sing-box --property=ActiveState,SubState,MainPID,NRestarts,ActiveEnterTimestampMonotonic", fake.calls[0])
+}
+
+func TestGetStatus_Failed(t *testing.T) {
+	fake, _ := setupStatus(t, map[string]string{
+		"systemctl show sing-box": "ActiveState=failed\nSubState=failed\nMainPID=0\nNRestarts=5\nActiveEnterTimestampMonotonic=0\n",
+		"journalctl -u sing-box":  "2026-10-18T08:00:00+0000 host sing-box[1]: FATAL start service\n2026-10-18T08:00:01+0000 host systemd[1]: sing-box.service: Failed\n",
+	})
+
+	s, err := GetStatus(2)
+	require.Nil(t, err)
+
+	assert.Equal(t, "failed", s.ActiveState)
+	assert.Equal(t, 0, s.MainPID)
+	assert.Equal(t, 5, s.RestartCount)
+	assert.Nil(t, s.StartedAt)
+	assert.Nil(t, s.Process)
+	assert.Empty(t, s.Version)
+	assert.Equal(t, []string{
+		"2026-10-18T08:00:00+0000 host sing-box[1]: FATAL start service",
+		"2026-10-18T08:00:01+0000 host systemd[1]: sing-box.service: Failed",
+	}, s.Journal)
+	assert.Contains(t, fake.calls, "journalctl -u sing-box -n 2 --no-pager -o short-iso")
+}
+
+func TestGetStatus_Errors(t *testing.T) {
+	t.Run("InvalidLines", func(t *testing.T) {
+		setupStatus(t, nil)
+
+		for _, n := range []int{0, -1, MaxJournalLines + 1} {
+			_, err := GetStatus(n)
+			require.NotNil(t, err)
+			assert.Equal(t, "Singbox_InvalidJournalLines", err.Code())
+			assert.Equal(t, "lines", err.Field())
+		}
+	})
+
+	t.Run("SystemctlFailed", func(t *testing.T) {
+		setupStatus(t, nil)
+
+		_, err := GetStatus(DefaultJournalLines)
+		require.NotNil(t, err)
+		assert.Equal(t, "Singbox_StatusFailed", err.Code())
+	})
+
+	t.Run("InteractionDisabled", func(t *testing.T) {
+		fake, _ := setupStatus(t, nil)
+		t.Setenv("DISABLE_SINGBOX_INTERACTION", "true")
+
+		_, err := GetStatus(DefaultJournalLines)
+		assert.Equal(t, errStatusInteractionDisabled, err)
+		assert.Empty(t, fake.calls)
+	})
+}
+
+func TestReadProcessStats_InvalidStat(t *testing.T) {
+	_, dir := setupStatus(t, nil)
+	writeProcFile(t, dir, "42/status", "VmRSS:\t100 kB\n")
+	writeProcFile(t, dir, "42/stat", "42 (sing-box) S 1\n")
+
+	_, err := readProcessStats(42)
+	assert.Error(t, err)
+}
+
+func TestGetStatus_CustomManager(t *testing.T) {
+	setupStatus(t, map[string]string{"sing-box version": "sing-box version 1.11.0\n", "svstat": "active\n"})
+	t.Setenv("SERVICE_MANAGER", "custom")
+	t.Setenv("SERVICE_STATUS_CMD", "svstat")
+
+	s, err := GetStatus(DefaultJournalLines)
+	require.Nil(t, err)
+	assert.Equal(t, &Status{ActiveState: StateActive, Version: "1.11.0"}, s)
+}
@@ -0,0 +1,303 @@
+package singbox
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+const (
+	defaultRunCommand = "sing-box run -c {config}"
+
+	supervisorMinBackoff  = time.Second
+	supervisorMaxBackoff  = 30 * time.Second
+	supervisorResetAfter  = time.Minute
+	supervisorStopTimeout = 10 * time.Second
+)
+
+var (
+	errSupervisorNotRunning = apperr.NewConflictErr("Singbox_NotRunning", "sing-box is not running")
+	errEmptyConfigPath      = apperr.NewFatalErr("Singbox_EmptyConfigPath", "path to the config file is not specified")
+)
+
+// supervisor runs sing-box as a child process and restarts it with an exponential backoff when it exits
+// unexpectedly. The output of the child goes to the log file served by GetLog.
+type supervisor struct {
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	resetAfter  time.Duration
+	stopTimeout time.Duration
+
+	// ctl serializes Start, Stop and Restart, mu guards the state shared with the supervising goroutine
+	ctl       sync.Mutex
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	state     string
+	startedAt time.Time
+	restarts  int
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+var (
+	supervisorOnce    sync.Once
+	defaultSupervisor *supervisor
+)
+
+func newSupervisor() *supervisor {
+	return &supervisor{
+		minBackoff:  supervisorMinBackoff,
+		maxBackoff:  supervisorMaxBackoff,
+		resetAfter:  supervisorResetAfter,
+		stopTimeout: supervisorStopTimeout,
+		state:       StateInactive,
+	}
+}
+
+func getSupervisor() *supervisor {
+	supervisorOnce.Do(func() {
+		defaultSupervisor = newSupervisor()
+	})
+
+	return defaultSupervisor
+}
+
+func SupervisorMode() bool {
+	return strings.ToLower(utils.GetEnv("SERVICE_MANAGER", "systemd")) == "supervisor"
+}
+
+func (s *supervisor) Start() apperr.Err {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	return s.start()
+}
+
+func (s *supervisor) Stop() apperr.Err {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	s.stopAndWait()
+	return nil
+}
+
+func (s *supervisor) Restart() apperr.Err {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	s.stopAndWait()
+	return s.start()
+}
+
+func (s *supervisor) start() apperr.Err {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return nil
+	}
+
+	cmd, output, appErr := s.spawn()
+	if appErr != nil {
+		s.state = StateFailed
+		return appErr
+	}
+
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go s.supervise(cmd, output, s.stop, s.done)
+	return nil
+}
+
+func (s *supervisor) stopAndWait() {
+	s.mu.Lock()
+	if s.stop == nil {
+		s.mu.Unlock()
+		return
+	}
+
+	close(s.stop)
+	cmd, done := s.cmd, s.done
+	s.stop, s.done = nil, nil
+	s.state = StateDeactivating
+	if cmd != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(s.stopTimeout):
+		log.Printf("sing-box did not stop in %s, killing it", s.stopTimeout)
+		if cmd != nil {
+			cmd.Process.Kill()
+		}
+		<-done
+	}
+}
+
+func (s *supervisor) Reload() apperr.Err {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd == nil {
+		return errSupervisorNotRunning
+	}
+
+	if err := s.cmd.Process.Signal(syscall.SIGHUP); err != nil {
+		return apperr.NewFatalErr("Singbox_ReloadFailed", err.Error())
+	}
+
+	return nil
+}
+
+func (s *supervisor) ActiveState() (string, apperr.Err) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state, nil
+}
+
+func (s *supervisor) status(journalLines int) (*Status, apperr.Err) {
+	s.mu.Lock()
+	st := &Status{ActiveState: s.state, RestartCount: s.restarts}
+	if s.cmd != nil {
+		startedAt := s.startedAt.UTC().Truncate(time.Second)
+		st.SubState = "running"
+		st.MainPID = s.cmd.Process.Pid
+		st.StartedAt = &startedAt
+		st.UptimeSeconds = int64(time.Since(s.startedAt).Seconds())
+	} else if s.stop != nil {
+		st.SubState = "auto-restart"
+	} else {
+		st.SubState = "dead"
+	}
+	s.mu.Unlock()
+
+	if st.MainPID > 0 {
+		if stats, err := readProcessStats(st.MainPID); err == nil {
+			st.Process = stats
+		} else {
+			log.Printf("failed to read the sing-box process stats: %s", err)
+		}
+	}
+
+	if st.ActiveState == StateFailed || st.ActiveState == StateInactive {
+		st.Journal = tailLog(journalLines)
+	}
+
+	return st, nil
+}
+
+// spawn must be called with the mutex held
+func (s *supervisor) spawn() (*exec.Cmd, io.Closer, apperr.Err) {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		return nil, nil, errEmptyConfigPath
+	}
+
+	args := commandFromEnv("SINGBOX_RUN_CMD", defaultRunCommand, "{config}", configPath)
+	cmd := exec.Command(args[0], args[1:]...)
+
+	var output io.Closer = io.NopCloser(nil)
+	if logPath, appErr := getLogPath(); appErr == nil {
+		file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, apperr.NewFatalErr("Log_OpenError", err.Error())
+		}
+
+		cmd.Stdout, cmd.Stderr, output = file, file, file
+	} else {
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		output.Close()
+		return nil, nil, apperr.NewFatalErr("Singbox_StartFailed", fmt.Sprintf("failed to start '%s': %s", strings.Join(args, " "), err))
+	}
+
+	s.cmd, s.startedAt, s.state = cmd, time.Now(), StateActive
+	return cmd, output, nil
+}
+
+func (s *supervisor) supervise(cmd *exec.Cmd, output io.Closer, stop, done chan struct{}) {
+	defer close(done)
+
+	backoff := s.minBackoff
+	for cmd != nil {
+		err := cmd.Wait()
+		output.Close()
+
+		s.mu.Lock()
+		ran := time.Since(s.startedAt)
+		s.cmd = nil
+		stopped := isClosed(stop)
+		if !stopped {
+			s.state = StateFailed
+		}
+		s.mu.Unlock()
+
+		if stopped {
+			break
+		}
+
+		log.Printf("sing-box exited unexpectedly: %v", err)
+		if ran >= s.resetAfter {
+			backoff = s.minBackoff
+		}
+
+		cmd, output, backoff = s.respawn(stop, backoff)
+	}
+
+	s.mu.Lock()
+	s.state = StateInactive
+	s.mu.Unlock()
+}
+
+// respawn waits for the backoff and starts sing-box again until it succeeds or the supervisor is stopped
+func (s *supervisor) respawn(stop chan struct{}, backoff time.Duration) (*exec.Cmd, io.Closer, time.Duration) {
+	for {
+		log.Printf("restarting sing-box in %s", backoff)
+
+		select {
+		case <-stop:
+			return nil, nil, backoff
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, s.maxBackoff)
+
+		s.mu.Lock()
+		if isClosed(stop) {
+			s.mu.Unlock()
+			return nil, nil, backoff
+		}
+
+		s.restarts++
+		cmd, output, appErr := s.spawn()
+		s.mu.Unlock()
+
+		if appErr == nil {
+			return cmd, output, backoff
+		}
+
+		log.Printf("failed to restart sing-box: %s", appErr.Msg())
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+package singbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeSingbox = `echo "started $*"
+trap 'echo reloaded' HUP
+trap 'echo stopping; exit 0' TERM
+while true; do sleep 0.02; done`
+
+func setupSupervisor(t *testing.T, script string) (*supervisor, func() string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "sing-box.log")
+	bin := writeScript(t, dir, "sing-box", script)
+
+	t.Setenv("CONFIG_PATH", "/etc/sing-box/config.json")
+	t.Setenv("LOG_PATH", logPath)
+	t.Setenv("SINGBOX_RUN_CMD", bin+" run -c {config}")
+
+	s := newSupervisor()
+	s.minBackoff, s.maxBackoff, s.stopTimeout = 10*time.Millisecond, 40*time.Millisecond, time.Second
+	t.Cleanup(func() { s.Stop() })
+
+	return s, func() string {
+		data, _ := os.ReadFile(logPath)
+		return string(data)
+	}
+}
+
+func state(s *supervisor) string {
+	st, _ := s.ActiveState()
+	return st
+}
+
+func TestSupervisor_Lifecycle(t *testing.T) {
+	s, logs := setupSupervisor(t, fakeSingbox)
+	assert.Equal(t, StateInactive, state(s))
+
+	require.Nil(t, s.Start())
+	assert.Equal(t, StateActive, state(s))
+	require.Eventually(t, func() bool { return strings.Contains(logs(), "started run -c /etc/sing-box/config.json") }, time.Second, 10*time.Millisecond)
+
+	st, err := s.status(DefaultJournalLines)
+	require.Nil(t, err)
+	assert.Equal(t, StateActive, st.ActiveState)
+	assert.Equal(t, "running", st.SubState)
+	assert.Positive(t, st.MainPID)
+	assert.NotNil(t, st.StartedAt)
+	assert.Nil(t, st.Journal)
+
+	// Starting twice keeps the same process
+	require.Nil(t, s.Start())
+	st2, _ := s.status(DefaultJournalLines)
+	assert.Equal(t, st.MainPID, st2.MainPID)
+
+	require.Nil(t, s.Reload())
+	require.Eventually(t, func() bool { return strings.Contains(logs(), "reloaded") }, time.Second, 10*time.Millisecond)
+
+	require.Nil(t, s.Restart())
+	assert.Equal(t, StateActive, state(s))
+	require.Eventually(t, func() bool { return strings.Count(logs(), "started") == 2 }, time.Second, 10*time.Millisecond)
+
+	require.Nil(t, s.Stop())
+	assert.Equal(t, StateInactive, state(s))
+	assert.Contains(t, logs(), "stopping")
+	assert.Equal(t, errSupervisorNotRunning, s.Reload())
+
+	st, _ = s.status(1)
+	assert.Equal(t, "dead", st.SubState)
+	assert.Zero(t, st.MainPID)
+	assert.Equal(t, []string{"stopping"}, st.Journal)
+}
+
+func TestSupervisor_RestartsOnCrash(t *testing.T) {
+	s, logs := setupSupervisor(t, `echo crashed; exit 1`)
+
+	require.Nil(t, s.Start())
+	require.Eventually(t, func() bool {
+		st, _ := s.status(DefaultJournalLines)
+		return st.RestartCount >= 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.GreaterOrEqual(t, strings.Count(logs(), "crashed"), 3)
+
+	require.Nil(t, s.Stop())
+	assert.Equal(t, StateInactive, state(s))
+}
+
+func TestSupervisor_StartFailed(t *testing.T) {
+	s, _ := setupSupervisor(t, fakeSingbox)
+	t.Setenv("SINGBOX_RUN_CMD", filepath.Join(t.TempDir(), "missing")+" run")
+
+	err := s.Start()
+	require.NotNil(t, err)
+	assert.Equal(t, "Singbox_StartFailed", err.Code())
+	assert.Equal(t, StateFailed, state(s))
+}
+
+func TestSupervisor_StopTimeout(t *testing.T) {
+	s, _ := setupSupervisor(t, `trap '' TERM
+while true; do sleep 0.02; done`)
+	s.stopTimeout = 50 * time.Millisecond
+
+	require.Nil(t, s.Start())
+	require.Nil(t, s.Stop())
+	assert.Equal(t, StateInactive, state(s))
+}
+
+func TestTailLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	t.Setenv("LOG_PATH", path)
+
+	assert.Nil(t, tailLog(2))
+
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644))
+	assert.Equal(t, []string{"two", "three"}, tailLog(2))
+	assert.Equal(t, []string{"one", "two", "three"}, tailLog(10))
+}
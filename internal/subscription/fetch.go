@@ -0,0 +1,158 @@
+package subscription
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+const (
+	fetchTimeout = 30 * time.Second
+	maxSize      = 10 << 20
+	// Providers choose the format by the user agent, sing-box gets the outbounds as JSON or the share links
+	userAgent = "sing-box"
+)
+
+func errInvalidURL(u string) apperr.Err {
+	return apperr.NewFieldValidationErr("Subscription_InvalidURL", "url", fmt.Sprintf("url '%s' is invalid, expected an http(s) or file URL or an absolute path", u))
+}
+
+var errLocalDisabled = apperr.NewFieldValidationErr("Subscription_LocalDisabled", "url", "local sources are disabled, SUBSCRIPTIONS_LOCAL_DIR is not set")
+
+func errOutsideLocalDir(path string) apperr.Err {
+	return apperr.NewFieldValidationErr("Subscription_OutsideLocalDir", "url", fmt.Sprintf("path '%s' is outside of SUBSCRIPTIONS_LOCAL_DIR", path))
+}
+
+func errFetch(msg string) apperr.Err {
+	return apperr.NewFatalErr("Subscription_FetchError", msg)
+}
+
+// localPath returns the path of the local sources
+func localPath(source string) (string, bool) {
+	if filepath.IsAbs(source) {
+		return source, true
+	}
+
+	if u, err := url.Parse(source); err == nil && u.Scheme == "file" && u.Path != "" {
+		return u.Path, true
+	}
+
+	return "", false
+}
+
+// checkLocalPath keeps the local sources in the directory given for them,
+// the service must not read any file it has access to
+func checkLocalPath(path string) apperr.Err {
+	dir := utils.GetEnv("SUBSCRIPTIONS_LOCAL_DIR", "")
+	if dir == "" {
+		return errLocalDisabled
+	}
+
+	if !within(dir, path) {
+		return errOutsideLocalDir(path)
+	}
+
+	return nil
+}
+
+// resolveLocalPath follows the symlinks of the path, the file they lead to is checked as well
+func resolveLocalPath(path string) (string, apperr.Err) {
+	if err := checkLocalPath(path); err != nil {
+		return "", err
+	}
+
+	dir, err := filepath.EvalSymlinks(utils.GetEnv("SUBSCRIPTIONS_LOCAL_DIR", ""))
+	if err != nil {
+		return "", errFetch(err.Error())
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", errFetch(err.Error())
+	}
+
+	if !within(dir, resolved) {
+		return "", errOutsideLocalDir(path)
+	}
+
+	return resolved, nil
+}
+
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+func validateSource(source string) apperr.Err {
+	if path, ok := localPath(source); ok {
+		return checkLocalPath(path)
+	}
+
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return nil
+	}
+
+	return errInvalidURL(source)
+}
+
+var httpClient = &http.Client{Timeout: fetchTimeout}
+
+// Fetch reads the content of the source, it is not parsed
+func Fetch(source string) ([]byte, apperr.Err) {
+	if err := validateSource(source); err != nil {
+		return nil, err
+	}
+
+	if path, ok := localPath(source); ok {
+		resolved, err := resolveLocalPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return readLimited(os.Open(resolved))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, errFetch(err.Error())
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errFetch(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errFetch(fmt.Sprintf("source responded with the status %d", resp.StatusCode))
+	}
+
+	return readLimited(resp.Body, nil)
+}
+
+func readLimited(r io.ReadCloser, err error) ([]byte, apperr.Err) {
+	if err != nil {
+		return nil, errFetch(err.Error())
+	}
+
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, errFetch(err.Error())
+	}
+
+	if len(data) > maxSize {
+		return nil, errFetch(fmt.Sprintf("content is larger than %d bytes", maxSize))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package subscription
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+func TestFetch_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sub" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer server.Close()
+
+	data, err := Fetch(server.URL + "/sub")
+	require.Nil(t, err)
+	assert.Equal(t, userAgent, string(data))
+
+	_, err = Fetch(server.URL + "/unknown")
+	assert.Equal(t, errFetch("source responded with the status 404"), err)
+}
+
+func TestFetch_Local(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SUBSCRIPTIONS_LOCAL_DIR", dir)
+
+	path := filepath.Join(dir, "sub.txt")
+	require.NoError(t, os.WriteFile(path, []byte(trojanLink), 0o644))
+
+	data, err := Fetch(path)
+	require.Nil(t, err)
+	assert.Equal(t, trojanLink, string(data))
+
+	data, err = Fetch("file://" + path)
+	require.Nil(t, err)
+	assert.Equal(t, trojanLink, string(data))
+
+	_, err = Fetch(path + ".missing")
+	require.NotNil(t, err)
+	assert.Equal(t, "Subscription_FetchError", err.Code())
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte(trojanLink), 0o644))
+
+	_, err = Fetch(outside)
+	assert.Equal(t, errOutsideLocalDir(outside), err)
+
+	_, err = Fetch(filepath.Join(dir, "..", filepath.Base(filepath.Dir(outside)), "secret.txt"))
+	require.NotNil(t, err)
+	assert.Equal(t, "Subscription_OutsideLocalDir", err.Code())
+
+	link := filepath.Join(dir, "link.txt")
+	require.NoError(t, os.Symlink(outside, link))
+	_, err = Fetch(link)
+	assert.Equal(t, errOutsideLocalDir(link), err)
+}
+
+func TestFetch_LocalDisabled(t *testing.T) {
+	t.Setenv("SUBSCRIPTIONS_LOCAL_DIR", "")
+
+	_, err := Fetch("/etc/passwd")
+	assert.Equal(t, errLocalDisabled, err)
+}
+
+func TestValidateSource(t *testing.T) {
+	t.Setenv("SUBSCRIPTIONS_LOCAL_DIR", "/srv/subscriptions")
+
+	tests := []struct {
+		source   string
+		expected apperr.Err
+	}{
+		{"https://example.com/sub?token=1", nil},
+		{"http://127.0.0.1:8080/sub", nil},
+		{"file:///srv/subscriptions/sub.txt", nil},
+		{"/srv/subscriptions/sub.txt", nil},
+		{"/srv/subscriptions/nested/sub.txt", nil},
+		{"/etc/passwd", errOutsideLocalDir("/etc/passwd")},
+		{"file:///etc/passwd", errOutsideLocalDir("/etc/passwd")},
+		{"/srv/subscriptions/../../etc/passwd", errOutsideLocalDir("/srv/subscriptions/../../etc/passwd")},
+		{"/srv/subscriptions", errOutsideLocalDir("/srv/subscriptions")},
+		{"/srv/subscriptions-other/sub.txt", errOutsideLocalDir("/srv/subscriptions-other/sub.txt")},
+		{"sub.txt", errInvalidURL("sub.txt")},
+		{"ftp://example.com/sub", errInvalidURL("ftp://example.com/sub")},
+		{"https://", errInvalidURL("https://")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			assert.Equal(t, tt.expected, validateSource(tt.source))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package subscription
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+)
+
+// skippedLineError does not repeat the line, the content of the source is not disclosed
+const skippedLineError = "line is not a supported share link"
+
+func errInvalidJSON(msg string) apperr.Err {
+	return apperr.NewValidationErr("Subscription_InvalidJson", "content is not a list of outbounds: "+msg)
+}
+
+// The outbounds of a sing-box configuration that are not servers of the provider
+var serviceTypes = []string{config.OutboundDirect, config.OutboundBlock, config.OutboundDNS, config.OutboundSelector, config.OutboundURLTest}
+
+// Skipped is an entry of the content that cannot be used, Index is the line of a link that cannot be parsed
+// or the position of an invalid outbound, the latter has the tag
+type Skipped struct {
+	Index int    `json:"index"`
+	Tag   string `json:"tag,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// Parse takes a sing-box configuration or its outbounds as JSON, or the share links one per line
+// either as they are or in base64. The tags are made of the names and have no prefix.
+func Parse(data []byte) ([]*config.Outbound, []Skipped, apperr.Err) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+
+	if len(data) > 0 && (data[0] == '[' || data[0] == '{') {
+		return parseJSON(data)
+	}
+
+	if decoded, ok := decodeBase64(data); ok {
+		data = decoded
+	}
+
+	outbounds, skips := parseLinks(data)
+	return outbounds, skips, nil
+}
+
+func parseJSON(data []byte) ([]*config.Outbound, []Skipped, apperr.Err) {
+	var outbounds []*config.Outbound
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &outbounds); err != nil {
+			return nil, nil, errInvalidJSON(err.Error())
+		}
+	} else {
+		var c struct {
+			Outbounds []*config.Outbound `json:"outbounds"`
+		}
+
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, nil, errInvalidJSON(err.Error())
+		}
+
+		outbounds = c.Outbounds
+	}
+
+	result := make([]*config.Outbound, 0, len(outbounds))
+	for _, o := range outbounds {
+		if o == nil || slices.Contains(serviceTypes, o.Type) {
+			continue
+		}
+
+		o.Tag = outbound.MakeTag(o.Tag, o)
+		result = append(result, o)
+	}
+
+	return result, nil, nil
+}
+
+func parseLinks(data []byte) ([]*config.Outbound, []Skipped) {
+	var outbounds []*config.Outbound
+	var skips []Skipped
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxSize)
+	for i := 0; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		o, err := outbound.ParseLink(line)
+		if err != nil {
+			skips = append(skips, Skipped{Index: i, Code: err.Code(), Error: skippedLineError})
+			continue
+		}
+
+		outbounds = append(outbounds, o)
+	}
+
+	return outbounds, skips
+}
+
+// decodeBase64 decodes the content only when it is a list of links in base64,
+// the padding and the line breaks the providers add are ignored
+func decodeBase64(data []byte) ([]byte, bool) {
+	s := strings.TrimRight(strings.Join(strings.Fields(string(data)), ""), "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil && bytes.Contains(b, []byte("://")) {
+			return b, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,85 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+)
+
+const (
+	vlessLink  = "vless://bf000d23-0752-40b4-affe-68f7707a9661@198.51.100.1:443?security=reality&sni=www.microsoft.com&pbk=jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0&sid=0123#NL%20Amsterdam"
+	trojanLink = "trojan://secret@198.51.100.2:443?sni=example.com#DE"
+)
+
+func tags(outbounds []*config.Outbound) []string {
+	var result []string
+	for _, o := range outbounds {
+		result = append(result, o.Tag)
+	}
+
+	return result
+}
+
+func TestParse(t *testing.T) {
+	links := vlessLink + "\r\n\n# comment\n" + trojanLink + "\n"
+
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+		skipped  []Skipped
+	}{
+		{"Links", links, []string{"nl-amsterdam", "de"}, nil},
+		{"Base64", base64.StdEncoding.EncodeToString([]byte(links)), []string{"nl-amsterdam", "de"}, nil},
+		{"Base64_URLWrapped", base64.RawURLEncoding.EncodeToString([]byte(links))[:40] + "\n" + base64.RawURLEncoding.EncodeToString([]byte(links))[40:], []string{"nl-amsterdam", "de"}, nil},
+		{"BOM", "\xef\xbb\xbf" + trojanLink, []string{"de"}, nil},
+		{
+			"UnsupportedLinks",
+			"vmess://eyJhZGQiOiJleGFtcGxlLmNvbSJ9\n" + trojanLink + "\ntrojan://secret@example.com",
+			[]string{"de"},
+			[]Skipped{
+				{Index: 0, Code: "OutboundLink_UnsupportedScheme", Error: skippedLineError},
+				{Index: 2, Code: "OutboundLink_Invalid", Error: skippedLineError},
+			},
+		},
+		{
+			"LocalFile",
+			"root:x:0:0:root:/root:/bin/bash\n" + trojanLink,
+			[]string{"de"},
+			[]Skipped{{Index: 0, Code: "OutboundLink_UnsupportedScheme", Error: skippedLineError}},
+		},
+		{
+			"JsonOutbounds",
+			`[{"type":"trojan","tag":"US West","server":"198.51.100.3","server_port":443,"password":"secret","tls":{"enabled":true}},
+				{"type":"vmess","tag":"jp","server":"198.51.100.4","server_port":443,"uuid":"bf000d23-0752-40b4-affe-68f7707a9661"}]`,
+			[]string{"us-west", "jp"},
+			nil,
+		},
+		{
+			"JsonConfig",
+			`{"log":{},"outbounds":[{"type":"selector","tag":"proxy","outbounds":["a"]},{"type":"direct","tag":"direct"},
+				{"type":"shadowsocks","tag":"🇫🇷","server":"198.51.100.5","server_port":8388,"method":"aes-256-gcm","password":"secret"}]}`,
+			[]string{"shadowsocks-198.51.100.5"},
+			nil,
+		},
+		{"Empty", "", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbounds, skipped, err := Parse([]byte(tt.data))
+			require.Nil(t, err)
+			assert.Equal(t, tt.expected, tags(outbounds))
+			assert.Equal(t, tt.skipped, skipped)
+		})
+	}
+}
+
+func TestParse_InvalidJson(t *testing.T) {
+	_, _, err := Parse([]byte(`{"outbounds": {}}`))
+	require.NotNil(t, err)
+	assert.Equal(t, "Subscription_InvalidJson", err.Code())
+}
@@ -0,0 +1,207 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+)
+
+var errNoOutbounds = apperr.NewValidationErr("Subscription_NoOutbounds", "subscription has no valid outbounds, the configuration is left as it is")
+
+func errSelectorType(tag, t string) apperr.Err {
+	return apperr.NewConflictErr("Subscription_SelectorType", fmt.Sprintf("outbound '%s' is '%s', not a selector", tag, t))
+}
+
+type Result struct {
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Kept are the outbounds the subscription no longer has that are still used by the configuration
+	Kept    []string  `json:"kept,omitempty"`
+	Skipped []Skipped `json:"skipped,omitempty"`
+	Changed bool      `json:"changed"`
+}
+
+// Tags are the outbounds of the configuration belonging to the subscription
+func (s *Subscription) Tags(c *config.Conf) []string {
+	var tags []string
+	for _, o := range c.Outbounds {
+		if s.Owns(o.Tag) {
+			tags = append(tags, o.Tag)
+		}
+	}
+
+	return tags
+}
+
+func sameOutbound(a, b *config.Outbound) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// prepare prefixes the tags making them unique and leaves the valid outbounds only,
+// the tags of the outbounds not belonging to the subscription are not taken over
+func prepare(c *config.Conf, s *Subscription, outbounds []*config.Outbound, r *Result) []*config.Outbound {
+	taken := make(map[string]bool, len(c.Outbounds)+len(outbounds))
+	valid := make([]*config.Outbound, 0, len(outbounds))
+
+	for _, o := range c.Outbounds {
+		if !s.Owns(o.Tag) {
+			taken[o.Tag] = true
+		}
+	}
+
+	for i, o := range outbounds {
+		tag := s.Prefix + o.Tag
+		for n := 2; taken[tag] || tag == s.Selector; n++ {
+			tag = fmt.Sprintf("%s%s-%d", s.Prefix, o.Tag, n)
+		}
+
+		o.Tag = tag
+		if err := outbound.Validate(o); err != nil {
+			r.Skipped = append(r.Skipped, Skipped{Index: i, Tag: tag, Code: err.Code(), Error: err.Msg()})
+			continue
+		}
+
+		taken[tag] = true
+		valid = append(valid, o)
+	}
+
+	return valid
+}
+
+// Reconcile makes the outbounds of the subscription in the configuration the given ones. The outbounds
+// the subscription no longer has are removed unless the configuration uses them, the selector is made
+// of all the outbounds of the subscription. Nothing is changed when there is no valid outbound,
+// a provider returning nothing must not leave the configuration without the servers.
+// The outbounds the subscription owns afterwards are recorded in s.Outbounds.
+func Reconcile(c *config.Conf, s *Subscription, outbounds []*config.Outbound) (*Result, apperr.Err) {
+	r := new(Result)
+	outbounds = prepare(c, s, outbounds, r)
+	if len(outbounds) == 0 {
+		return r, errNoOutbounds
+	}
+
+	tags := make([]string, 0, len(outbounds))
+	for _, o := range outbounds {
+		tags = append(tags, o.Tag)
+
+		i := slices.IndexFunc(c.Outbounds, func(existing *config.Outbound) bool {
+			return existing.Tag == o.Tag
+		})
+
+		switch {
+		case i == -1:
+			c.Outbounds = append(c.Outbounds, o)
+			r.Added = append(r.Added, o.Tag)
+		case !sameOutbound(c.Outbounds[i], o):
+			c.Outbounds[i] = o
+			r.Updated = append(r.Updated, o.Tag)
+		}
+	}
+
+	selectorChanged, selectorCreated, err := updateSelector(c, s, tags)
+	if err != nil {
+		return r, err
+	}
+
+	for _, tag := range s.Tags(c) {
+		if slices.Contains(tags, tag) {
+			continue
+		}
+
+		if len(outbound.References(c, tag)) > 0 {
+			r.Kept = append(r.Kept, tag)
+			continue
+		}
+
+		if err := outbound.Remove(c, tag); err != nil {
+			return r, err
+		}
+
+		r.Removed = append(r.Removed, tag)
+	}
+
+	s.Outbounds = append(tags, r.Kept...)
+	s.SelectorCreated = s.SelectorCreated || selectorCreated
+	r.Changed = selectorChanged || len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Removed) > 0
+	return r, nil
+}
+
+// updateSelector creates the selector or makes the outbounds of the subscription its members after
+// the ones added by hand, the default is kept while the selector still has it
+func updateSelector(c *config.Conf, s *Subscription, tags []string) (changed bool, created bool, err apperr.Err) {
+	if s.Selector == "" {
+		return false, false, nil
+	}
+
+	o, err := outbound.Get(c, s.Selector)
+	if err != nil {
+		c.Outbounds = append(c.Outbounds, config.NewOutbound(s.Selector, &config.SelectorOptions{Outbounds: tags}))
+		return true, true, nil
+	}
+
+	opts, ok := o.Options.(*config.SelectorOptions)
+	if !ok {
+		return false, false, errSelectorType(s.Selector, o.Type)
+	}
+
+	members := slices.DeleteFunc(slices.Clone(opts.Outbounds), func(tag string) bool {
+		return s.Owns(tag) || slices.Contains(tags, tag)
+	})
+	members = append(members, tags...)
+
+	if slices.Equal(opts.Outbounds, members) {
+		return false, false, nil
+	}
+
+	opts.Outbounds = members
+	if !slices.Contains(members, opts.Default) {
+		opts.Default = ""
+	}
+
+	return true, false, nil
+}
+
+// Purge removes the outbounds of the subscription and the selector it has created, they are only taken
+// out of the selector it has not. It is refused when the configuration still uses any of them.
+func Purge(c *config.Conf, s *Subscription) (bool, apperr.Err) {
+	changed := false
+	if s.Selector != "" {
+		if o, err := outbound.Get(c, s.Selector); err == nil {
+			if s.SelectorCreated {
+				if err := outbound.Remove(c, s.Selector); err != nil {
+					return false, err
+				}
+
+				changed = true
+			} else if opts, ok := o.Options.(*config.SelectorOptions); ok {
+				members := slices.DeleteFunc(slices.Clone(opts.Outbounds), s.Owns)
+				if len(members) != len(opts.Outbounds) {
+					opts.Outbounds = members
+					if s.Owns(opts.Default) {
+						opts.Default = ""
+					}
+
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, tag := range s.Tags(c) {
+		if err := outbound.Remove(c, tag); err != nil {
+			return false, err
+		}
+
+		changed = true
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,176 @@
+package subscription
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/singbox/config/outbound"
+)
+
+func loadConf(t *testing.T) *config.Conf {
+	t.Helper()
+
+	data, err := os.ReadFile("../singbox/config/testdata/config.json")
+	require.NoError(t, err)
+
+	c := new(config.Conf)
+	require.NoError(t, json.Unmarshal(data, c))
+	return c
+}
+
+func links(t *testing.T, links ...string) []*config.Outbound {
+	t.Helper()
+
+	var outbounds []*config.Outbound
+	for _, link := range links {
+		o, err := outbound.ParseLink(link)
+		require.Nil(t, err)
+		outbounds = append(outbounds, o)
+	}
+
+	return outbounds
+}
+
+func selectorOf(t *testing.T, c *config.Conf, tag string) *config.SelectorOptions {
+	t.Helper()
+
+	o, err := outbound.Get(c, tag)
+	require.Nil(t, err)
+	return o.Options.(*config.SelectorOptions)
+}
+
+func TestReconcile(t *testing.T) {
+	c := loadConf(t)
+	s := &Subscription{ID: "1", Prefix: "sub-", Selector: "sub"}
+	usLink := "trojan://secret@198.51.100.3:443#US"
+
+	r, err := Reconcile(c, s, links(t, vlessLink, trojanLink, trojanLink))
+	require.Nil(t, err)
+	assert.Equal(t, &Result{Added: []string{"sub-nl-amsterdam", "sub-de", "sub-de-2"}, Changed: true}, r)
+	assert.Equal(t, []string{"sub-nl-amsterdam", "sub-de", "sub-de-2"}, selectorOf(t, c, "sub").Outbounds)
+	assert.Equal(t, []string{"sub-nl-amsterdam", "sub-de", "sub-de-2"}, s.Tags(c))
+	assert.True(t, s.SelectorCreated)
+
+	r, err = Reconcile(c, s, links(t, vlessLink, trojanLink, trojanLink))
+	require.Nil(t, err)
+	assert.Equal(t, &Result{}, r)
+
+	selectorOf(t, c, "sub").Default = "sub-de"
+	c.Route.Final = "sub-de-2"
+
+	r, err = Reconcile(c, s, links(t, usLink, "trojan://changed@198.51.100.2:443?sni=example.com#DE"))
+	require.Nil(t, err)
+	assert.Equal(t, &Result{Added: []string{"sub-us"}, Updated: []string{"sub-de"}, Removed: []string{"sub-nl-amsterdam"}, Kept: []string{"sub-de-2"}, Changed: true}, r)
+	assert.Equal(t, []string{"sub-us", "sub-de"}, selectorOf(t, c, "sub").Outbounds)
+	assert.Equal(t, "sub-de", selectorOf(t, c, "sub").Default)
+	assert.Equal(t, []string{"sub-de", "sub-de-2", "sub-us"}, s.Tags(c))
+
+	r, err = Reconcile(c, s, links(t, usLink))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de"}, r.Removed)
+	assert.Equal(t, "", selectorOf(t, c, "sub").Default)
+}
+
+func TestReconcile_Invalid(t *testing.T) {
+	c := loadConf(t)
+	s := &Subscription{ID: "1", Prefix: "sub-"}
+	before, err := json.Marshal(c)
+	require.NoError(t, err)
+
+	invalid := links(t, "trojan://@198.51.100.3:443#US")
+	r, appErr := Reconcile(c, s, invalid)
+	assert.Equal(t, errNoOutbounds, appErr)
+	assert.Equal(t, []Skipped{{Index: 0, Tag: "sub-us", Code: "Outbound_EmptyPassword", Error: "password is empty"}}, r.Skipped)
+
+	after, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	r, appErr = Reconcile(c, s, links(t, "trojan://@198.51.100.3:443#US", trojanLink))
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{"sub-de"}, r.Added)
+	assert.Len(t, r.Skipped, 1)
+
+	_, appErr = Reconcile(c, &Subscription{ID: "2", Prefix: "other-", Selector: "direct"}, links(t, trojanLink))
+	assert.Equal(t, errSelectorType("direct", "direct"), appErr)
+}
+
+func TestReconcile_ForeignOutbounds(t *testing.T) {
+	c := loadConf(t)
+	s := &Subscription{ID: "1", Prefix: "sub-", Selector: "sub"}
+	manual := new(config.Outbound)
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"direct","tag":"sub-de"}`), manual))
+	c.Outbounds = append(c.Outbounds, manual)
+
+	r, err := Reconcile(c, s, links(t, trojanLink))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de-2"}, r.Added)
+	assert.Equal(t, []string{"sub-de-2"}, s.Outbounds)
+	assert.Equal(t, []string{"sub-de-2"}, selectorOf(t, c, "sub").Outbounds)
+
+	r, err = Reconcile(c, s, links(t, vlessLink))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"sub-de-2"}, r.Removed)
+	assert.Equal(t, []string{"sub-nl-amsterdam"}, s.Outbounds)
+
+	_, err = Purge(c, s)
+	require.Nil(t, err)
+	o, err := outbound.Get(c, "sub-de")
+	require.Nil(t, err)
+	assert.Equal(t, manual, o)
+}
+
+func TestReconcile_ExistingSelector(t *testing.T) {
+	c := loadConf(t)
+	s := &Subscription{ID: "1", Prefix: "sub-", Selector: "select"}
+
+	r, err := Reconcile(c, s, links(t, vlessLink, trojanLink))
+	require.Nil(t, err)
+	assert.True(t, r.Changed)
+	assert.False(t, s.SelectorCreated)
+	assert.Equal(t, []string{"proxy", "proxy-ws", "direct", "sub-nl-amsterdam", "sub-de"}, selectorOf(t, c, "select").Outbounds)
+
+	selectorOf(t, c, "select").Outbounds = append(selectorOf(t, c, "select").Outbounds, "block")
+	selectorOf(t, c, "select").Default = "sub-nl-amsterdam"
+
+	_, err = Reconcile(c, s, links(t, trojanLink))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"proxy", "proxy-ws", "direct", "block", "sub-de"}, selectorOf(t, c, "select").Outbounds)
+	assert.Equal(t, "", selectorOf(t, c, "select").Default)
+
+	selectorOf(t, c, "select").Default = "sub-de"
+	changed, err := Purge(c, s)
+	require.Nil(t, err)
+	assert.True(t, changed)
+	assert.Empty(t, s.Tags(c))
+	assert.Equal(t, []string{"proxy", "proxy-ws", "direct", "block"}, selectorOf(t, c, "select").Outbounds)
+	assert.Equal(t, "", selectorOf(t, c, "select").Default)
+}
+
+func TestPurge(t *testing.T) {
+	c := loadConf(t)
+	s := &Subscription{ID: "1", Prefix: "sub-", Selector: "sub"}
+
+	_, err := Reconcile(c, s, links(t, vlessLink, trojanLink))
+	require.Nil(t, err)
+
+	c.Route.Final = "sub"
+	_, err = Purge(c, s)
+	require.NotNil(t, err)
+	assert.Equal(t, "Outbound_InUse", err.Code())
+
+	c.Route.Final = "proxy"
+	changed, err := Purge(c, s)
+	require.Nil(t, err)
+	assert.True(t, changed)
+	assert.Empty(t, s.Tags(c))
+	assert.Len(t, c.Outbounds, 6)
+
+	changed, err = Purge(c, s)
+	require.Nil(t, err)
+	assert.False(t, changed)
+}
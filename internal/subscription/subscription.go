@@ -0,0 +1,360 @@
+package subscription
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/traf72/singbox-api/internal/apperr"
+	"github.com/traf72/singbox-api/internal/singbox/config"
+	"github.com/traf72/singbox-api/internal/utils"
+)
+
+const minInterval = time.Minute
+
+var (
+	errEmptyPrefix = apperr.NewFieldValidationErr("Subscription_EmptyPrefix", "prefix", "tag prefix is empty")
+	errEmptyURL    = apperr.NewFieldValidationErr("Subscription_EmptyURL", "url", "url is empty")
+)
+
+func errNotFound(id string) apperr.Err {
+	return apperr.NewNotFoundErr("Subscription_NotFound", fmt.Sprintf("subscription '%s' is not found", id))
+}
+
+func errInvalidTag(field, tag string) apperr.Err {
+	return apperr.NewFieldValidationErr("Subscription_InvalidTag", field, fmt.Sprintf("%s '%s' has spaces", field, tag))
+}
+
+func errInvalidInterval(interval string) apperr.Err {
+	return apperr.NewFieldValidationErr("Subscription_InvalidInterval", "interval", fmt.Sprintf("interval '%s' is invalid, expected a duration of %s or more, e.g. '6h'", interval, minInterval))
+}
+
+func errPrefixOverlap(prefix, other, id string) apperr.Err {
+	return apperr.NewConflictErr("Subscription_PrefixOverlap", fmt.Sprintf("prefix '%s' overlaps the prefix '%s' of the subscription '%s'", prefix, other, id))
+}
+
+func errPrefixTaken(prefix, tag string) apperr.Err {
+	return apperr.NewConflictErr("Subscription_PrefixTaken", fmt.Sprintf("prefix '%s' matches the outbound '%s' not belonging to the subscription", prefix, tag))
+}
+
+func errPrefixChange(prefix, current string) apperr.Err {
+	return apperr.NewFieldValidationErr("Subscription_PrefixChange", "prefix", fmt.Sprintf("prefix '%s' cannot replace '%s' while the subscription has outbounds, remove it with purge and add it again", prefix, current))
+}
+
+func errStore(code, msg string) apperr.Err {
+	return apperr.NewFatalErr("Subscription_"+code, msg)
+}
+
+// Subscription is a source of outbounds, the tags of its outbounds are made of the prefix.
+// The outbounds belonging to it are the ones recorded in its status.
+type Subscription struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// URL is an http(s) or file URL, or an absolute path of a local file in SUBSCRIPTIONS_LOCAL_DIR
+	URL    string `json:"url"`
+	Prefix string `json:"prefix"`
+	// Selector is the tag of the selector group kept with all the outbounds of the subscription
+	Selector string `json:"selector,omitempty"`
+	// Interval is how often the subscription is refreshed, it is refreshed on demand only when it is empty
+	Interval string `json:"interval,omitempty"`
+	Status
+}
+
+// Status is the outcome of the last refresh
+type Status struct {
+	LastRefresh *time.Time `json:"lastRefresh,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	// Outbounds are the tags of the outbounds the subscription has added and still owns
+	Outbounds []string `json:"outbounds,omitempty"`
+	// SelectorCreated is set when the selector has been created by the subscription, not taken over
+	SelectorCreated bool `json:"selectorCreated,omitempty"`
+}
+
+func (s *Subscription) interval() time.Duration {
+	d, _ := time.ParseDuration(s.Interval)
+	return d
+}
+
+// Due reports whether the scheduled refresh of the subscription is due
+func (s *Subscription) Due(now time.Time) bool {
+	d := s.interval()
+	if d <= 0 {
+		return false
+	}
+
+	return s.LastRefresh == nil || now.Sub(*s.LastRefresh) >= d
+}
+
+// Owns reports whether the outbound belongs to the subscription, the selector is not one of its outbounds
+func (s *Subscription) Owns(tag string) bool {
+	return slices.Contains(s.Outbounds, tag)
+}
+
+func (s *Subscription) validate() apperr.Err {
+	if strings.TrimSpace(s.URL) == "" {
+		return errEmptyURL
+	}
+
+	if err := validateSource(s.URL); err != nil {
+		return err
+	}
+
+	if s.Prefix == "" {
+		return errEmptyPrefix
+	}
+
+	if strings.ContainsAny(s.Prefix, " \t\n\r") {
+		return errInvalidTag("prefix", s.Prefix)
+	}
+
+	if strings.ContainsAny(s.Selector, " \t\n\r") {
+		return errInvalidTag("selector", s.Selector)
+	}
+
+	if s.Interval != "" {
+		if d, err := time.ParseDuration(s.Interval); err != nil || d < minInterval {
+			return errInvalidInterval(s.Interval)
+		}
+	}
+
+	return nil
+}
+
+var storeMutex sync.Mutex
+
+func getStorePath() (string, apperr.Err) {
+	if path := utils.GetEnv("SUBSCRIPTIONS_FILE", ""); path != "" {
+		return path, nil
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "subscriptions.json"), nil
+}
+
+func load() ([]*Subscription, apperr.Err) {
+	path, appErr := getStorePath()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []*Subscription{}, nil
+	} else if err != nil {
+		return nil, errStore("ReadError", err.Error())
+	}
+
+	subs := []*Subscription{}
+	if err := utils.FromJSON(bytes.NewReader(data), &subs); err != nil {
+		return nil, errStore("JsonDecodeError", err.Error())
+	}
+
+	return subs, nil
+}
+
+func save(subs []*Subscription) apperr.Err {
+	path, appErr := getStorePath()
+	if appErr != nil {
+		return appErr
+	}
+
+	var buf bytes.Buffer
+	if err := utils.ToJSON(&buf, subs, &utils.JSONOptions{Indent: "    "}); err != nil {
+		return errStore("JsonEncodeError", err.Error())
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, buf.Bytes(), 0o600); err != nil {
+		return errStore("TmpFileWriteError", err.Error())
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		if removeErr := os.Remove(tempPath); removeErr != nil {
+			log.Println("failed to remove temp subscriptions file:", removeErr)
+		}
+
+		return errStore("TmpFileRenameError", err.Error())
+	}
+
+	return nil
+}
+
+func index(subs []*Subscription, id string) int {
+	return slices.IndexFunc(subs, func(s *Subscription) bool {
+		return s.ID == id
+	})
+}
+
+func checkPrefix(subs []*Subscription, s *Subscription) apperr.Err {
+	for _, other := range subs {
+		if other.ID != s.ID && (strings.HasPrefix(s.Prefix, other.Prefix) || strings.HasPrefix(other.Prefix, s.Prefix)) {
+			return errPrefixOverlap(s.Prefix, other.Prefix, other.ID)
+		}
+	}
+
+	return nil
+}
+
+// checkOutbounds refuses the prefix of the outbounds the subscription would take over,
+// the selector may be an existing one
+func checkOutbounds(c *config.Conf, s *Subscription) apperr.Err {
+	for _, o := range c.Outbounds {
+		if strings.HasPrefix(o.Tag, s.Prefix) && o.Tag != s.Selector && !s.Owns(o.Tag) {
+			return errPrefixTaken(s.Prefix, o.Tag)
+		}
+	}
+
+	return nil
+}
+
+func newID() (string, apperr.Err) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", errStore("IDError", err.Error())
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func List() ([]*Subscription, apperr.Err) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	return load()
+}
+
+func Get(id string) (*Subscription, apperr.Err) {
+	subs, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	i := index(subs, id)
+	if i == -1 {
+		return nil, errNotFound(id)
+	}
+
+	return subs[i], nil
+}
+
+// Add stores the subscription under a new ID, its status is not taken
+func Add(c *config.Conf, s *Subscription) apperr.Err {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	subs, err := load()
+	if err != nil {
+		return err
+	}
+
+	if s.ID, err = newID(); err != nil {
+		return err
+	}
+
+	if err := checkPrefix(subs, s); err != nil {
+		return err
+	}
+
+	s.Status = Status{}
+	if err := checkOutbounds(c, s); err != nil {
+		return err
+	}
+
+	return save(append(subs, s))
+}
+
+// Update replaces the source of the subscription keeping its status. The prefix
+// is not changed while the subscription has outbounds, they would not match it.
+func Update(c *config.Conf, id string, s *Subscription) apperr.Err {
+	s.ID = id
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	subs, err := load()
+	if err != nil {
+		return err
+	}
+
+	i := index(subs, id)
+	if i == -1 {
+		return errNotFound(id)
+	}
+
+	if err := checkPrefix(subs, s); err != nil {
+		return err
+	}
+
+	s.Status = subs[i].Status
+	if s.Selector != subs[i].Selector {
+		s.SelectorCreated = false
+	}
+
+	if s.Prefix != subs[i].Prefix {
+		if len(s.Outbounds) > 0 {
+			return errPrefixChange(s.Prefix, subs[i].Prefix)
+		}
+
+		if err := checkOutbounds(c, s); err != nil {
+			return err
+		}
+	}
+
+	subs[i] = s
+	return save(subs)
+}
+
+func Remove(id string) apperr.Err {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	subs, err := load()
+	if err != nil {
+		return err
+	}
+
+	i := index(subs, id)
+	if i == -1 {
+		return errNotFound(id)
+	}
+
+	return save(slices.Delete(subs, i, i+1))
+}
+
+// SetStatus records the outcome of a refresh, the subscription removed in the meantime is skipped
+func SetStatus(id string, status Status) apperr.Err {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	subs, err := load()
+	if err != nil {
+		return err
+	}
+
+	i := index(subs, id)
+	if i == -1 {
+		return nil
+	}
+
+	subs[i].Status = status
+	return save(subs)
+}
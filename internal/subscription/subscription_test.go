@@ -0,0 +1,127 @@
+package subscription
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traf72/singbox-api/internal/apperr"
+)
+
+func setupStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("SUBSCRIPTIONS_FILE", filepath.Join(t.TempDir(), "subscriptions.json"))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(s *Subscription)
+		expected apperr.Err
+	}{
+		{"Valid", func(s *Subscription) {}, nil},
+		{"EmptyURL", func(s *Subscription) { s.URL = " " }, errEmptyURL},
+		{"InvalidURL", func(s *Subscription) { s.URL = "sub.txt" }, errInvalidURL("sub.txt")},
+		{"EmptyPrefix", func(s *Subscription) { s.Prefix = "" }, errEmptyPrefix},
+		{"PrefixWithSpaces", func(s *Subscription) { s.Prefix = "my sub-" }, errInvalidTag("prefix", "my sub-")},
+		{"SelectorWithSpaces", func(s *Subscription) { s.Selector = "my sub" }, errInvalidTag("selector", "my sub")},
+		{"NoInterval", func(s *Subscription) { s.Interval = "" }, nil},
+		{"InvalidInterval", func(s *Subscription) { s.Interval = "daily" }, errInvalidInterval("daily")},
+		{"ShortInterval", func(s *Subscription) { s.Interval = "30s" }, errInvalidInterval("30s")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Subscription{URL: "https://example.com/sub", Prefix: "sub-", Selector: "sub", Interval: "6h"}
+			tt.modify(s)
+			assert.Equal(t, tt.expected, s.validate())
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	setupStore(t)
+	c := loadConf(t)
+
+	subs, err := List()
+	require.Nil(t, err)
+	assert.Empty(t, subs)
+
+	s := &Subscription{Name: "Provider", URL: "https://example.com/sub", Prefix: "sub-", Status: Status{LastError: "ignored"}}
+	require.Nil(t, Add(c, s))
+	assert.Len(t, s.ID, 16)
+	assert.Empty(t, s.LastError)
+
+	overlap := &Subscription{URL: "https://example.com/other", Prefix: "sub-nl-"}
+	assert.Equal(t, errPrefixOverlap("sub-nl-", "sub-", s.ID), Add(c, overlap))
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	require.Nil(t, SetStatus(s.ID, Status{LastRefresh: &now, Outbounds: []string{"sub-nl"}}))
+
+	updated := &Subscription{URL: "https://example.com/sub2", Prefix: "sub-", Interval: "1h"}
+	require.Nil(t, Update(c, s.ID, updated))
+
+	stored, err := Get(s.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "https://example.com/sub2", stored.URL)
+	assert.Equal(t, []string{"sub-nl"}, stored.Outbounds)
+	assert.Equal(t, now, *stored.LastRefresh)
+
+	assert.Equal(t, errNotFound("unknown"), Update(c, "unknown", updated))
+	assert.Nil(t, SetStatus("unknown", Status{}))
+
+	require.Nil(t, Remove(s.ID))
+	_, err = Get(s.ID)
+	assert.Equal(t, errNotFound(s.ID), err)
+	assert.Equal(t, errNotFound(s.ID), Remove(s.ID))
+}
+
+func TestStore_Prefix(t *testing.T) {
+	setupStore(t)
+	c := loadConf(t)
+
+	assert.Equal(t, errPrefixTaken("proxy", "proxy"), Add(c, &Subscription{URL: "https://example.com/sub", Prefix: "proxy"}))
+	require.Nil(t, Add(c, &Subscription{URL: "https://example.com/sub", Prefix: "sel", Selector: "select"}))
+
+	s := &Subscription{URL: "https://example.com/sub", Prefix: "sub-"}
+	require.Nil(t, Add(c, s))
+	assert.Equal(t, errPrefixTaken("dire", "direct"), Update(c, s.ID, &Subscription{URL: s.URL, Prefix: "dire"}))
+
+	require.Nil(t, Update(c, s.ID, &Subscription{URL: s.URL, Prefix: "new-"}))
+	require.Nil(t, SetStatus(s.ID, Status{Outbounds: []string{"new-nl"}}))
+	assert.Equal(t, errPrefixChange("other-", "new-"), Update(c, s.ID, &Subscription{URL: s.URL, Prefix: "other-"}))
+	assert.Nil(t, Update(c, s.ID, &Subscription{URL: "https://example.com/sub2", Prefix: "new-"}))
+}
+
+func TestStore_SelectorChange(t *testing.T) {
+	setupStore(t)
+	c := loadConf(t)
+
+	s := &Subscription{URL: "https://example.com/sub", Prefix: "sub-", Selector: "sub"}
+	require.Nil(t, Add(c, s))
+	require.Nil(t, SetStatus(s.ID, Status{Outbounds: []string{"sub-nl"}, SelectorCreated: true}))
+
+	require.Nil(t, Update(c, s.ID, &Subscription{URL: s.URL, Prefix: "sub-", Selector: "sub", Interval: "1h"}))
+	stored, err := Get(s.ID)
+	require.Nil(t, err)
+	assert.True(t, stored.SelectorCreated)
+
+	require.Nil(t, Update(c, s.ID, &Subscription{URL: s.URL, Prefix: "sub-", Selector: "select"}))
+	stored, err = Get(s.ID)
+	require.Nil(t, err)
+	assert.False(t, stored.SelectorCreated)
+	assert.Equal(t, []string{"sub-nl"}, stored.Outbounds)
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-30 * time.Minute)
+	old := now.Add(-2 * time.Hour)
+
+	assert.False(t, (&Subscription{}).Due(now))
+	assert.True(t, (&Subscription{Interval: "1h"}).Due(now))
+	assert.False(t, (&Subscription{Interval: "1h", Status: Status{LastRefresh: &recent}}).Due(now))
+	assert.True(t, (&Subscription{Interval: "1h", Status: Status{LastRefresh: &old}}).Due(now))
+}
@@ -23,3 +23,13 @@ func GetEnvBool(name string, defaultVal bool) (bool, error) {
 
 	return val, nil
 }
+
+func GetEnvInt(name string, defaultVal int) (int, error) {
+	strVal := GetEnv(name, strconv.Itoa(defaultVal))
+	val, err := strconv.Atoi(strVal)
+	if err != nil {
+		return 0, err
+	}
+
+	return val, nil
+}
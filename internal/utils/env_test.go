@@ -57,3 +57,34 @@ func TestGetEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvInt(t *testing.T) {
+	tests := []struct {
+		name        string
+		envIsSet    bool
+		envVal      string
+		defaultVal  int
+		expectedVal int
+		expectedErr bool
+	}{
+		{name: "Environment variable is set", envIsSet: true, envVal: "25", defaultVal: 50, expectedVal: 25},
+		{name: "Environment variable is unset", envIsSet: false, defaultVal: 50, expectedVal: 50},
+		{name: "Environment variable is explicitly empty", envIsSet: true, envVal: "", defaultVal: 50, expectedVal: 50},
+		{name: "Environment variable is invalid", envIsSet: true, envVal: "fifty", defaultVal: 50, expectedVal: 0, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := os.Unsetenv("TEST_ENV_INT")
+			assert.NoError(t, err)
+
+			if tt.envIsSet {
+				t.Setenv("TEST_ENV_INT", tt.envVal)
+			}
+
+			result, err := GetEnvInt("TEST_ENV_INT", tt.defaultVal)
+			assert.Equal(t, tt.expectedVal, result)
+			assert.Equal(t, tt.expectedErr, err != nil)
+		})
+	}
+}